@@ -29,8 +29,9 @@ type Allocator interface {
 
 	// Returns a generator, done, and error channel.
 	// The generator returns the location for the brick, then the possible locations
-	// of its replicas. The caller must close() the done channel when it no longer
+	// of its replicas. Only devices with all the tags in the selector are
+	// returned. The caller must close() the done channel when it no longer
 	// needs to read from the generator.
-	GetNodes(clusterId, brickId string) (<-chan string,
+	GetNodes(clusterId, brickId string, selector map[string]string) (<-chan string,
 		chan<- struct{}, <-chan error)
 }
@@ -103,7 +103,8 @@ func (d *MockAllocator) RemoveCluster(clusterId string) error {
 	return nil
 }
 
-func (d *MockAllocator) GetNodes(clusterId, brickId string) (<-chan string,
+func (d *MockAllocator) GetNodes(clusterId, brickId string,
+	selector map[string]string) (<-chan string,
 	chan<- struct{}, <-chan error) {
 
 	// Initialize channels
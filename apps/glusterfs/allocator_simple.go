@@ -99,6 +99,7 @@ func (s *SimpleAllocator) AddDevice(cluster *ClusterEntry,
 		zone:     node.Info.Zone,
 		nodeId:   node.Info.Id,
 		deviceId: device.Info.Id,
+		tags:     device.Info.Tags,
 	})
 
 	return nil
@@ -146,7 +147,8 @@ func (s *SimpleAllocator) RemoveCluster(clusterId string) error {
 	return nil
 }
 
-func (s *SimpleAllocator) getDeviceList(clusterId, brickId string) ([]*SimpleDevice, error) {
+func (s *SimpleAllocator) getDeviceList(clusterId, brickId string,
+	selector map[string]string) ([]*SimpleDevice, error) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
@@ -159,11 +161,23 @@ func (s *SimpleAllocator) getDeviceList(clusterId, brickId string) ([]*SimpleDev
 	ring.Rebalance()
 	devicelist := ring.GetDeviceList(brickId)
 
+	// Only keep the devices which match the selector
+	if len(selector) != 0 {
+		matching := make([]*SimpleDevice, 0, len(devicelist))
+		for _, d := range devicelist {
+			if matchesSelector(d.tags, selector) {
+				matching = append(matching, d)
+			}
+		}
+		devicelist = matching
+	}
+
 	return devicelist, nil
 
 }
 
-func (s *SimpleAllocator) GetNodes(clusterId, brickId string) (<-chan string,
+func (s *SimpleAllocator) GetNodes(clusterId, brickId string,
+	selector map[string]string) (<-chan string,
 	chan<- struct{}, <-chan error) {
 
 	// Initialize channels
@@ -174,7 +188,7 @@ func (s *SimpleAllocator) GetNodes(clusterId, brickId string) (<-chan string,
 	errc := make(chan error, 1)
 
 	// Get the list of devices for this brick id
-	devicelist, err := s.getDeviceList(clusterId, brickId)
+	devicelist, err := s.getDeviceList(clusterId, brickId, selector)
 	if err != nil {
 		errc <- err
 		close(device)
@@ -25,6 +25,7 @@ import (
 type SimpleDevice struct {
 	zone             int
 	nodeId, deviceId string
+	tags             map[string]string
 }
 
 // Pretty pring a SimpleDevice
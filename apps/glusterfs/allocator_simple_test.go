@@ -44,7 +44,7 @@ func TestSimpleAllocatorEmpty(t *testing.T) {
 	err = a.RemoveCluster("aaa")
 	tests.Assert(t, err == ErrNotFound)
 
-	ch, _, errc := a.GetNodes(utils.GenUUID(), utils.GenUUID())
+	ch, _, errc := a.GetNodes(utils.GenUUID(), utils.GenUUID(), nil)
 	for d := range ch {
 		tests.Assert(t, false, d)
 	}
@@ -77,7 +77,7 @@ func TestSimpleAllocatorAddRemoveDevice(t *testing.T) {
 	tests.Assert(t, a.rings[cluster.Info.Id] != nil)
 
 	// Get the nodes from the ring
-	ch, _, errc := a.GetNodes(cluster.Info.Id, utils.GenUUID())
+	ch, _, errc := a.GetNodes(cluster.Info.Id, utils.GenUUID(), nil)
 
 	var devices int
 	for d := range ch {
@@ -94,7 +94,7 @@ func TestSimpleAllocatorAddRemoveDevice(t *testing.T) {
 	tests.Assert(t, len(a.rings) == 1)
 
 	// Get the nodes from the ring
-	ch, _, errc = a.GetNodes(cluster.Info.Id, utils.GenUUID())
+	ch, _, errc = a.GetNodes(cluster.Info.Id, utils.GenUUID(), nil)
 
 	devices = 0
 	for d := range ch {
@@ -143,7 +143,7 @@ func TestSimpleAllocatorInitFromDb(t *testing.T) {
 	tests.Assert(t, a != nil)
 
 	// Get the nodes from the ring
-	ch, _, errc := a.GetNodes(clusterId, utils.GenUUID())
+	ch, _, errc := a.GetNodes(clusterId, utils.GenUUID(), nil)
 
 	var devices int
 	for d := range ch {
@@ -155,3 +155,53 @@ func TestSimpleAllocatorInitFromDb(t *testing.T) {
 	tests.Assert(t, err == nil)
 
 }
+
+func TestSimpleAllocatorGetNodesSelector(t *testing.T) {
+	a := NewSimpleAllocator()
+	tests.Assert(t, a != nil)
+
+	cluster := createSampleClusterEntry()
+	node := createSampleNodeEntry()
+	node.Info.ClusterId = cluster.Info.Id
+
+	// Add one ssd device and one untagged device
+	ssd := createSampleDeviceEntry(node.Info.Id, 10000)
+	ssd.Info.Tags = map[string]string{"class": "ssd"}
+	hdd := createSampleDeviceEntry(node.Info.Id, 10000)
+
+	err := a.AddDevice(cluster, node, ssd)
+	tests.Assert(t, err == nil)
+	err = a.AddDevice(cluster, node, hdd)
+	tests.Assert(t, err == nil)
+
+	// Without a selector all devices are returned
+	ch, _, errc := a.GetNodes(cluster.Info.Id, utils.GenUUID(), nil)
+	devices := 0
+	for _ = range ch {
+		devices++
+	}
+	err = <-errc
+	tests.Assert(t, err == nil)
+	tests.Assert(t, devices == 2)
+
+	// Only the ssd device matches
+	ch, _, errc = a.GetNodes(cluster.Info.Id, utils.GenUUID(),
+		map[string]string{"class": "ssd"})
+	devices = 0
+	for d := range ch {
+		devices++
+		tests.Assert(t, d == ssd.Info.Id)
+	}
+	err = <-errc
+	tests.Assert(t, err == nil)
+	tests.Assert(t, devices == 1)
+
+	// No devices match
+	ch, _, errc = a.GetNodes(cluster.Info.Id, utils.GenUUID(),
+		map[string]string{"class": "nvme"})
+	for d := range ch {
+		tests.Assert(t, false, d)
+	}
+	err = <-errc
+	tests.Assert(t, err == nil)
+}
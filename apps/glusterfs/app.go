@@ -23,12 +23,14 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/heketi/heketi/executors"
 	"github.com/heketi/heketi/executors/kubeexec"
+	"github.com/heketi/heketi/executors/localexec"
 	"github.com/heketi/heketi/executors/mockexec"
 	"github.com/heketi/heketi/executors/sshexec"
 	"github.com/heketi/rest"
 	"github.com/heketi/utils"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 )
 
@@ -52,6 +54,11 @@ type App struct {
 	executor     executors.Executor
 	allocator    Allocator
 	conf         *GlusterFSConfig
+	confLock     sync.RWMutex
+
+	// Background tasks
+	stop       chan bool
+	background sync.WaitGroup
 
 	// For testing only.  Keep access to the object
 	// not through the interface
@@ -80,10 +87,16 @@ func NewApp(configIo io.Reader) *App {
 		app.xo, err = mockexec.NewMockExecutor()
 		app.executor = app.xo
 	case app.conf.Executor == "kube" || app.conf.Executor == "kubernetes":
-		app.executor, err = kubeexec.NewKubeExecutor(&app.conf.KubeConfig)
+		// The executor applies the environment to its configuration,
+		// which is kept as read to be compared on reload
+		kubeConfig := app.conf.KubeConfig
+		app.executor, err = kubeexec.NewKubeExecutor(&kubeConfig)
+	case app.conf.Executor == "local":
+		app.executor, err = localexec.NewLocalExecutor(&app.conf.LocalConfig)
 	case app.conf.Executor == "ssh" || app.conf.Executor == "":
 		app.executor, err = sshexec.NewSshExecutor(&app.conf.SshConfig)
 	default:
+		logger.LogError("Unknown executor: %v", app.conf.Executor)
 		return nil
 	}
 	if err != nil {
@@ -100,7 +113,7 @@ func NewApp(configIo io.Reader) *App {
 	// Setup BoltDB database
 	app.db, err = bolt.Open(dbfilename, 0600, &bolt.Options{Timeout: 3 * time.Second})
 	if err != nil {
-		logger.LogError("Unable to open database")
+		logger.LogError("Unable to open database %v: %v", dbfilename, err)
 		return nil
 	}
 
@@ -148,6 +161,13 @@ func NewApp(configIo io.Reader) *App {
 		return nil
 	}
 
+	// Connect to the nodes with their own ssh settings
+	err = app.loadHostConnections()
+	if err != nil {
+		logger.Err(err)
+		return nil
+	}
+
 	// Set advanced settings
 	app.setAdvSettings()
 
@@ -159,16 +179,42 @@ func NewApp(configIo io.Reader) *App {
 		app.conf.Allocator = "simple"
 		app.allocator = NewSimpleAllocatorFromDb(app.db)
 	default:
+		logger.LogError("Unknown allocator: %v", app.conf.Allocator)
 		return nil
 	}
 	logger.Info("Loaded %v allocator", app.conf.Allocator)
 
+	// Start the background tasks
+	app.stop = make(chan bool)
+	app.startDeviceHealthSweep()
+	app.startNodeHeartbeat()
+
 	// Show application has loaded
 	logger.Info("GlusterFS Application Loaded")
 
 	return app
 }
 
+// Calls fn every interval until the application is closed
+func (a *App) runPeriodically(interval time.Duration, fn func()) {
+	a.background.Add(1)
+	go func() {
+		defer a.background.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				fn()
+			case <-a.stop:
+				return
+			}
+		}
+	}()
+}
+
 func (a *App) setLogLevel(level string) {
 	switch level {
 	case "none":
@@ -187,6 +233,14 @@ func (a *App) setLogLevel(level string) {
 }
 
 func (a *App) setAdvSettings() {
+	limitsLock.Lock()
+	defer limitsLock.Unlock()
+
+	a.applyAdvSettings()
+}
+
+// Must be called with limitsLock held
+func (a *App) applyAdvSettings() {
 	if a.conf.BrickMaxNum != 0 {
 		logger.Info("Adv: Max bricks per volume set to %v", a.conf.BrickMaxNum)
 
@@ -221,6 +275,28 @@ func (a *App) SetRoutes(router *mux.Router) error {
 			Pattern:     ASYNC_ROUTE + "/{id:[A-Fa-f0-9]+}",
 			HandlerFunc: a.asyncManager.HandlerStatus},
 
+		// Configuration
+		rest.Route{
+			Name:        "ConfigInfo",
+			Method:      "GET",
+			Pattern:     "/config",
+			HandlerFunc: a.ConfigInfo},
+
+		// Readiness check reaching the nodes.  The checks which
+		// do not are served without authorization.
+		rest.Route{
+			Name:        "Readyz",
+			Method:      "GET",
+			Pattern:     "/readyz",
+			HandlerFunc: a.Readyz},
+
+		// Metrics
+		rest.Route{
+			Name:        "Metrics",
+			Method:      "GET",
+			Pattern:     "/metrics",
+			HandlerFunc: a.Metrics},
+
 		// Cluster
 		rest.Route{
 			Name:        "ClusterCreate",
@@ -242,6 +318,16 @@ func (a *App) SetRoutes(router *mux.Router) error {
 			Method:      "DELETE",
 			Pattern:     "/clusters/{id:[A-Fa-f0-9]+}",
 			HandlerFunc: a.ClusterDelete},
+		rest.Route{
+			Name:        "ClusterSetSettings",
+			Method:      "POST",
+			Pattern:     "/clusters/{id:[A-Fa-f0-9]+}/settings",
+			HandlerFunc: a.ClusterSetSettings},
+		rest.Route{
+			Name:        "ClusterSetLabels",
+			Method:      "POST",
+			Pattern:     "/clusters/{id:[A-Fa-f0-9]+}/labels",
+			HandlerFunc: a.ClusterSetLabels},
 
 		// Node
 		rest.Route{
@@ -259,11 +345,21 @@ func (a *App) SetRoutes(router *mux.Router) error {
 			Method:      "DELETE",
 			Pattern:     "/nodes/{id:[A-Fa-f0-9]+}",
 			HandlerFunc: a.NodeDelete},
+		rest.Route{
+			Name:        "NodeUpdate",
+			Method:      "PATCH",
+			Pattern:     "/nodes/{id:[A-Fa-f0-9]+}",
+			HandlerFunc: a.NodeUpdate},
 		rest.Route{
 			Name:        "NodeSetState",
 			Method:      "POST",
 			Pattern:     "/nodes/{id:[A-Fa-f0-9]+}/state",
 			HandlerFunc: a.NodeSetState},
+		rest.Route{
+			Name:        "NodeSshCheck",
+			Method:      "GET",
+			Pattern:     "/nodes/{id:[A-Fa-f0-9]+}/ssh-check",
+			HandlerFunc: a.NodeSshCheck},
 
 		// Devices
 		rest.Route{
@@ -286,6 +382,26 @@ func (a *App) SetRoutes(router *mux.Router) error {
 			Method:      "POST",
 			Pattern:     "/devices/{id:[A-Fa-f0-9]+}/state",
 			HandlerFunc: a.DeviceSetState},
+		rest.Route{
+			Name:        "DeviceSetTags",
+			Method:      "POST",
+			Pattern:     "/devices/{id:[A-Fa-f0-9]+}/tags",
+			HandlerFunc: a.DeviceSetTags},
+		rest.Route{
+			Name:        "DeviceResync",
+			Method:      "POST",
+			Pattern:     "/devices/{id:[A-Fa-f0-9]+}/resync",
+			HandlerFunc: a.DeviceResync},
+		rest.Route{
+			Name:        "DeviceHealth",
+			Method:      "GET",
+			Pattern:     "/devices/{id:[A-Fa-f0-9]+}/health",
+			HandlerFunc: a.DeviceHealth},
+		rest.Route{
+			Name:        "DeviceUsage",
+			Method:      "GET",
+			Pattern:     "/devices/{id:[A-Fa-f0-9]+}/usage",
+			HandlerFunc: a.DeviceUsage},
 
 		// Volume
 		rest.Route{
@@ -303,6 +419,36 @@ func (a *App) SetRoutes(router *mux.Router) error {
 			Method:      "POST",
 			Pattern:     "/volumes/{id:[A-Fa-f0-9]+}/expand",
 			HandlerFunc: a.VolumeExpand},
+		rest.Route{
+			Name:        "VolumeHealInfo",
+			Method:      "GET",
+			Pattern:     "/volumes/{id:[A-Fa-f0-9]+}/heal",
+			HandlerFunc: a.VolumeHealInfo},
+		rest.Route{
+			Name:        "VolumeHeal",
+			Method:      "POST",
+			Pattern:     "/volumes/{id:[A-Fa-f0-9]+}/heal",
+			HandlerFunc: a.VolumeHeal},
+		rest.Route{
+			Name:        "VolumeRebalanceStatus",
+			Method:      "GET",
+			Pattern:     "/volumes/{id:[A-Fa-f0-9]+}/rebalance",
+			HandlerFunc: a.VolumeRebalanceStatus},
+		rest.Route{
+			Name:        "VolumeRebalance",
+			Method:      "POST",
+			Pattern:     "/volumes/{id:[A-Fa-f0-9]+}/rebalance",
+			HandlerFunc: a.VolumeRebalance},
+		rest.Route{
+			Name:        "VolumeStatus",
+			Method:      "GET",
+			Pattern:     "/volumes/{id:[A-Fa-f0-9]+}/status",
+			HandlerFunc: a.VolumeStatus},
+		rest.Route{
+			Name:        "VolumeUsage",
+			Method:      "GET",
+			Pattern:     "/volumes/{id:[A-Fa-f0-9]+}/usage",
+			HandlerFunc: a.VolumeUsage},
 		rest.Route{
 			Name:        "VolumeDelete",
 			Method:      "DELETE",
@@ -318,12 +464,19 @@ func (a *App) SetRoutes(router *mux.Router) error {
 	// Register all routes from the App
 	for _, route := range routes {
 
+		// Routes which change the state and cannot show their
+		// commands instead refuse dry runs
+		handler := route.HandlerFunc
+		if route.Method != "GET" && !dryRunRoutes[route.Name] {
+			handler = refuseDryRun(handler)
+		}
+
 		// Add routes from the table
 		router.
 			Methods(route.Method).
 			Path(route.Pattern).
 			Name(route.Name).
-			Handler(route.HandlerFunc)
+			Handler(handler)
 
 	}
 
@@ -333,6 +486,10 @@ func (a *App) SetRoutes(router *mux.Router) error {
 
 func (a *App) Close() {
 
+	// Stop the background tasks
+	close(a.stop)
+	a.background.Wait()
+
 	// Close the DB
 	a.db.Close()
 	logger.Info("Closed")
@@ -356,3 +513,17 @@ func (a *App) Auth(w http.ResponseWriter, r *http.Request, next http.HandlerFunc
 	// Everything is clean
 	next(w, r)
 }
+
+// Returns the http status for an error from the executor, so that
+// clients can tell a node which could not be reached from a command
+// which failed on it
+func executorErrorStatus(err error) int {
+	switch {
+	case executors.IsHostUnreachable(err):
+		return http.StatusBadGateway
+	case executors.IsTimeout(err):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
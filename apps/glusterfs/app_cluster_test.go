@@ -383,3 +383,198 @@ func TestClusterDelete(t *testing.T) {
 	tests.Assert(t, err == nil, err)
 
 }
+
+func TestClusterCreateWithSettings(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Bad settings
+	request := []byte(`{
+		"settings" : {
+			"brick_min_size_gb" : 10,
+			"brick_max_size_gb" : 5
+		}
+	}`)
+	r, err := http.Post(ts.URL+"/clusters", "application/json", bytes.NewBuffer(request))
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusBadRequest)
+
+	// Good settings
+	request = []byte(`{
+		"settings" : {
+			"brick_max_size_gb" : 100,
+			"durability" : {
+				"type" : "replicate",
+				"replicate" : {
+					"replica" : 3
+				}
+			}
+		}
+	}`)
+	r, err = http.Post(ts.URL+"/clusters", "application/json", bytes.NewBuffer(request))
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusCreated)
+
+	var msg api.ClusterInfoResponse
+	err = utils.GetJsonFromResponse(r, &msg)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, msg.Settings.BrickMaxSize == 100)
+	tests.Assert(t, msg.Settings.Durability.Type == api.DurabilityReplicate)
+	tests.Assert(t, msg.Settings.Durability.Replicate.Replica == 3)
+
+	// Check the settings were saved
+	err = app.db.View(func(tx *bolt.Tx) error {
+		entry, err := NewClusterEntryFromId(tx, msg.Id)
+		if err != nil {
+			return err
+		}
+		tests.Assert(t, entry.Info.Settings.BrickMaxSize == 100)
+		return nil
+	})
+	tests.Assert(t, err == nil)
+
+	// An empty body is accepted
+	r, err = http.Post(ts.URL+"/clusters", "application/json", nil)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusCreated)
+}
+
+func TestClusterSetSettings(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Create a cluster
+	entry := createSampleClusterEntry()
+	err := app.db.Update(func(tx *bolt.Tx) error {
+		return entry.Save(tx)
+	})
+	tests.Assert(t, err == nil)
+
+	// Unknown cluster
+	request := []byte(`{ "max_bricks_per_volume" : 10 }`)
+	r, err := http.Post(ts.URL+"/clusters/123/settings",
+		"application/json", bytes.NewBuffer(request))
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusNotFound)
+
+	// Bad JSON
+	r, err = http.Post(ts.URL+"/clusters/"+entry.Info.Id+"/settings",
+		"application/json", bytes.NewBuffer([]byte(`{ bad json }`)))
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == 422)
+
+	// Bad durability
+	request = []byte(`{ "durability" : { "type" : "bad" } }`)
+	r, err = http.Post(ts.URL+"/clusters/"+entry.Info.Id+"/settings",
+		"application/json", bytes.NewBuffer(request))
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusBadRequest)
+
+	// Good settings
+	request = []byte(`{ "max_bricks_per_volume" : 10 }`)
+	r, err = http.Post(ts.URL+"/clusters/"+entry.Info.Id+"/settings",
+		"application/json", bytes.NewBuffer(request))
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusOK)
+
+	var msg api.ClusterInfoResponse
+	err = utils.GetJsonFromResponse(r, &msg)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, msg.Id == entry.Info.Id)
+	tests.Assert(t, msg.Settings.BrickMaxNum == 10)
+
+	// Check db
+	err = app.db.View(func(tx *bolt.Tx) error {
+		entry, err := NewClusterEntryFromId(tx, entry.Info.Id)
+		if err != nil {
+			return err
+		}
+		tests.Assert(t, entry.Info.Settings.BrickMaxNum == 10)
+		return nil
+	})
+	tests.Assert(t, err == nil)
+}
+
+func TestClusterSetLabels(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Create a cluster without labels
+	entry := createSampleClusterEntry()
+	err := app.db.Update(func(tx *bolt.Tx) error {
+		return entry.Save(tx)
+	})
+	tests.Assert(t, err == nil)
+
+	// Unknown cluster
+	request := []byte(`{ "labels" : { "purpose" : "block" } }`)
+	r, err := http.Post(ts.URL+"/clusters/123/labels",
+		"application/json", bytes.NewBuffer(request))
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusNotFound)
+
+	// Bad JSON
+	r, err = http.Post(ts.URL+"/clusters/"+entry.Info.Id+"/labels",
+		"application/json", bytes.NewBuffer([]byte(`{ bad json }`)))
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == 422)
+
+	// Empty key
+	r, err = http.Post(ts.URL+"/clusters/"+entry.Info.Id+"/labels",
+		"application/json", bytes.NewBuffer([]byte(`{ "labels" : { "" : "block" } }`)))
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusBadRequest)
+
+	// Good labels
+	r, err = http.Post(ts.URL+"/clusters/"+entry.Info.Id+"/labels",
+		"application/json", bytes.NewBuffer(request))
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusOK)
+
+	var msg api.ClusterInfoResponse
+	err = utils.GetJsonFromResponse(r, &msg)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, msg.Id == entry.Info.Id)
+	tests.Assert(t, msg.Labels["purpose"] == "block")
+
+	// The cluster can now be selected
+	err = app.db.View(func(tx *bolt.Tx) error {
+		entry, err := NewClusterEntryFromId(tx, entry.Info.Id)
+		if err != nil {
+			return err
+		}
+		tests.Assert(t, entry.MatchesSelector(map[string]string{"purpose": "block"}))
+		return nil
+	})
+	tests.Assert(t, err == nil)
+}
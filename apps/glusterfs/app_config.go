@@ -18,25 +18,49 @@ package glusterfs
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
 
+	"github.com/heketi/heketi/executors"
 	"github.com/heketi/heketi/executors/kubeexec"
+	"github.com/heketi/heketi/executors/localexec"
 	"github.com/heketi/heketi/executors/sshexec"
 )
 
 type GlusterFSConfig struct {
-	DBfile     string              `json:"db"`
-	Executor   string              `json:"executor"`
-	Allocator  string              `json:"allocator"`
-	SshConfig  sshexec.SshConfig   `json:"sshexec"`
-	KubeConfig kubeexec.KubeConfig `json:"kubeexec"`
-	Loglevel   string              `json:"loglevel"`
+	DBfile      string                `json:"db"`
+	Executor    string                `json:"executor"`
+	Allocator   string                `json:"allocator"`
+	SshConfig   sshexec.SshConfig     `json:"sshexec"`
+	KubeConfig  kubeexec.KubeConfig   `json:"kubeexec"`
+	LocalConfig localexec.LocalConfig `json:"localexec"`
+	Loglevel    string                `json:"loglevel"`
 
 	// advanced settings
 	BrickMaxSize int `json:"brick_max_size_gb"`
 	BrickMinSize int `json:"brick_min_size_gb"`
 	BrickMaxNum  int `json:"max_bricks_per_volume"`
+
+	// Device health sweep.  Every interval, in seconds, the SMART data
+	// of each online device is read and the device is set offline if
+	// a threshold is exceeded.  Zero disables the sweep or threshold.
+	DeviceHealthInterval  int `json:"device_health_interval"`
+	MaxReallocatedSectors int `json:"max_reallocated_sectors"`
+	MaxPendingSectors     int `json:"max_pending_sectors"`
+
+	// Node heartbeat.  Every interval, in seconds, each node is
+	// checked through the executor and set offline after the given
+	// number of consecutive failures.  Zero disables the heartbeat.
+	NodeHeartbeatInterval int `json:"node_heartbeat_interval"`
+	NodeHeartbeatFailures int `json:"node_heartbeat_failures"`
+
+	// Percent full at which a warning is given for the thin pool
+	// of a brick when its usage is read.  Zero uses the default.
+	ThinPoolWarnPercent int `json:"thin_pool_warn_percent"`
 }
 
 type ConfigFile struct {
@@ -61,3 +85,278 @@ func loadConfiguration(configIo io.Reader) *GlusterFSConfig {
 
 	return &config.GlusterFS
 }
+
+// Checks the configuration for values which would keep the application
+// from starting or working correctly.  All problems found are returned.
+func (c *GlusterFSConfig) Validate() []error {
+	errs := make([]error, 0)
+
+	// Executor
+	switch c.Executor {
+	case "mock", "local":
+	case "kube", "kubernetes":
+		// With the service account the namespace defaults to the one of the pod
+		if c.KubeConfig.Namespace == "" && os.Getenv("HEKETI_KUBE_NAMESPACE") == "" &&
+			!c.KubeConfig.UsesServiceAccount() {
+			errs = append(errs, fmt.Errorf("kubeexec: namespace must be provided"))
+		}
+		if c.KubeConfig.KubeConfigFile != "" {
+			if _, err := os.Stat(c.KubeConfig.KubeConfigFile); err != nil {
+				errs = append(errs, fmt.Errorf("kubeexec: unable to read kubeconfig: %v", err))
+			}
+		}
+		if c.KubeConfig.RetryAttempts < 0 {
+			errs = append(errs, fmt.Errorf("kubeexec: retry_attempts cannot be negative"))
+		}
+		if c.KubeConfig.RetryBackoff < 0 {
+			errs = append(errs, fmt.Errorf("kubeexec: retry_backoff cannot be negative"))
+		}
+	case "ssh", "":
+		if c.SshConfig.PrivateKeyFile == "" {
+			if !c.SshConfig.Agent {
+				errs = append(errs, fmt.Errorf("sshexec: keyfile must be provided"))
+			}
+		} else if fp, err := os.Open(c.SshConfig.PrivateKeyFile); err != nil {
+			errs = append(errs, fmt.Errorf("sshexec: unable to read keyfile: %v", err))
+		} else {
+			fp.Close()
+		}
+		if c.SshConfig.Agent && os.Getenv("SSH_AUTH_SOCK") == "" {
+			errs = append(errs, fmt.Errorf("sshexec: agent needs SSH_AUTH_SOCK to be set"))
+		}
+		if c.SshConfig.KnownHostsFile != "" {
+			if fp, err := os.Open(c.SshConfig.KnownHostsFile); err != nil {
+				errs = append(errs, fmt.Errorf("sshexec: unable to read known_hosts: %v", err))
+			} else {
+				fp.Close()
+			}
+		}
+		if c.SshConfig.Port != "" {
+			if port, err := strconv.Atoi(c.SshConfig.Port); err != nil || port < 1 || port > 65535 {
+				errs = append(errs, fmt.Errorf("sshexec: invalid port %v", c.SshConfig.Port))
+			}
+		}
+		for _, setting := range []struct {
+			name  string
+			value int
+		}{
+			{"max_connections_per_host", c.SshConfig.MaxConnectionsPerHost},
+			{"max_connections", c.SshConfig.MaxConnections},
+			{"device_setup_timeout", c.SshConfig.DeviceSetupTimeout},
+			{"brick_create_timeout", c.SshConfig.BrickCreateTimeout},
+			{"volume_create_timeout", c.SshConfig.VolumeCreateTimeout},
+			{"retry_attempts", c.SshConfig.RetryAttempts},
+			{"retry_backoff", c.SshConfig.RetryBackoff},
+			{"idle_timeout", c.SshConfig.IdleTimeout},
+			{"keepalive_interval", c.SshConfig.KeepaliveInterval},
+		} {
+			if setting.value < 0 {
+				errs = append(errs, fmt.Errorf("sshexec: %v cannot be negative", setting.name))
+			}
+		}
+		format := executors.BrickFormat{
+			MkfsOptions:  c.SshConfig.BrickMkfsOptions,
+			MountOptions: c.SshConfig.BrickMountOptions,
+			ChunkSize:    c.SshConfig.BrickChunkSize,
+			MountRoot:    c.SshConfig.BrickMountRoot,
+		}
+		if err := format.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("sshexec: %v", err))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("Unknown executor: %v", c.Executor))
+	}
+
+	// Allocator
+	switch c.Allocator {
+	case "mock", "simple", "":
+	default:
+		errs = append(errs, fmt.Errorf("Unknown allocator: %v", c.Allocator))
+	}
+
+	// Database directory must exist
+	if c.DBfile != "" {
+		dir := filepath.Dir(c.DBfile)
+		if info, err := os.Stat(dir); err != nil {
+			errs = append(errs, fmt.Errorf("Unable to access db directory %v: %v", dir, err))
+		} else if !info.IsDir() {
+			errs = append(errs, fmt.Errorf("db location %v is not a directory", dir))
+		}
+	}
+
+	return append(errs, c.validateSettings()...)
+}
+
+// Validates the settings which can be changed while running
+func (c *GlusterFSConfig) validateSettings() []error {
+	errs := make([]error, 0)
+
+	switch c.Loglevel {
+	case "none", "critical", "error", "warning", "info", "debug", "":
+	default:
+		errs = append(errs, fmt.Errorf("Unknown loglevel: %v", c.Loglevel))
+	}
+
+	if c.BrickMaxSize < 0 {
+		errs = append(errs, fmt.Errorf("brick_max_size_gb cannot be negative"))
+	}
+	if c.BrickMinSize < 0 {
+		errs = append(errs, fmt.Errorf("brick_min_size_gb cannot be negative"))
+	}
+	if c.BrickMaxNum < 0 {
+		errs = append(errs, fmt.Errorf("max_bricks_per_volume cannot be negative"))
+	}
+
+	// Compare the sizes in KB, using the defaults for those not set
+	maxSize, minSize := defaultBrickMaxSize, defaultBrickMinSize
+	if c.BrickMaxSize > 0 {
+		maxSize = uint64(c.BrickMaxSize) * GB
+	}
+	if c.BrickMinSize > 0 {
+		minSize = uint64(c.BrickMinSize) * GB
+	}
+	if minSize > maxSize {
+		errs = append(errs, fmt.Errorf("brick_min_size_gb (%v GB) must not be larger than "+
+			"brick_max_size_gb (%v GB)", minSize/GB, maxSize/GB))
+	}
+
+	if c.DeviceHealthInterval < 0 {
+		errs = append(errs, fmt.Errorf("device_health_interval cannot be negative"))
+	}
+	if c.MaxReallocatedSectors < 0 {
+		errs = append(errs, fmt.Errorf("max_reallocated_sectors cannot be negative"))
+	}
+	if c.MaxPendingSectors < 0 {
+		errs = append(errs, fmt.Errorf("max_pending_sectors cannot be negative"))
+	}
+	if c.DeviceHealthInterval > 0 && c.MaxReallocatedSectors == 0 && c.MaxPendingSectors == 0 {
+		errs = append(errs, fmt.Errorf("device_health_interval requires "+
+			"max_reallocated_sectors or max_pending_sectors"))
+	}
+
+	if c.NodeHeartbeatInterval < 0 {
+		errs = append(errs, fmt.Errorf("node_heartbeat_interval cannot be negative"))
+	}
+	if c.NodeHeartbeatFailures < 0 {
+		errs = append(errs, fmt.Errorf("node_heartbeat_failures cannot be negative"))
+	}
+
+	if c.ThinPoolWarnPercent < 0 || c.ThinPoolWarnPercent > 100 {
+		errs = append(errs, fmt.Errorf("thin_pool_warn_percent must be between 0 and 100"))
+	}
+
+	return errs
+}
+
+// Reads the configuration, applying any environment overrides, and
+// returns all problems found in the GlusterFS application settings.
+func ValidateConfiguration(configIo io.Reader) []error {
+	config := loadConfiguration(configIo)
+	if config == nil {
+		return []error{fmt.Errorf("Unable to parse glusterfs configuration")}
+	}
+
+	return config.Validate()
+}
+
+// Reads the configuration again and applies the settings which can be
+// changed while the server is running: log level and limits.  Settings
+// which require a restart are logged and left unchanged.  If the new
+// configuration is not valid, no settings are changed.
+func (a *App) Reload(configIo io.Reader) error {
+	conf := loadConfiguration(configIo)
+	if conf == nil {
+		return fmt.Errorf("Unable to parse configuration")
+	}
+
+	a.confLock.Lock()
+	defer a.confLock.Unlock()
+
+	// Keep the settings which cannot be changed
+	if conf.DBfile != a.conf.DBfile {
+		logger.LogError("Unable to change db from %v to %v without a restart",
+			a.conf.DBfile, conf.DBfile)
+		conf.DBfile = a.conf.DBfile
+	}
+	if conf.Executor != a.conf.Executor {
+		logger.LogError("Unable to change executor from %v to %v without a restart",
+			a.conf.Executor, conf.Executor)
+		conf.Executor = a.conf.Executor
+	}
+	if conf.Allocator == "" {
+		conf.Allocator = "simple"
+	}
+	if conf.Allocator != a.conf.Allocator {
+		logger.LogError("Unable to change allocator from %v to %v without a restart",
+			a.conf.Allocator, conf.Allocator)
+		conf.Allocator = a.conf.Allocator
+	}
+	if conf.SshConfig != a.conf.SshConfig {
+		logger.LogError("Unable to change sshexec settings without a restart")
+		conf.SshConfig = a.conf.SshConfig
+	}
+	if conf.KubeConfig != a.conf.KubeConfig {
+		logger.LogError("Unable to change kubeexec settings without a restart")
+		conf.KubeConfig = a.conf.KubeConfig
+	}
+	if conf.LocalConfig != a.conf.LocalConfig {
+		logger.LogError("Unable to change localexec settings without a restart")
+		conf.LocalConfig = a.conf.LocalConfig
+	}
+	if conf.DeviceHealthInterval != a.conf.DeviceHealthInterval {
+		logger.LogError("Unable to change device_health_interval without a restart")
+		conf.DeviceHealthInterval = a.conf.DeviceHealthInterval
+	}
+	if conf.NodeHeartbeatInterval != a.conf.NodeHeartbeatInterval {
+		logger.LogError("Unable to change node_heartbeat_interval without a restart")
+		conf.NodeHeartbeatInterval = a.conf.NodeHeartbeatInterval
+	}
+
+	if errs := conf.validateSettings(); len(errs) != 0 {
+		for _, err := range errs {
+			logger.LogError("%v", err)
+		}
+		return fmt.Errorf("Invalid configuration, no settings changed")
+	}
+
+	// Apply the new settings
+	a.conf = conf
+	a.setLogLevel(conf.Loglevel)
+
+	limitsLock.Lock()
+	defer limitsLock.Unlock()
+	resetLimits()
+	a.applyAdvSettings()
+
+	logger.Info("Configuration reloaded")
+
+	return nil
+}
+
+// Returns the configuration currently in use
+func (a *App) ConfigInfo(w http.ResponseWriter, r *http.Request) {
+	a.confLock.RLock()
+	conf := *a.conf
+	a.confLock.RUnlock()
+
+	// Do not show secrets
+	if conf.KubeConfig.Password != "" {
+		conf.KubeConfig.Password = "********"
+	}
+	if conf.KubeConfig.Token != "" {
+		conf.KubeConfig.Token = "********"
+	}
+
+	// Show the limits in use
+	limitsLock.RLock()
+	conf.BrickMaxNum = BrickMaxNum
+	conf.BrickMaxSize = int(BrickMaxSize / GB)
+	conf.BrickMinSize = int(BrickMinSize / GB)
+	limitsLock.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(conf); err != nil {
+		panic(err)
+	}
+}
@@ -22,6 +22,7 @@ import (
 
 	"github.com/boltdb/bolt"
 	"github.com/gorilla/mux"
+	"github.com/heketi/heketi/executors"
 	"github.com/heketi/heketi/pkg/glusterfs/api"
 	"github.com/heketi/utils"
 )
@@ -40,10 +41,21 @@ func (a *App) DeviceAdd(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "no devices added", http.StatusBadRequest)
 		return
 	}
+	for key := range msg.Tags {
+		if key == "" {
+			http.Error(w, "Device tag keys cannot be empty", http.StatusBadRequest)
+			return
+		}
+	}
 
 	// Create device entry
 	device := NewDeviceEntryFromRequest(&msg)
 
+	if isDryRun(r) {
+		a.deviceAddDryRun(w, device)
+		return
+	}
+
 	// Check the node is in the db
 	var node *NodeEntry
 	err = a.db.Update(func(tx *bolt.Tx) error {
@@ -318,6 +330,157 @@ func (a *App) DeviceDelete(w http.ResponseWriter, r *http.Request) {
 
 }
 
+func (a *App) DeviceSetTags(w http.ResponseWriter, r *http.Request) {
+	// Get the id from the URL
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	// Unmarshal JSON
+	var msg api.DeviceTagsRequest
+	err := utils.GetJsonFromRequest(r, &msg)
+	if err != nil {
+		http.Error(w, "request unable to be parsed", 422)
+		return
+	}
+
+	// Set tags
+	var info *api.DeviceInfoResponse
+	err = a.db.Update(func(tx *bolt.Tx) error {
+		device, err := NewDeviceEntryFromId(tx, id)
+		if err == ErrNotFound {
+			http.Error(w, "Id not found", http.StatusNotFound)
+			return err
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		err = device.SetTags(tx, a.allocator, msg.Tags)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return err
+		}
+
+		// Save new tags
+		err = device.Save(tx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		info, err = device.NewInfoResponse(tx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	// Write msg
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(info); err != nil {
+		panic(err)
+	}
+}
+
+func (a *App) DeviceResync(w http.ResponseWriter, r *http.Request) {
+	// Get the id from the URL
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	// Get device and node information
+	var (
+		device *DeviceEntry
+		node   *NodeEntry
+	)
+	err := a.db.View(func(tx *bolt.Tx) error {
+		var err error
+		device, err = NewDeviceEntryFromId(tx, id)
+		if err == ErrNotFound {
+			http.Error(w, "Id not found", http.StatusNotFound)
+			return err
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		node, err = NewNodeEntryFromId(tx, device.NodeId)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	// Read the new size of the device from the node
+	logger.Info("Resyncing device %v on node %v", device.Info.Name, device.NodeId)
+	deviceInfo, err := a.executor.DeviceResync(node.ManageHostName(),
+		device.Info.Name, device.Info.Id)
+	if err != nil {
+		logger.Err(err)
+		http.Error(w, err.Error(), executorErrorStatus(err))
+		return
+	}
+
+	// Reconcile the storage with the bricks on the device
+	msg := &api.DeviceResyncResponse{
+		Id: id,
+	}
+	err = a.db.Update(func(tx *bolt.Tx) error {
+		// Bricks may have been added while the node was accessed
+		device, err := NewDeviceEntryFromId(tx, id)
+		if err == ErrNotFound {
+			http.Error(w, "Id not found", http.StatusNotFound)
+			return err
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		msg.Previous = device.Info.Storage
+		err = device.StorageResync(tx, deviceInfo.Size)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return err
+		}
+		device.SetExtentSize(deviceInfo.ExtentSize)
+		msg.Current = device.Info.Storage
+
+		err = device.Save(tx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		logger.Err(err)
+		return
+	}
+
+	if msg.Previous != msg.Current {
+		logger.Info("Device %v storage changed from %+v to %+v",
+			id, msg.Previous, msg.Current)
+	}
+
+	// Write msg
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(msg); err != nil {
+		panic(err)
+	}
+}
+
 func (a *App) DeviceSetState(w http.ResponseWriter, r *http.Request) {
 	// Get the id from the URL
 	vars := mux.Vars(r)
@@ -362,3 +525,37 @@ func (a *App) DeviceSetState(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// Returns the commands which would set up the device on its node
+func (a *App) deviceAddDryRun(w http.ResponseWriter, device *DeviceEntry) {
+	var node *NodeEntry
+	err := a.db.View(func(tx *bolt.Tx) error {
+		var err error
+		node, err = NewNodeEntryFromId(tx, device.NodeId)
+		if err == ErrNotFound {
+			http.Error(w, "Node id does not exist", http.StatusNotFound)
+			return err
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	a.dryRun(w, func(db *bolt.DB, executor executors.Executor, allocator Allocator) error {
+		err := db.Update(func(tx *bolt.Tx) error {
+			return device.Register(tx)
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = executor.DeviceSetup(node.ManageHostName(),
+			device.Info.Name, device.Info.Id)
+		return err
+	})
+}
@@ -0,0 +1,197 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package glusterfs
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/mux"
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+)
+
+// Returns the SMART health of the device as read from its node
+func (a *App) DeviceHealth(w http.ResponseWriter, r *http.Request) {
+	// Get the id from the URL
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	// Get device and node information
+	var (
+		device *DeviceEntry
+		node   *NodeEntry
+	)
+	err := a.db.View(func(tx *bolt.Tx) error {
+		var err error
+		device, err = NewDeviceEntryFromId(tx, id)
+		if err == ErrNotFound {
+			http.Error(w, "Id not found", http.StatusNotFound)
+			return err
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		node, err = NewNodeEntryFromId(tx, device.NodeId)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	health, err := a.executor.DeviceHealth(node.ManageHostName(), device.Info.Name)
+	if err != nil {
+		logger.Err(err)
+		http.Error(w, err.Error(), executorErrorStatus(err))
+		return
+	}
+
+	msg := &api.DeviceHealthResponse{
+		Id:                 id,
+		Passed:             health.Passed,
+		ReallocatedSectors: health.ReallocatedSectors,
+		PendingSectors:     health.PendingSectors,
+		Temperature:        health.Temperature,
+	}
+
+	// Write msg
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(msg); err != nil {
+		panic(err)
+	}
+}
+
+// Starts a goroutine which checks the health of the devices
+// periodically, if it has been enabled in the configuration
+func (a *App) startDeviceHealthSweep() {
+	if a.conf.DeviceHealthInterval <= 0 {
+		return
+	}
+
+	interval := time.Duration(a.conf.DeviceHealthInterval) * time.Second
+	logger.Info("Checking device health every %v", interval)
+
+	a.runPeriodically(interval, a.checkDeviceHealth)
+}
+
+// Reads the health of every online device on an online node and sets
+// the device offline if it has crossed any of the configured thresholds
+func (a *App) checkDeviceHealth() {
+	a.confLock.RLock()
+	maxReallocated := uint64(a.conf.MaxReallocatedSectors)
+	maxPending := uint64(a.conf.MaxPendingSectors)
+	a.confLock.RUnlock()
+
+	// Get the devices to check
+	type deviceHost struct {
+		id, name, host string
+	}
+	devices := make([]deviceHost, 0)
+	err := a.db.View(func(tx *bolt.Tx) error {
+		list, err := DeviceList(tx)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range list {
+			device, err := NewDeviceEntryFromId(tx, id)
+			if err != nil {
+				return err
+			}
+			if !device.isOnline() {
+				continue
+			}
+
+			node, err := NewNodeEntryFromId(tx, device.NodeId)
+			if err != nil {
+				return err
+			}
+			if !node.isOnline() {
+				continue
+			}
+
+			devices = append(devices, deviceHost{
+				id:   id,
+				name: device.Info.Name,
+				host: node.ManageHostName(),
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		logger.Err(err)
+		return
+	}
+
+	for _, d := range devices {
+		health, err := a.executor.DeviceHealth(d.host, d.name)
+		if err != nil {
+			logger.Warning("Unable to read health of device %v on %v: %v",
+				d.name, d.host, err)
+			continue
+		}
+
+		if !deviceHealthExceeded(health, maxReallocated, maxPending) {
+			continue
+		}
+
+		logger.Warning("Device %v on %v has %v reallocated and %v pending sectors. "+
+			"Setting it offline", d.name, d.host,
+			health.ReallocatedSectors, health.PendingSectors)
+
+		err = a.db.Update(func(tx *bolt.Tx) error {
+			device, err := NewDeviceEntryFromId(tx, d.id)
+			if err != nil {
+				return err
+			}
+
+			err = device.SetState(tx, a.allocator, api.EntryStateOffline)
+			if err != nil {
+				return err
+			}
+
+			return device.Save(tx)
+		})
+		if err != nil {
+			logger.LogError("Unable to set device %v offline: %v", d.id, err)
+		}
+	}
+}
+
+// A threshold of zero is not checked
+func deviceHealthExceeded(health *executors.DeviceHealthInfo,
+	maxReallocated, maxPending uint64) bool {
+
+	if maxReallocated > 0 && health.ReallocatedSectors > maxReallocated {
+		return true
+	}
+	if maxPending > 0 && health.PendingSectors > maxPending {
+		return true
+	}
+
+	return false
+}
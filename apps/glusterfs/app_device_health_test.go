@@ -0,0 +1,227 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package glusterfs
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/mux"
+	client "github.com/heketi/heketi/client/api/go-client"
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+	"github.com/heketi/tests"
+)
+
+func TestDeviceHealth(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Create a client
+	c := client.NewClientNoAuth(ts.URL)
+	tests.Assert(t, c != nil)
+
+	err := setupSampleDbWithTopology(app,
+		1,      // clusters
+		1,      // nodes_per_cluster
+		1,      // devices_per_node,
+		500*GB, // disksize
+	)
+	tests.Assert(t, err == nil)
+
+	var device *DeviceEntry
+	err = app.db.View(func(tx *bolt.Tx) error {
+		devices, err := DeviceList(tx)
+		if err != nil {
+			return err
+		}
+		device, err = NewDeviceEntryFromId(tx, devices[0])
+		return err
+	})
+	tests.Assert(t, err == nil)
+
+	// Unknown device
+	_, err = c.DeviceHealth("123")
+	tests.Assert(t, err != nil)
+
+	app.xo.MockDeviceHealth = func(host, name string) (*executors.DeviceHealthInfo, error) {
+		tests.Assert(t, name == device.Info.Name)
+		return &executors.DeviceHealthInfo{
+			Passed:             true,
+			ReallocatedSectors: 2,
+			PendingSectors:     1,
+			Temperature:        35,
+		}, nil
+	}
+
+	info, err := c.DeviceHealth(device.Info.Id)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, info.Id == device.Info.Id)
+	tests.Assert(t, info.Passed)
+	tests.Assert(t, info.ReallocatedSectors == 2)
+	tests.Assert(t, info.PendingSectors == 1)
+	tests.Assert(t, info.Temperature == 35)
+
+	// SMART data cannot be read
+	app.xo.MockDeviceHealth = func(host, name string) (*executors.DeviceHealthInfo, error) {
+		return nil, errors.New("smartctl not found")
+	}
+
+	_, err = c.DeviceHealth(device.Info.Id)
+	tests.Assert(t, err != nil)
+}
+
+func TestDeviceHealthSweep(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+
+	err := setupSampleDbWithTopology(app,
+		1,      // clusters
+		2,      // nodes_per_cluster
+		2,      // devices_per_node,
+		500*GB, // disksize
+	)
+	tests.Assert(t, err == nil)
+
+	var devices []string
+	err = app.db.View(func(tx *bolt.Tx) error {
+		var err error
+		devices, err = DeviceList(tx)
+		return err
+	})
+	tests.Assert(t, err == nil)
+
+	// Get device names
+	bad := make(map[string]bool)
+	err = app.db.View(func(tx *bolt.Tx) error {
+		d, err := NewDeviceEntryFromId(tx, devices[0])
+		if err != nil {
+			return err
+		}
+		bad[d.Info.Name] = true
+		return nil
+	})
+	tests.Assert(t, err == nil)
+
+	// One device has too many pending sectors, another fails to answer
+	checked := 0
+	app.xo.MockDeviceHealth = func(host, name string) (*executors.DeviceHealthInfo, error) {
+		checked++
+		if bad[name] {
+			return &executors.DeviceHealthInfo{
+				Passed:         true,
+				PendingSectors: 20,
+			}, nil
+		}
+		if checked == 2 {
+			return nil, errors.New("unreachable")
+		}
+		return &executors.DeviceHealthInfo{
+			Passed:             true,
+			ReallocatedSectors: 20,
+		}, nil
+	}
+
+	// Only pending sectors are checked
+	app.conf.MaxPendingSectors = 10
+	app.checkDeviceHealth()
+	tests.Assert(t, checked == 4, checked)
+
+	err = app.db.View(func(tx *bolt.Tx) error {
+		for _, id := range devices {
+			d, err := NewDeviceEntryFromId(tx, id)
+			if err != nil {
+				return err
+			}
+			if id == devices[0] {
+				tests.Assert(t, d.State == api.EntryStateOffline)
+			} else {
+				tests.Assert(t, d.State == api.EntryStateOnline)
+			}
+		}
+		return nil
+	})
+	tests.Assert(t, err == nil)
+
+	// Offline devices are not checked again
+	checked = 0
+	app.checkDeviceHealth()
+	tests.Assert(t, checked == 3, checked)
+}
+
+func TestDeviceHealthSweepInterval(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app without the sweep, so the mock can be set up
+	// before anything calls it
+	app := NewApp(bytes.NewBuffer([]byte(`{
+		"glusterfs" : {
+			"executor" : "mock",
+			"allocator" : "simple",
+			"db" : "` + tmpfile + `",
+			"max_reallocated_sectors" : 10
+		}
+	}`)))
+	tests.Assert(t, app != nil)
+
+	err := setupSampleDbWithTopology(app,
+		1,      // clusters
+		1,      // nodes_per_cluster
+		1,      // devices_per_node,
+		500*GB, // disksize
+	)
+	tests.Assert(t, err == nil)
+
+	checked := make(chan bool, 10)
+	app.xo.MockDeviceHealth = func(host, name string) (*executors.DeviceHealthInfo, error) {
+		checked <- true
+		return &executors.DeviceHealthInfo{Passed: true}, nil
+	}
+
+	// Check the devices every second
+	app.conf.DeviceHealthInterval = 1
+	app.startDeviceHealthSweep()
+
+	select {
+	case <-checked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Device health was not checked")
+	}
+
+	// Close must stop the sweep
+	app.Close()
+}
@@ -685,3 +685,179 @@ func TestDeviceState(t *testing.T) {
 	tests.Assert(t, info.Storage.Used == device.Storage.Used)
 	tests.Assert(t, info.Storage.Total == device.Storage.Total)
 }
+
+func TestDeviceSetTags(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Create a client
+	c := client.NewClientNoAuth(ts.URL)
+	tests.Assert(t, c != nil)
+
+	// Create Cluster
+	cluster, err := c.ClusterCreate()
+	tests.Assert(t, err == nil)
+
+	// Create Node
+	nodeReq := &api.NodeAddRequest{
+		Zone:      1,
+		ClusterId: cluster.Id,
+	}
+	nodeReq.Hostnames.Manage = sort.StringSlice{"manage.host"}
+	nodeReq.Hostnames.Storage = sort.StringSlice{"storage.host"}
+	node, err := c.NodeAdd(nodeReq)
+	tests.Assert(t, err == nil)
+
+	// Add device with a bad tag
+	deviceReq := &api.DeviceAddRequest{}
+	deviceReq.Name = "/dev/fake1"
+	deviceReq.NodeId = node.Id
+	deviceReq.Tags = map[string]string{"": "ssd"}
+	err = c.DeviceAdd(deviceReq)
+	tests.Assert(t, err != nil)
+
+	// Add device with tags
+	deviceReq.Tags = map[string]string{"class": "hdd"}
+	err = c.DeviceAdd(deviceReq)
+	tests.Assert(t, err == nil)
+
+	node, err = c.NodeInfo(node.Id)
+	tests.Assert(t, err == nil)
+	deviceId := node.DevicesInfo[0].Id
+	device, err := c.DeviceInfo(deviceId)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, device.Tags["class"] == "hdd")
+
+	// Unknown device
+	err = c.DeviceSetTags("123", &api.DeviceTagsRequest{})
+	tests.Assert(t, err != nil)
+
+	// Bad JSON
+	r, err := http.Post(ts.URL+"/devices/"+deviceId+"/tags",
+		"application/json", bytes.NewBuffer([]byte(`{ bad json }`)))
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == 422)
+
+	// Change tags
+	err = c.DeviceSetTags(deviceId, &api.DeviceTagsRequest{
+		Tags: map[string]string{"class": "ssd"},
+	})
+	tests.Assert(t, err == nil)
+
+	device, err = c.DeviceInfo(deviceId)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, device.Tags["class"] == "ssd")
+
+	// The allocator has the new tags
+	ch, _, errc := app.allocator.GetNodes(cluster.Id, utils.GenUUID(),
+		map[string]string{"class": "ssd"})
+	devices := 0
+	for d := range ch {
+		devices++
+		tests.Assert(t, d == deviceId)
+	}
+	err = <-errc
+	tests.Assert(t, err == nil)
+	tests.Assert(t, devices == 1)
+}
+
+func TestDeviceResync(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Create a client
+	c := client.NewClientNoAuth(ts.URL)
+	tests.Assert(t, c != nil)
+
+	// Create a cluster with one device of 500GB and a volume on it
+	err := setupSampleDbWithTopology(app,
+		1,      // clusters
+		1,      // nodes_per_cluster
+		1,      // devices_per_node,
+		500*GB, // disksize
+	)
+	tests.Assert(t, err == nil)
+
+	v := createSampleVolumeEntry(100)
+	v.Info.Durability.Type = api.DurabilityDistributeOnly
+	err = v.Create(app.db, app.executor, app.allocator)
+	tests.Assert(t, err == nil)
+
+	var deviceId string
+	var before api.StorageSize
+	err = app.db.View(func(tx *bolt.Tx) error {
+		devices, err := DeviceList(tx)
+		if err != nil {
+			return err
+		}
+		device, err := NewDeviceEntryFromId(tx, devices[0])
+		if err != nil {
+			return err
+		}
+		deviceId = device.Info.Id
+		before = device.Info.Storage
+		return nil
+	})
+	tests.Assert(t, err == nil)
+	tests.Assert(t, before.Used > 0)
+
+	// Unknown device
+	_, err = c.DeviceResync("123")
+	tests.Assert(t, err != nil)
+
+	// Device has grown to 1TB
+	app.xo.MockDeviceResync = func(host, device, vgid string) (*executors.DeviceInfo, error) {
+		tests.Assert(t, vgid == deviceId)
+		d := &executors.DeviceInfo{}
+		d.Size = 1 * TB
+		d.ExtentSize = 4096
+		return d, nil
+	}
+
+	info, err := c.DeviceResync(deviceId)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, info.Id == deviceId)
+	tests.Assert(t, info.Previous == before)
+	tests.Assert(t, info.Current.Total == 1*TB)
+	tests.Assert(t, info.Current.Used == before.Used)
+	tests.Assert(t, info.Current.Free == 1*TB-before.Used)
+
+	device, err := c.DeviceInfo(deviceId)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, device.Storage == info.Current)
+
+	// Device has shrunk below the size of its bricks
+	app.xo.MockDeviceResync = func(host, device, vgid string) (*executors.DeviceInfo, error) {
+		d := &executors.DeviceInfo{}
+		d.Size = 10 * GB
+		d.ExtentSize = 4096
+		return d, nil
+	}
+
+	_, err = c.DeviceResync(deviceId)
+	tests.Assert(t, err != nil)
+
+	device, err = c.DeviceInfo(deviceId)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, device.Storage == info.Current)
+}
@@ -0,0 +1,149 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package glusterfs
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/executors/dryrunexec"
+	"github.com/heketi/heketi/executors/kubeexec"
+	"github.com/heketi/heketi/executors/sshexec"
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+)
+
+// Routes which can return the commands they would run
+var dryRunRoutes = map[string]bool{
+	"DeviceAdd":    true,
+	"VolumeCreate": true,
+	"VolumeExpand": true,
+	"VolumeDelete": true,
+}
+
+// Refuses dry run requests for routes which would otherwise
+// carry out the operation
+func refuseDryRun(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if isDryRun(r) {
+			http.Error(w, "Dry run is not supported for this request",
+				http.StatusBadRequest)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// Returns true if the request only asks for the commands
+// which would be run
+func isDryRun(r *http.Request) bool {
+	dryrun, err := strconv.ParseBool(r.URL.Query().Get("dryrun"))
+	return err == nil && dryrun
+}
+
+// Runs the operation on a copy of the database with an executor which
+// records the commands instead of running them, and writes the commands
+// to the response.  Nothing is changed in the database or on the nodes.
+func (a *App) dryRun(w http.ResponseWriter,
+	operation func(db *bolt.DB, executor executors.Executor, allocator Allocator) error) {
+
+	// Copy the database
+	fp, err := ioutil.TempFile("", "heketi-dryrun")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	dbfile := fp.Name()
+	fp.Close()
+	defer os.Remove(dbfile)
+
+	err = a.db.View(func(tx *bolt.Tx) error {
+		return tx.CopyFile(dbfile, 0600)
+	})
+	if err != nil {
+		logger.Err(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	db, err := bolt.Open(dbfile, 0600, &bolt.Options{Timeout: 3 * time.Second})
+	if err != nil {
+		logger.Err(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	a.confLock.RLock()
+	var allocator Allocator
+	if a.conf.Allocator == "mock" {
+		allocator = NewMockAllocator(db)
+	} else {
+		allocator = NewSimpleAllocatorFromDb(db)
+	}
+
+	// Only the ssh executor uses the ssh configuration
+	var (
+		fstab     string
+		sshConfig *sshexec.SshConfig
+	)
+	switch a.conf.Executor {
+	case "kube", "kubernetes":
+		fstab = a.conf.KubeConfig.Fstab
+		if k, ok := a.executor.(*kubeexec.KubeExecutor); ok {
+			fstab = k.Fstab
+		}
+	case "local":
+		fstab = a.conf.LocalConfig.Fstab
+	default:
+		config := a.conf.SshConfig
+		sshConfig = &config
+		fstab = config.Fstab
+	}
+	a.confLock.RUnlock()
+	executor := dryrunexec.NewDryRunExecutor(fstab, sshConfig)
+
+	// Run the operation
+	err = operation(db, executor, allocator)
+	if err != nil {
+		logger.LogError("Dry run failed: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	msg := &api.DryRunResponse{
+		Commands: make([]api.HostCommands, 0),
+	}
+	for _, host := range executor.Plan() {
+		msg.Commands = append(msg.Commands, api.HostCommands{
+			Host:     host.Host,
+			Commands: host.Commands,
+		})
+	}
+
+	// Write msg
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(msg); err != nil {
+		panic(err)
+	}
+}
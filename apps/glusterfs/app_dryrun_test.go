@@ -0,0 +1,312 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package glusterfs
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/mux"
+	client "github.com/heketi/heketi/client/api/go-client"
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+	"github.com/heketi/tests"
+)
+
+// Returns true if any command in the plan contains s
+func planContains(plan *api.DryRunResponse, s string) bool {
+	for _, host := range plan.Commands {
+		for _, command := range host.Commands {
+			if strings.Contains(command, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestVolumeCreateDryRun(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Create a client
+	c := client.NewClientNoAuth(ts.URL)
+	tests.Assert(t, c != nil)
+
+	err := setupSampleDbWithTopology(app,
+		1,    // clusters
+		4,    // nodes_per_cluster
+		2,    // devices_per_node,
+		5*TB, // disksize
+	)
+	tests.Assert(t, err == nil)
+
+	// The executor must not be used
+	app.xo.MockBrickCreate = func(host string,
+		brick *executors.BrickRequest) (*executors.BrickInfo, error) {
+		t.Error("brick created during dry run")
+		return nil, nil
+	}
+
+	req := &api.VolumeCreateRequest{}
+	req.Size = 100
+	req.Durability.Type = api.DurabilityReplicate
+	req.Durability.Replicate.Replica = 3
+
+	plan, err := c.VolumeCreateDryRun(req)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(plan.Commands) > 0)
+	tests.Assert(t, planContains(plan, "lvcreate"))
+	tests.Assert(t, planContains(plan, "mkfs.xfs"))
+	tests.Assert(t, planContains(plan, "volume create"))
+	tests.Assert(t, planContains(plan, "volume start"))
+	for _, host := range plan.Commands {
+		tests.Assert(t, strings.HasPrefix(host.Host, "manage"), host.Host)
+	}
+
+	// Nothing was saved
+	list, err := c.VolumeList()
+	tests.Assert(t, err == nil)
+	tests.Assert(t, len(list.Volumes) == 0)
+	err = app.db.View(func(tx *bolt.Tx) error {
+		devices, err := DeviceList(tx)
+		tests.Assert(t, err == nil)
+		for _, id := range devices {
+			device, err := NewDeviceEntryFromId(tx, id)
+			tests.Assert(t, err == nil)
+			tests.Assert(t, len(device.Bricks) == 0)
+			tests.Assert(t, device.Info.Storage.Used == 0)
+		}
+		return nil
+	})
+	tests.Assert(t, err == nil)
+
+	// Requests are still checked
+	req.Size = 0
+	_, err = c.VolumeCreateDryRun(req)
+	tests.Assert(t, err != nil)
+	tests.Assert(t, strings.Contains(err.Error(), "Invalid volume size"))
+}
+
+func TestVolumeExpandDeleteDryRun(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Create a client
+	c := client.NewClientNoAuth(ts.URL)
+	tests.Assert(t, c != nil)
+
+	err := setupSampleDbWithTopology(app,
+		1,    // clusters
+		4,    // nodes_per_cluster
+		2,    // devices_per_node,
+		5*TB, // disksize
+	)
+	tests.Assert(t, err == nil)
+
+	v := createSampleVolumeEntry(100)
+	err = v.Create(app.db, app.executor, app.allocator)
+	tests.Assert(t, err == nil)
+
+	// Expand, with the settings of the configuration
+	app.conf.SshConfig.RebalanceOnExpansion = true
+	app.conf.SshConfig.BrickMountRoot = "/bricks"
+	plan, err := c.VolumeExpandDryRun(v.Info.Id, &api.VolumeExpandRequest{
+		Size: 50,
+	})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, planContains(plan, "lvcreate"))
+	tests.Assert(t, planContains(plan, "volume add-brick "+v.Info.Name))
+	tests.Assert(t, planContains(plan, "volume rebalance "+v.Info.Name+" start"))
+	tests.Assert(t, planContains(plan, "/bricks/vg_"))
+
+	info, err := c.VolumeInfo(v.Info.Id)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, info.Size == 100)
+	tests.Assert(t, len(info.Bricks) == len(v.Bricks))
+
+	// Delete
+	plan, err = c.VolumeDeleteDryRun(v.Info.Id)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, planContains(plan, "volume stop "+v.Info.Name))
+	tests.Assert(t, planContains(plan, "volume delete "+v.Info.Name))
+	tests.Assert(t, planContains(plan, "lvremove"))
+
+	info, err = c.VolumeInfo(v.Info.Id)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, info.Id == v.Info.Id)
+
+	// Unknown volume
+	_, err = c.VolumeDeleteDryRun("123")
+	tests.Assert(t, err != nil)
+}
+
+func TestDeviceAddDryRun(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Create a client
+	c := client.NewClientNoAuth(ts.URL)
+	tests.Assert(t, c != nil)
+
+	err := setupSampleDbWithTopology(app,
+		1,    // clusters
+		1,    // nodes_per_cluster
+		1,    // devices_per_node,
+		5*TB, // disksize
+	)
+	tests.Assert(t, err == nil)
+
+	var node *NodeEntry
+	err = app.db.View(func(tx *bolt.Tx) error {
+		var err error
+		nodes := EntryKeys(tx, BOLTDB_BUCKET_NODE)
+		node, err = NewNodeEntryFromId(tx, nodes[0])
+		return err
+	})
+	tests.Assert(t, err == nil)
+
+	req := &api.DeviceAddRequest{}
+	req.Name = "/dev/sdz"
+	req.NodeId = node.Info.Id
+
+	plan, err := c.DeviceAddDryRun(req)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(plan.Commands) == 1)
+	tests.Assert(t, plan.Commands[0].Host == node.ManageHostName())
+	tests.Assert(t, planContains(plan, "pvcreate --metadatasize=128M --dataalignment=256K /dev/sdz"))
+	tests.Assert(t, planContains(plan, "vgcreate"))
+
+	// The device was not added
+	info, err := c.NodeInfo(node.Info.Id)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, len(info.DevicesInfo) == 1)
+
+	// A device already on the node cannot be added
+	err = c.DeviceAdd(req)
+	tests.Assert(t, err == nil)
+	_, err = c.DeviceAddDryRun(req)
+	tests.Assert(t, err != nil)
+
+	// Unknown node
+	req.NodeId = "123"
+	_, err = c.DeviceAddDryRun(req)
+	tests.Assert(t, err != nil)
+}
+
+func TestDryRunUnsupported(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	err := setupSampleDbWithTopology(app,
+		1,    // clusters
+		1,    // nodes_per_cluster
+		1,    // devices_per_node,
+		5*TB, // disksize
+	)
+	tests.Assert(t, err == nil)
+
+	var nodeId, deviceId string
+	err = app.db.View(func(tx *bolt.Tx) error {
+		nodeId = EntryKeys(tx, BOLTDB_BUCKET_NODE)[0]
+		deviceId = EntryKeys(tx, BOLTDB_BUCKET_DEVICE)[0]
+		return nil
+	})
+	tests.Assert(t, err == nil)
+
+	// Routes which change the state without a dry run are refused
+	for _, request := range []struct {
+		method, path, body string
+	}{
+		{"POST", "/nodes", `{"cluster": "123", "zone": 1}`},
+		{"DELETE", "/nodes/" + nodeId, ""},
+		{"POST", "/nodes/" + nodeId + "/state", `{"state": "offline"}`},
+		{"DELETE", "/devices/" + deviceId, ""},
+		{"POST", "/devices/" + deviceId + "/state", `{"state": "offline"}`},
+	} {
+		req, err := http.NewRequest(request.method, ts.URL+request.path+"?dryrun=true",
+			bytes.NewBufferString(request.body))
+		tests.Assert(t, err == nil)
+		r, err := http.DefaultClient.Do(req)
+		tests.Assert(t, err == nil)
+		tests.Assert(t, r.StatusCode == http.StatusBadRequest,
+			request.method, request.path, r.StatusCode)
+	}
+
+	// Nothing was changed
+	err = app.db.View(func(tx *bolt.Tx) error {
+		node, err := NewNodeEntryFromId(tx, nodeId)
+		tests.Assert(t, err == nil)
+		tests.Assert(t, node.isOnline())
+
+		device, err := NewDeviceEntryFromId(tx, deviceId)
+		tests.Assert(t, err == nil)
+		tests.Assert(t, device.isOnline())
+		return nil
+	})
+	tests.Assert(t, err == nil)
+
+	// Reading is not affected
+	r, err := http.Get(ts.URL + "/nodes/" + nodeId + "?dryrun=true")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusOK)
+}
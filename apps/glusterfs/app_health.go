@@ -0,0 +1,222 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package glusterfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/mux"
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+)
+
+// Liveness check.  Only shows that the server is able to answer.
+func (a *App) Healthz(w http.ResponseWriter, r *http.Request) {
+	health := &api.HealthResponse{
+		Healthy: true,
+		Checks:  make([]api.HealthCheck, 0),
+	}
+
+	writeHealthResponse(w, health)
+}
+
+// Readiness check.  Verifies that the database and allocator are usable.
+// If the query parameter 'executor' is set to true, it also verifies
+// that at least one node per cluster can be reached by the executor.
+// Reaching the nodes runs commands on them, so that check is only
+// served behind authorization.
+func (a *App) Readyz(w http.ResponseWriter, r *http.Request) {
+	health := &api.HealthResponse{
+		Healthy: true,
+		Checks:  make([]api.HealthCheck, 0),
+	}
+
+	health.Checks = append(health.Checks, a.checkDb())
+	health.Checks = append(health.Checks, a.checkAllocator())
+
+	if readyzChecksExecutor(r) {
+		health.Checks = append(health.Checks, a.checkExecutor()...)
+	}
+
+	for _, check := range health.Checks {
+		if !check.Healthy {
+			health.Healthy = false
+		}
+	}
+
+	writeHealthResponse(w, health)
+}
+
+func readyzChecksExecutor(r *http.Request) bool {
+	checkExecutor, _ := strconv.ParseBool(r.URL.Query().Get("executor"))
+	return checkExecutor
+}
+
+// Matches the readiness checks which can be served without
+// authorization, those which do not reach the nodes
+func ReadyzWithoutExecutor(r *http.Request, rm *mux.RouteMatch) bool {
+	return !readyzChecksExecutor(r)
+}
+
+func writeHealthResponse(w http.ResponseWriter, health *api.HealthResponse) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if health.Healthy {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(health); err != nil {
+		panic(err)
+	}
+}
+
+func (a *App) checkDb() api.HealthCheck {
+	check := api.HealthCheck{
+		Name: "db",
+	}
+
+	// Make sure the file has not been removed from under us
+	if _, err := os.Stat(a.db.Path()); err != nil {
+		check.Message = err.Error()
+		return check
+	}
+
+	// Check all the buckets can be read
+	err := a.db.View(func(tx *bolt.Tx) error {
+		for _, bucket := range []string{
+			BOLTDB_BUCKET_CLUSTER,
+			BOLTDB_BUCKET_NODE,
+			BOLTDB_BUCKET_VOLUME,
+			BOLTDB_BUCKET_DEVICE,
+			BOLTDB_BUCKET_BRICK,
+		} {
+			if tx.Bucket([]byte(bucket)) == nil {
+				return fmt.Errorf("Unable to access bucket %v", bucket)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		check.Message = err.Error()
+		return check
+	}
+
+	check.Healthy = true
+	return check
+}
+
+func (a *App) checkAllocator() api.HealthCheck {
+	check := api.HealthCheck{
+		Name: "allocator",
+	}
+
+	if a.allocator == nil {
+		check.Message = "Allocator not loaded"
+		return check
+	}
+
+	check.Healthy = true
+	return check
+}
+
+// Returns a check for each cluster.  A cluster is considered
+// reachable if any of its online nodes answers the executor.
+// A cluster without nodes has nothing to reach and is not failed,
+// while one whose nodes are all offline cannot be used.
+func (a *App) checkExecutor() []api.HealthCheck {
+
+	// Get the manage hostnames of the online nodes in each cluster
+	var clusters []string
+	hosts := make(map[string][]string)
+	empty := make(map[string]bool)
+	err := a.db.View(func(tx *bolt.Tx) error {
+		var err error
+		clusters, err = ClusterList(tx)
+		if err != nil {
+			return err
+		}
+
+		for _, clusterId := range clusters {
+			cluster, err := NewClusterEntryFromId(tx, clusterId)
+			if err != nil {
+				return err
+			}
+
+			hosts[clusterId] = make([]string, 0)
+			empty[clusterId] = len(cluster.Info.Nodes) == 0
+			for _, nodeId := range cluster.Info.Nodes {
+				node, err := NewNodeEntryFromId(tx, nodeId)
+				if err != nil {
+					return err
+				}
+
+				if node.isOnline() {
+					hosts[clusterId] = append(hosts[clusterId], node.ManageHostName())
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return []api.HealthCheck{
+			api.HealthCheck{
+				Name:    "executor",
+				Message: err.Error(),
+			},
+		}
+	}
+
+	checks := make([]api.HealthCheck, 0)
+	for _, clusterId := range clusters {
+		check := api.HealthCheck{
+			Name: "executor:" + clusterId,
+		}
+
+		if empty[clusterId] {
+			check.Healthy = true
+			check.Message = "No nodes in cluster"
+			checks = append(checks, check)
+			continue
+		}
+		if len(hosts[clusterId]) == 0 {
+			check.Message = "No online nodes in cluster"
+			checks = append(checks, check)
+			continue
+		}
+
+		for _, host := range hosts[clusterId] {
+			err := a.executor.Ping(host)
+			if err == nil {
+				check.Healthy = true
+				check.Message = ""
+				break
+			}
+			logger.Warning("Node %v in cluster %v not reachable: %v",
+				host, clusterId, err)
+			check.Message = "No nodes in cluster reachable"
+		}
+
+		checks = append(checks, check)
+	}
+
+	return checks
+}
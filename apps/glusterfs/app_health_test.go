@@ -0,0 +1,336 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package glusterfs
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/mux"
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+	"github.com/heketi/tests"
+	"github.com/heketi/utils"
+)
+
+func setupHealthServer(app *App) *httptest.Server {
+	router := mux.NewRouter()
+	router.Methods("GET").Path("/healthz").HandlerFunc(app.Healthz)
+	router.Methods("GET").Path("/readyz").HandlerFunc(app.Readyz)
+
+	return httptest.NewServer(router)
+}
+
+func TestAppHealthz(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+
+	// Setup the server
+	ts := setupHealthServer(app)
+	defer ts.Close()
+
+	r, err := http.Get(ts.URL + "/healthz")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusOK)
+	tests.Assert(t, r.Header.Get("Content-Type") == "application/json; charset=UTF-8")
+
+	var msg api.HealthResponse
+	err = utils.GetJsonFromResponse(r, &msg)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, msg.Healthy)
+}
+
+func TestAppReadyz(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+
+	// Setup the server
+	ts := setupHealthServer(app)
+	defer ts.Close()
+
+	r, err := http.Get(ts.URL + "/readyz")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusOK)
+
+	var msg api.HealthResponse
+	err = utils.GetJsonFromResponse(r, &msg)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, msg.Healthy)
+	tests.Assert(t, len(msg.Checks) == 2)
+	tests.Assert(t, msg.Checks[0].Name == "db")
+	tests.Assert(t, msg.Checks[0].Healthy)
+	tests.Assert(t, msg.Checks[1].Name == "allocator")
+	tests.Assert(t, msg.Checks[1].Healthy)
+
+	// Remove the allocator
+	app.allocator = nil
+	r, err = http.Get(ts.URL + "/readyz")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusServiceUnavailable)
+
+	err = utils.GetJsonFromResponse(r, &msg)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, !msg.Healthy)
+	tests.Assert(t, !msg.Checks[1].Healthy)
+	tests.Assert(t, msg.Checks[1].Message != "")
+}
+
+func TestAppReadyzExecutor(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+
+	// Setup the server
+	ts := setupHealthServer(app)
+	defer ts.Close()
+
+	// Create two clusters with three nodes each
+	err := setupSampleDbWithTopology(app,
+		2,      // clusters
+		3,      // nodes_per_cluster
+		1,      // devices_per_node,
+		500*GB, // disksize
+	)
+	tests.Assert(t, err == nil)
+
+	// All nodes answer
+	pinged := 0
+	app.xo.MockPing = func(host string) error {
+		pinged++
+		return nil
+	}
+
+	r, err := http.Get(ts.URL + "/readyz?executor=true")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusOK)
+
+	var msg api.HealthResponse
+	err = utils.GetJsonFromResponse(r, &msg)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, msg.Healthy)
+	tests.Assert(t, len(msg.Checks) == 4)
+	for _, check := range msg.Checks[2:] {
+		tests.Assert(t, strings.HasPrefix(check.Name, "executor:"))
+		tests.Assert(t, check.Healthy)
+	}
+
+	// Only one ping per cluster is needed
+	tests.Assert(t, pinged == 2, pinged)
+
+	// Only one node in each cluster answers
+	pinged = 0
+	app.xo.MockPing = func(host string) error {
+		pinged++
+		if pinged%3 != 0 {
+			return errors.New("unreachable")
+		}
+		return nil
+	}
+
+	r, err = http.Get(ts.URL + "/readyz?executor=true")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusOK)
+	tests.Assert(t, pinged == 6, pinged)
+
+	// No nodes answer
+	app.xo.MockPing = func(host string) error {
+		return errors.New("unreachable")
+	}
+
+	r, err = http.Get(ts.URL + "/readyz?executor=true")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusServiceUnavailable)
+
+	err = utils.GetJsonFromResponse(r, &msg)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, !msg.Healthy)
+	for _, check := range msg.Checks[2:] {
+		tests.Assert(t, !check.Healthy)
+	}
+
+	// Without the parameter the executor is not used
+	r, err = http.Get(ts.URL + "/readyz")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusOK)
+}
+
+func TestAppReadyzExecutorNoOnlineNodes(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+
+	// Setup the server
+	ts := setupHealthServer(app)
+	defer ts.Close()
+
+	// Create a cluster with two nodes
+	err := setupSampleDbWithTopology(app,
+		1,      // clusters
+		2,      // nodes_per_cluster
+		1,      // devices_per_node,
+		500*GB, // disksize
+	)
+	tests.Assert(t, err == nil)
+
+	pinged := 0
+	app.xo.MockPing = func(host string) error {
+		pinged++
+		return nil
+	}
+
+	// Set all the nodes offline
+	setNodesState := func(state api.EntryState) {
+		err := app.db.Update(func(tx *bolt.Tx) error {
+			clusters, err := ClusterList(tx)
+			if err != nil {
+				return err
+			}
+			for _, clusterId := range clusters {
+				cluster, err := NewClusterEntryFromId(tx, clusterId)
+				if err != nil {
+					return err
+				}
+				for _, id := range cluster.Info.Nodes {
+					node, err := NewNodeEntryFromId(tx, id)
+					if err != nil {
+						return err
+					}
+					node.State = state
+					err = node.Save(tx)
+					if err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+		tests.Assert(t, err == nil)
+	}
+	setNodesState(api.EntryStateOffline)
+
+	r, err := http.Get(ts.URL + "/readyz?executor=true")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusServiceUnavailable)
+
+	var msg api.HealthResponse
+	err = utils.GetJsonFromResponse(r, &msg)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, !msg.Healthy)
+	tests.Assert(t, len(msg.Checks) == 3)
+	tests.Assert(t, !msg.Checks[2].Healthy)
+	tests.Assert(t, msg.Checks[2].Message == "No online nodes in cluster")
+	tests.Assert(t, pinged == 0, pinged)
+
+	// A cluster without nodes does not fail the check
+	setNodesState(api.EntryStateOnline)
+
+	cluster := NewClusterEntryFromRequest()
+	err = app.db.Update(func(tx *bolt.Tx) error {
+		return cluster.Save(tx)
+	})
+	tests.Assert(t, err == nil)
+
+	r, err = http.Get(ts.URL + "/readyz?executor=true")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusOK)
+
+	err = utils.GetJsonFromResponse(r, &msg)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, msg.Healthy)
+	tests.Assert(t, len(msg.Checks) == 4)
+	found := false
+	for _, check := range msg.Checks[2:] {
+		tests.Assert(t, check.Healthy)
+		if check.Name == "executor:"+cluster.Info.Id {
+			tests.Assert(t, check.Message == "No nodes in cluster")
+			found = true
+		}
+	}
+	tests.Assert(t, found)
+}
+
+func TestAppReadyzExecutorAuthorized(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+
+	// Route as the server does, with the executor check behind
+	// the authorization
+	router := mux.NewRouter()
+	router.Methods("GET").Path("/readyz").
+		MatcherFunc(ReadyzWithoutExecutor).HandlerFunc(app.Readyz)
+
+	appRouter := mux.NewRouter()
+	app.SetRoutes(appRouter)
+	authorized := 0
+	router.NewRoute().HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorized++
+		appRouter.ServeHTTP(w, r)
+	})
+
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	pinged := 0
+	app.xo.MockPing = func(host string) error {
+		pinged++
+		return nil
+	}
+
+	err := setupSampleDbWithTopology(app,
+		1,      // clusters
+		1,      // nodes_per_cluster
+		1,      // devices_per_node,
+		500*GB, // disksize
+	)
+	tests.Assert(t, err == nil)
+
+	for _, path := range []string{"/readyz", "/readyz?executor=false"} {
+		r, err := http.Get(ts.URL + path)
+		tests.Assert(t, err == nil)
+		tests.Assert(t, r.StatusCode == http.StatusOK)
+	}
+	tests.Assert(t, authorized == 0, authorized)
+	tests.Assert(t, pinged == 0, pinged)
+
+	r, err := http.Get(ts.URL + "/readyz?executor=true")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusOK)
+	tests.Assert(t, authorized == 1, authorized)
+	tests.Assert(t, pinged == 1, pinged)
+}
@@ -0,0 +1,48 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package glusterfs
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/heketi/heketi/executors"
+)
+
+// Shows the counters of the executors in the Prometheus text format
+func (a *App) Metrics(w http.ResponseWriter, r *http.Request) {
+	retries := executors.GetRetryMetrics()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.WriteHeader(http.StatusOK)
+	for _, metric := range []struct {
+		name  string
+		help  string
+		value uint64
+	}{
+		{"heketi_executor_retries_total",
+			"Commands tried again after a transient failure", retries.Retries},
+		{"heketi_executor_retries_recovered_total",
+			"Commands which succeeded after being tried again", retries.Recovered},
+		{"heketi_executor_retries_exhausted_total",
+			"Commands which still failed after being tried again", retries.Exhausted},
+	} {
+		fmt.Fprintf(w, "# HELP %v %v\n", metric.name, metric.help)
+		fmt.Fprintf(w, "# TYPE %v counter\n", metric.name)
+		fmt.Fprintf(w, "%v %v\n", metric.name, metric.value)
+	}
+}
@@ -18,6 +18,7 @@ package glusterfs
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/boltdb/bolt"
@@ -60,6 +61,10 @@ func (a *App) NodeAdd(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	if !validSshPort(msg.SshPort) {
+		http.Error(w, "Invalid ssh port "+msg.SshPort, http.StatusBadRequest)
+		return
+	}
 
 	// Create a node entry
 	node := NewNodeEntryFromRequest(&msg)
@@ -103,6 +108,7 @@ func (a *App) NodeAdd(w http.ResponseWriter, r *http.Request) {
 
 	// Add node
 	logger.Info("Adding node %v", node.ManageHostName())
+	a.setHostConnection(node)
 	a.asyncManager.AsyncHttpRedirectFunc(w, r, func() (seeother string, e error) {
 
 		// Cleanup in case of failure
@@ -112,6 +118,7 @@ func (a *App) NodeAdd(w http.ResponseWriter, r *http.Request) {
 					node.Deregister(tx)
 					return nil
 				})
+				a.clearHostConnection(node.ManageHostName())
 			}
 		}()
 
@@ -316,6 +323,8 @@ func (a *App) NodeDelete(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			return "", err
 		}
+		a.clearHostConnection(node.ManageHostName())
+
 		// Show that the key has been deleted
 		logger.Info("Deleted node [%s]", id)
 
@@ -324,6 +333,162 @@ func (a *App) NodeDelete(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func (a *App) NodeUpdate(w http.ResponseWriter, r *http.Request) {
+	// Get the id from the URL
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	// Unmarshal JSON
+	var msg api.NodeUpdateRequest
+	err := utils.GetJsonFromRequest(r, &msg)
+	if err != nil {
+		http.Error(w, "request unable to be parsed", 422)
+		return
+	}
+
+	// Check for correct values
+	if msg.Zone < 0 {
+		http.Error(w, "Zone cannot be negative", http.StatusBadRequest)
+		return
+	}
+	if msg.SshPort != api.NodeSshDefault && !validSshPort(msg.SshPort) {
+		http.Error(w, "Invalid ssh port "+msg.SshPort, http.StatusBadRequest)
+		return
+	}
+	for _, name := range append(msg.Hostnames.Manage, msg.Hostnames.Storage...) {
+		if name == "" {
+			http.Error(w, "Hostname cannot be an empty string", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Register the new hostnames and get a peer node
+	var (
+		node, updated *NodeEntry
+		peer_node     *NodeEntry
+	)
+	err = a.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		node, err = NewNodeEntryFromId(tx, id)
+		if err == ErrNotFound {
+			http.Error(w, "Id not found", http.StatusNotFound)
+			return err
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		// Existing bricks would no longer match their gluster names
+		if node.changesStorageHostnames(&msg) {
+			bricks, err := node.hasBricks(tx)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return err
+			}
+			if bricks {
+				err = fmt.Errorf("Unable to change the storage hostnames of node %v "+
+					"while it has bricks", id)
+				http.Error(w, err.Error(), http.StatusConflict)
+				return err
+			}
+		}
+
+		updated, err = NewNodeEntryFromId(tx, id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+		updated.applyUpdate(&msg)
+
+		// Hostnames used by other nodes are rejected and the
+		// transaction is rolled back
+		err = node.Deregister(tx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+		err = updated.Register(tx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return err
+		}
+
+		// Get another node in the cluster to execute the Gluster
+		// peer command
+		cluster, err := NewClusterEntryFromId(tx, node.Info.ClusterId)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+		for _, nodeId := range cluster.Info.Nodes {
+			if nodeId != id {
+				peer_node, err = NewNodeEntryFromId(tx, nodeId)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return err
+				}
+				break
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	// Update node
+	logger.Info("Updating node %v", id)
+	a.asyncManager.AsyncHttpRedirectFunc(w, r, func() (seeother string, e error) {
+
+		// Restore the previous hostnames in case of failure
+		defer func() {
+			if e != nil {
+				a.db.Update(func(tx *bolt.Tx) error {
+					updated.Deregister(tx)
+					node.Register(tx)
+					return nil
+				})
+			}
+		}()
+
+		// Let the peers know the new storage address
+		if peer_node != nil && updated.StorageHostName() != node.StorageHostName() {
+			err := a.executor.PeerProbe(peer_node.ManageHostName(), updated.StorageHostName())
+			if err != nil {
+				return "", err
+			}
+		}
+
+		// Save the new values
+		err := a.db.Update(func(tx *bolt.Tx) error {
+			node, err := NewNodeEntryFromId(tx, id)
+			if err != nil {
+				return err
+			}
+
+			err = node.Update(tx, a.allocator, &msg)
+			if err != nil {
+				return err
+			}
+
+			return node.Save(tx)
+		})
+		if err != nil {
+			return "", err
+		}
+
+		// Connect to the node with its new settings
+		if updated.ManageHostName() != node.ManageHostName() {
+			a.clearHostConnection(node.ManageHostName())
+		}
+		a.setHostConnection(updated)
+
+		logger.Info("Updated node %v", id)
+		return "/nodes/" + id, nil
+	})
+}
+
 func (a *App) NodeSetState(w http.ResponseWriter, r *http.Request) {
 	// Get the id from the URL
 	vars := mux.Vars(r)
@@ -337,6 +502,53 @@ func (a *App) NodeSetState(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Get the volumes on the node to check their self-heal status
+	// before and after maintenance
+	var (
+		node    *NodeEntry
+		volumes []*VolumeEntry
+	)
+	err = a.db.View(func(tx *bolt.Tx) error {
+		var err error
+		node, err = NewNodeEntryFromId(tx, id)
+		if err == ErrNotFound {
+			http.Error(w, "Id not found", http.StatusNotFound)
+			return err
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		if enteringMaintenance(node.State, msg.State) ||
+			leavingMaintenance(node.State, msg.State) {
+			volumes, err = node.Volumes(tx)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	// Do not allow maintenance while data is being healed.  An offline
+	// node cannot be asked, and the bricks of its volumes on other nodes
+	// cannot heal until it is back, so it is not checked.
+	if enteringMaintenance(node.State, msg.State) {
+		err = a.checkVolumesHealed(node.ManageHostName(), volumes)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+	} else if node.State == api.EntryStateOffline &&
+		msg.State == api.EntryStateMaintenance {
+		logger.Info("Node %v is offline, self-heal not checked before maintenance",
+			node.ManageHostName())
+	}
+
 	// Check state is supported
 	err = a.db.Update(func(tx *bolt.Tx) error {
 		node, err := NewNodeEntryFromId(tx, id)
@@ -354,6 +566,7 @@ func (a *App) NodeSetState(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return err
 		}
+		node.AutoOffline = false
 
 		// Save new state
 		err = node.Save(tx)
@@ -367,4 +580,51 @@ func (a *App) NodeSetState(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		return
 	}
+
+	// Show what still needs to be healed after maintenance.  Self-heal
+	// catches up in the background, so the node is not kept out.
+	if leavingMaintenance(node.State, msg.State) {
+		err = a.checkVolumesHealed(node.ManageHostName(), volumes)
+		if err != nil {
+			logger.Warning("Node %v left maintenance: %v", node.ManageHostName(), err)
+		} else {
+			logger.Info("Node %v left maintenance with all volumes healed",
+				node.ManageHostName())
+		}
+	}
+}
+
+func enteringMaintenance(from, to api.EntryState) bool {
+	return from == api.EntryStateOnline && to == api.EntryStateMaintenance
+}
+
+func leavingMaintenance(from, to api.EntryState) bool {
+	return from == api.EntryStateMaintenance && to == api.EntryStateOnline
+}
+
+// Returns an error if any of the volumes with redundancy has
+// entries pending heal or bricks which cannot be reached
+func (a *App) checkVolumesHealed(host string, volumes []*VolumeEntry) error {
+	for _, volume := range volumes {
+		if volume.Info.Durability.Type == api.DurabilityDistributeOnly {
+			continue
+		}
+
+		info, err := a.executor.VolumeHealInfo(host, volume.Info.Name)
+		if err != nil {
+			return err
+		}
+
+		pending, complete := info.Pending()
+		if !complete {
+			return fmt.Errorf("Volume %v has bricks which are not connected",
+				volume.Info.Name)
+		}
+		if pending > 0 {
+			return fmt.Errorf("Volume %v has %v entries pending heal",
+				volume.Info.Name, pending)
+		}
+	}
+
+	return nil
 }
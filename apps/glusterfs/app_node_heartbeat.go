@@ -0,0 +1,140 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package glusterfs
+
+import (
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+)
+
+const (
+	defaultNodeHeartbeatFailures = 3
+)
+
+// Starts a goroutine which checks that the nodes can be reached
+// periodically, if it has been enabled in the configuration
+func (a *App) startNodeHeartbeat() {
+	if a.conf.NodeHeartbeatInterval <= 0 {
+		return
+	}
+
+	interval := time.Duration(a.conf.NodeHeartbeatInterval) * time.Second
+	logger.Info("Checking nodes every %v", interval)
+
+	a.runPeriodically(interval, a.checkNodeHeartbeat)
+}
+
+// Pings every node which is not failed and records the result
+func (a *App) checkNodeHeartbeat() {
+	a.confLock.RLock()
+	maxFailures := a.conf.NodeHeartbeatFailures
+	a.confLock.RUnlock()
+	if maxFailures == 0 {
+		maxFailures = defaultNodeHeartbeatFailures
+	}
+
+	// Get the nodes to check
+	hosts := make(map[string]string)
+	err := a.db.View(func(tx *bolt.Tx) error {
+		clusters, err := ClusterList(tx)
+		if err != nil {
+			return err
+		}
+
+		for _, clusterId := range clusters {
+			cluster, err := NewClusterEntryFromId(tx, clusterId)
+			if err != nil {
+				return err
+			}
+
+			for _, nodeId := range cluster.Info.Nodes {
+				node, err := NewNodeEntryFromId(tx, nodeId)
+				if err != nil {
+					return err
+				}
+
+				if node.State != api.EntryStateFailed {
+					hosts[nodeId] = node.ManageHostName()
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		logger.Err(err)
+		return
+	}
+
+	// Ping the nodes in parallel so that unreachable
+	// nodes do not delay the others
+	var (
+		lock      sync.Mutex
+		wg        sync.WaitGroup
+		reachable = make(map[string]bool)
+	)
+	for nodeId, host := range hosts {
+		wg.Add(1)
+		go func(nodeId, host string) {
+			defer wg.Done()
+
+			err := a.executor.Ping(host)
+			if err != nil {
+				logger.Warning("Heartbeat to node %v failed: %v", host, err)
+			}
+
+			// A node which ran the command is up, even
+			// if the command failed on it
+			lock.Lock()
+			reachable[nodeId] = err == nil || executors.IsCommandFailed(err)
+			lock.Unlock()
+		}(nodeId, host)
+	}
+	wg.Wait()
+
+	// Save the results
+	err = a.db.Update(func(tx *bolt.Tx) error {
+		for nodeId, ok := range reachable {
+			node, err := NewNodeEntryFromId(tx, nodeId)
+			if err == ErrNotFound {
+				// Deleted while it was being checked
+				continue
+			} else if err != nil {
+				return err
+			}
+
+			err = node.Heartbeat(tx, a.allocator, ok, maxFailures)
+			if err != nil {
+				return err
+			}
+
+			err = node.Save(tx)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		logger.Err(err)
+	}
+}
@@ -0,0 +1,168 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package glusterfs
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+	"github.com/heketi/tests"
+	"github.com/heketi/utils"
+)
+
+func TestNodeHeartbeat(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	app.conf.NodeHeartbeatFailures = 2
+
+	err := setupSampleDbWithTopology(app,
+		1,      // clusters
+		3,      // nodes_per_cluster
+		1,      // devices_per_node,
+		500*GB, // disksize
+	)
+	tests.Assert(t, err == nil)
+
+	// Find one node to fail
+	var (
+		clusterId string
+		deadNode  *NodeEntry
+	)
+	err = app.db.View(func(tx *bolt.Tx) error {
+		clusters, err := ClusterList(tx)
+		if err != nil {
+			return err
+		}
+		clusterId = clusters[0]
+
+		cluster, err := NewClusterEntryFromId(tx, clusterId)
+		if err != nil {
+			return err
+		}
+
+		deadNode, err = NewNodeEntryFromId(tx, cluster.Info.Nodes[0])
+		return err
+	})
+	tests.Assert(t, err == nil)
+
+	var lock sync.Mutex
+	pinged := 0
+	dead := true
+	var pingErr error = &executors.HostUnreachableError{
+		Host: deadNode.ManageHostName(),
+		Err:  errors.New("connection refused"),
+	}
+	app.xo.MockPing = func(host string) error {
+		lock.Lock()
+		defer lock.Unlock()
+		pinged++
+		if dead && host == deadNode.ManageHostName() {
+			return pingErr
+		}
+		return nil
+	}
+
+	// Count the devices the allocator can use
+	ringDevices := func() int {
+		ch, _, errc := app.allocator.GetNodes(clusterId, utils.GenUUID(), nil)
+		devices := 0
+		for range ch {
+			devices++
+		}
+		tests.Assert(t, <-errc == nil)
+		return devices
+	}
+	tests.Assert(t, ringDevices() == 3)
+
+	nodeState := func() *NodeEntry {
+		var node *NodeEntry
+		err := app.db.View(func(tx *bolt.Tx) error {
+			var err error
+			node, err = NewNodeEntryFromId(tx, deadNode.Info.Id)
+			return err
+		})
+		tests.Assert(t, err == nil)
+		return node
+	}
+
+	// First failure
+	app.checkNodeHeartbeat()
+	tests.Assert(t, pinged == 3, pinged)
+	node := nodeState()
+	tests.Assert(t, node.State == api.EntryStateOnline)
+	tests.Assert(t, node.HeartbeatFailures == 1)
+	tests.Assert(t, ringDevices() == 3)
+
+	// Second failure sets the node offline
+	app.checkNodeHeartbeat()
+	node = nodeState()
+	tests.Assert(t, node.State == api.EntryStateOffline)
+	tests.Assert(t, node.HeartbeatFailures == 2)
+	tests.Assert(t, ringDevices() == 2)
+
+	// Node comes back
+	dead = false
+	app.checkNodeHeartbeat()
+	node = nodeState()
+	tests.Assert(t, node.State == api.EntryStateOnline)
+	tests.Assert(t, node.HeartbeatFailures == 0)
+	tests.Assert(t, !node.LastSeen.IsZero())
+	tests.Assert(t, ringDevices() == 3)
+
+	// A node which answers but fails the command is still up
+	dead = true
+	pingErr = &executors.CommandFailedError{
+		Host: deadNode.ManageHostName(),
+		Result: executors.CommandResult{
+			Command:    "sudo true",
+			ExitStatus: 1,
+		},
+	}
+	app.checkNodeHeartbeat()
+	app.checkNodeHeartbeat()
+	node = nodeState()
+	tests.Assert(t, node.State == api.EntryStateOnline)
+	tests.Assert(t, node.HeartbeatFailures == 0)
+	tests.Assert(t, ringDevices() == 3)
+
+	// Failed nodes are not checked
+	err = app.db.Update(func(tx *bolt.Tx) error {
+		node, err := NewNodeEntryFromId(tx, deadNode.Info.Id)
+		if err != nil {
+			return err
+		}
+		err = node.SetState(tx, app.allocator, api.EntryStateFailed)
+		if err != nil {
+			return err
+		}
+		return node.Save(tx)
+	})
+	tests.Assert(t, err == nil)
+
+	pinged = 0
+	app.checkNodeHeartbeat()
+	tests.Assert(t, pinged == 2, pinged)
+}
@@ -0,0 +1,129 @@
+//
+// Copyright (c) 2015 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package glusterfs
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/mux"
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+)
+
+// Checks that the node can be reached and that commands
+// can be run on it with sudo
+func (a *App) NodeSshCheck(w http.ResponseWriter, r *http.Request) {
+	// Get the id from the URL
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var node *NodeEntry
+	err := a.db.View(func(tx *bolt.Tx) error {
+		var err error
+		node, err = NewNodeEntryFromId(tx, id)
+		if err == ErrNotFound {
+			http.Error(w, "Id not found", http.StatusNotFound)
+			return err
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	msg := &api.NodeSshCheckResponse{
+		Id:   id,
+		Host: node.ManageHostName(),
+	}
+
+	err = a.executor.Ping(msg.Host)
+	switch {
+	case err == nil:
+		msg.Reachable = true
+		msg.Sudo = true
+	case executors.IsCommandFailed(err) || executors.IsTimeout(err):
+		// Connected, but sudo failed or waited for a password
+		msg.Reachable = true
+	}
+	if err != nil {
+		logger.Warning("Check of node %v failed: %v", msg.Host, err)
+		msg.Error = err.Error()
+	}
+
+	// Write msg
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(msg); err != nil {
+		panic(err)
+	}
+}
+
+// Gives the executor the ssh user and port of the node
+func (a *App) setHostConnection(node *NodeEntry) {
+	a.executor.SetHostConnection(node.ManageHostName(), executors.HostConnection{
+		User: node.Info.SshUser,
+		Port: node.Info.SshPort,
+	})
+}
+
+// Makes the executor use its configured user and port for the host
+func (a *App) clearHostConnection(host string) {
+	a.executor.SetHostConnection(host, executors.HostConnection{})
+}
+
+// Gives the executor the ssh user and port of every node
+func (a *App) loadHostConnections() error {
+	return a.db.View(func(tx *bolt.Tx) error {
+		clusters, err := ClusterList(tx)
+		if err != nil {
+			return err
+		}
+
+		for _, clusterId := range clusters {
+			cluster, err := NewClusterEntryFromId(tx, clusterId)
+			if err != nil {
+				return err
+			}
+
+			for _, nodeId := range cluster.Info.Nodes {
+				node, err := NewNodeEntryFromId(tx, nodeId)
+				if err != nil {
+					return err
+				}
+				a.setHostConnection(node)
+			}
+		}
+
+		return nil
+	})
+}
+
+// An empty port uses the one in the configuration
+func validSshPort(port string) bool {
+	if port == "" {
+		return true
+	}
+	value, err := strconv.Atoi(port)
+	return err == nil && value > 0 && value <= 65535
+}
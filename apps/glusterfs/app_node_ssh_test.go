@@ -0,0 +1,226 @@
+//
+// Copyright (c) 2015 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package glusterfs
+
+import (
+	"errors"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+	client "github.com/heketi/heketi/client/api/go-client"
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+	"github.com/heketi/tests"
+)
+
+func TestNodeSshSettings(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Create a client
+	c := client.NewClientNoAuth(ts.URL)
+	tests.Assert(t, c != nil)
+
+	// Settings given to the executor
+	var lock sync.Mutex
+	hosts := make(map[string]executors.HostConnection)
+	app.xo.MockSetHostConnection = func(host string, conn executors.HostConnection) {
+		lock.Lock()
+		defer lock.Unlock()
+		if conn.User == "" && conn.Port == "" {
+			delete(hosts, host)
+		} else {
+			hosts[host] = conn
+		}
+	}
+
+	cluster, err := c.ClusterCreate()
+	tests.Assert(t, err == nil)
+
+	// Bad port
+	nodeReq := &api.NodeAddRequest{
+		Zone:      1,
+		ClusterId: cluster.Id,
+		SshUser:   "admin",
+		SshPort:   "port",
+	}
+	nodeReq.Hostnames.Manage = sort.StringSlice{"manage.a"}
+	nodeReq.Hostnames.Storage = sort.StringSlice{"storage.a"}
+	_, err = c.NodeAdd(nodeReq)
+	tests.Assert(t, err != nil)
+	tests.Assert(t, len(hosts) == 0, hosts)
+
+	// The settings are saved and given to the executor
+	nodeReq.SshPort = "2222"
+	node, err := c.NodeAdd(nodeReq)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, node.SshUser == "admin")
+	tests.Assert(t, node.SshPort == "2222")
+	tests.Assert(t, hosts["manage.a"].User == "admin", hosts)
+	tests.Assert(t, hosts["manage.a"].Port == "2222", hosts)
+
+	// A failed add does not keep the settings
+	app.xo.MockPeerProbe = func(exec_host, newnode string) error {
+		return errors.New("probe failed")
+	}
+	nodeReq.Hostnames.Manage = sort.StringSlice{"manage.b"}
+	nodeReq.Hostnames.Storage = sort.StringSlice{"storage.b"}
+	_, err = c.NodeAdd(nodeReq)
+	tests.Assert(t, err != nil)
+	_, ok := hosts["manage.b"]
+	tests.Assert(t, !ok, hosts)
+
+	// Bad port on update
+	_, err = c.NodeUpdate(node.Id, &api.NodeUpdateRequest{SshPort: "70000"})
+	tests.Assert(t, err != nil)
+
+	// Only the port changes
+	info, err := c.NodeUpdate(node.Id, &api.NodeUpdateRequest{SshPort: "2200"})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, info.SshUser == "admin")
+	tests.Assert(t, info.SshPort == "2200")
+	tests.Assert(t, hosts["manage.a"].Port == "2200", hosts)
+
+	// The settings move with the management hostname
+	updateReq := &api.NodeUpdateRequest{SshUser: "root"}
+	updateReq.Hostnames.Manage = sort.StringSlice{"manage.a2"}
+	info, err = c.NodeUpdate(node.Id, updateReq)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, info.SshUser == "root")
+	_, ok = hosts["manage.a"]
+	tests.Assert(t, !ok, hosts)
+	tests.Assert(t, hosts["manage.a2"].User == "root", hosts)
+	tests.Assert(t, hosts["manage.a2"].Port == "2200", hosts)
+
+	// The port goes back to the one of the configuration
+	info, err = c.NodeUpdate(node.Id, &api.NodeUpdateRequest{SshPort: api.NodeSshDefault})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, info.SshUser == "root")
+	tests.Assert(t, info.SshPort == "")
+	tests.Assert(t, hosts["manage.a2"].User == "root", hosts)
+	tests.Assert(t, hosts["manage.a2"].Port == "", hosts)
+
+	// They are loaded again when the application starts
+	hosts = make(map[string]executors.HostConnection)
+	err = app.loadHostConnections()
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(hosts) == 1, hosts)
+	tests.Assert(t, hosts["manage.a2"].User == "root", hosts)
+
+	// And the user too
+	info, err = c.NodeUpdate(node.Id, &api.NodeUpdateRequest{SshUser: api.NodeSshDefault})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, info.SshUser == "")
+	tests.Assert(t, hosts["manage.a2"] == executors.HostConnection{}, hosts)
+
+	// And removed with the node
+	err = c.NodeDelete(node.Id)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(hosts) == 0, hosts)
+}
+
+func TestNodeSshCheck(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Create a client
+	c := client.NewClientNoAuth(ts.URL)
+	tests.Assert(t, c != nil)
+
+	cluster, err := c.ClusterCreate()
+	tests.Assert(t, err == nil)
+
+	nodeReq := &api.NodeAddRequest{
+		Zone:      1,
+		ClusterId: cluster.Id,
+	}
+	nodeReq.Hostnames.Manage = sort.StringSlice{"manage.a"}
+	nodeReq.Hostnames.Storage = sort.StringSlice{"storage.a"}
+	node, err := c.NodeAdd(nodeReq)
+	tests.Assert(t, err == nil, err)
+
+	// Unknown node
+	_, err = c.NodeSshCheck("123")
+	tests.Assert(t, err != nil)
+
+	// Node is reachable and sudo works
+	app.xo.MockPing = func(host string) error {
+		tests.Assert(t, host == "manage.a", host)
+		return nil
+	}
+	check, err := c.NodeSshCheck(node.Id)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, check.Id == node.Id)
+	tests.Assert(t, check.Host == "manage.a")
+	tests.Assert(t, check.Reachable)
+	tests.Assert(t, check.Sudo)
+	tests.Assert(t, check.Error == "")
+
+	// Sudo fails on the node
+	app.xo.MockPing = func(host string) error {
+		return &executors.CommandFailedError{
+			Host: host,
+			Result: executors.CommandResult{
+				Command:    "sudo true",
+				ExitStatus: 1,
+				Stderr:     "sudo: a password is required",
+			},
+		}
+	}
+	check, err = c.NodeSshCheck(node.Id)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, check.Reachable)
+	tests.Assert(t, !check.Sudo)
+	tests.Assert(t, check.Error != "")
+
+	// Node cannot be reached
+	app.xo.MockPing = func(host string) error {
+		return &executors.HostUnreachableError{
+			Host: host,
+			Err:  errors.New("Host key of manage.a:22 does not match"),
+		}
+	}
+	check, err = c.NodeSshCheck(node.Id)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, !check.Reachable)
+	tests.Assert(t, !check.Sudo)
+	tests.Assert(t, check.Error != "")
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/boltdb/bolt"
 	"github.com/gorilla/mux"
 	client "github.com/heketi/heketi/client/api/go-client"
+	"github.com/heketi/heketi/executors"
 	"github.com/heketi/heketi/pkg/glusterfs/api"
 	"github.com/heketi/tests"
 	"github.com/heketi/utils"
@@ -1088,3 +1089,306 @@ func TestNodeState(t *testing.T) {
 	tests.Assert(t, mockAllocator.clustermap[cluster.Id][0] == device.Id)
 
 }
+
+func TestNodeMaintenance(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Create a client
+	c := client.NewClientNoAuth(ts.URL)
+	tests.Assert(t, c != nil)
+
+	err := setupSampleDbWithTopology(app,
+		1,      // clusters
+		2,      // nodes_per_cluster
+		1,      // devices_per_node,
+		500*GB, // disksize
+	)
+	tests.Assert(t, err == nil)
+
+	v := createSampleVolumeEntry(100)
+	err = v.Create(app.db, app.executor, app.allocator)
+	tests.Assert(t, err == nil)
+
+	var nodes []string
+	err = app.db.View(func(tx *bolt.Tx) error {
+		cluster, err := NewClusterEntryFromId(tx, v.Info.Cluster)
+		nodes = cluster.Info.Nodes
+		return err
+	})
+	tests.Assert(t, err == nil)
+
+	// Volume has entries to heal
+	healed := 0
+	app.xo.MockVolumeHealInfo = func(host string, volume string) (*executors.VolumeHealInfo, error) {
+		tests.Assert(t, volume == v.Info.Name)
+		return &executors.VolumeHealInfo{
+			Bricks: []executors.BrickHealInfo{
+				executors.BrickHealInfo{Name: "a:/b1", Connected: true, Entries: healed},
+				executors.BrickHealInfo{Name: "b:/b2", Connected: true, Entries: 0},
+			},
+		}, nil
+	}
+
+	healed = 10
+	err = c.NodeState(nodes[0], &api.StateRequest{
+		State: api.EntryStateMaintenance,
+	})
+	tests.Assert(t, err != nil)
+	tests.Assert(t, strings.Contains(err.Error(), "pending heal"), err)
+
+	// Volume is healed
+	healed = 0
+	err = c.NodeState(nodes[0], &api.StateRequest{
+		State: api.EntryStateMaintenance,
+	})
+	tests.Assert(t, err == nil, err)
+
+	info, err := c.NodeInfo(nodes[0])
+	tests.Assert(t, err == nil)
+	tests.Assert(t, info.State == api.EntryStateMaintenance)
+
+	// The other node shares the replica sets
+	err = c.NodeState(nodes[1], &api.StateRequest{
+		State: api.EntryStateMaintenance,
+	})
+	tests.Assert(t, err != nil)
+
+	// Leaving maintenance checks the volume is healed, but does not
+	// require it
+	checked := 0
+	app.xo.MockVolumeHealInfo = func(host string, volume string) (*executors.VolumeHealInfo, error) {
+		checked++
+		return &executors.VolumeHealInfo{
+			Bricks: []executors.BrickHealInfo{
+				executors.BrickHealInfo{Name: "a:/b1", Connected: true, Entries: 10},
+			},
+		}, nil
+	}
+	err = c.NodeState(nodes[0], &api.StateRequest{
+		State: api.EntryStateOnline,
+	})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, checked == 1, checked)
+
+	info, err = c.NodeInfo(nodes[0])
+	tests.Assert(t, err == nil)
+	tests.Assert(t, info.State == api.EntryStateOnline)
+
+	// Heal information cannot be read
+	app.xo.MockVolumeHealInfo = func(host string, volume string) (*executors.VolumeHealInfo, error) {
+		checked++
+		return nil, errors.New("gluster not running")
+	}
+	err = c.NodeState(nodes[0], &api.StateRequest{
+		State: api.EntryStateMaintenance,
+	})
+	tests.Assert(t, err != nil)
+
+	// An offline node can be put in maintenance without reaching it
+	err = c.NodeState(nodes[0], &api.StateRequest{
+		State: api.EntryStateOffline,
+	})
+	tests.Assert(t, err == nil, err)
+
+	checked = 0
+	err = c.NodeState(nodes[0], &api.StateRequest{
+		State: api.EntryStateMaintenance,
+	})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, checked == 0, checked)
+
+	info, err = c.NodeInfo(nodes[0])
+	tests.Assert(t, err == nil)
+	tests.Assert(t, info.State == api.EntryStateMaintenance)
+
+	// Failing to read heal information does not keep it in maintenance
+	err = c.NodeState(nodes[0], &api.StateRequest{
+		State: api.EntryStateOnline,
+	})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, checked == 1, checked)
+}
+
+func TestNodeUpdate(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Create a client
+	c := client.NewClientNoAuth(ts.URL)
+	tests.Assert(t, c != nil)
+
+	// Create a cluster with two nodes
+	cluster, err := c.ClusterCreate()
+	tests.Assert(t, err == nil)
+
+	nodes := make([]*api.NodeInfoResponse, 0)
+	for _, name := range []string{"a", "b"} {
+		nodeReq := &api.NodeAddRequest{
+			Zone:      1,
+			ClusterId: cluster.Id,
+		}
+		nodeReq.Hostnames.Manage = sort.StringSlice{"manage." + name}
+		nodeReq.Hostnames.Storage = sort.StringSlice{"storage." + name}
+		node, err := c.NodeAdd(nodeReq)
+		tests.Assert(t, err == nil)
+
+		deviceReq := &api.DeviceAddRequest{}
+		deviceReq.Name = "/dev/fake1"
+		deviceReq.NodeId = node.Id
+		err = c.DeviceAdd(deviceReq)
+		tests.Assert(t, err == nil)
+
+		nodes = append(nodes, node)
+	}
+	id := nodes[0].Id
+
+	// Bad JSON
+	request := []byte(`{ bad json }`)
+	req, err := http.NewRequest("PATCH", ts.URL+"/nodes/"+id, bytes.NewBuffer(request))
+	tests.Assert(t, err == nil)
+	r, err := http.DefaultClient.Do(req)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == 422)
+
+	// Unknown node
+	_, err = c.NodeUpdate("123", &api.NodeUpdateRequest{Zone: 2})
+	tests.Assert(t, err != nil)
+
+	// Bad values
+	_, err = c.NodeUpdate(id, &api.NodeUpdateRequest{Zone: -1})
+	tests.Assert(t, err != nil)
+
+	badReq := &api.NodeUpdateRequest{}
+	badReq.Hostnames.Manage = sort.StringSlice{""}
+	_, err = c.NodeUpdate(id, badReq)
+	tests.Assert(t, err != nil)
+
+	// Hostname used by the other node
+	badReq.Hostnames.Manage = sort.StringSlice{"manage.b"}
+	_, err = c.NodeUpdate(id, badReq)
+	tests.Assert(t, err != nil)
+	tests.Assert(t, strings.Contains(err.Error(), "already used"), err)
+
+	// Change zone and hostnames
+	probed := ""
+	app.xo.MockPeerProbe = func(exec_host, newnode string) error {
+		tests.Assert(t, exec_host == "manage.b", exec_host)
+		probed = newnode
+		return nil
+	}
+
+	updateReq := &api.NodeUpdateRequest{Zone: 2}
+	updateReq.Hostnames.Manage = sort.StringSlice{"manage.a2"}
+	updateReq.Hostnames.Storage = sort.StringSlice{"storage.a2"}
+	info, err := c.NodeUpdate(id, updateReq)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, probed == "storage.a2", probed)
+	tests.Assert(t, info.Zone == 2)
+	tests.Assert(t, info.Hostnames.Manage[0] == "manage.a2")
+	tests.Assert(t, info.Hostnames.Storage[0] == "storage.a2")
+	tests.Assert(t, info.ClusterId == cluster.Id)
+	tests.Assert(t, len(info.DevicesInfo) == 1)
+
+	// Device is in the ring under the new zone
+	ring := app.allocator.(*SimpleAllocator).rings[cluster.Id].ring
+	tests.Assert(t, len(ring[1][id]) == 0)
+	tests.Assert(t, len(ring[2][id]) == 1)
+	tests.Assert(t, ring[2][id][0].deviceId == info.DevicesInfo[0].Id)
+
+	// The old hostnames are free and the new ones registered
+	badReq.Hostnames.Manage = sort.StringSlice{"manage.a2"}
+	_, err = c.NodeUpdate(nodes[1].Id, badReq)
+	tests.Assert(t, err != nil)
+
+	// Only the zone changes, no peer probe is needed
+	probed = ""
+	info, err = c.NodeUpdate(id, &api.NodeUpdateRequest{Zone: 3})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, probed == "")
+	tests.Assert(t, info.Zone == 3)
+	tests.Assert(t, info.Hostnames.Manage[0] == "manage.a2")
+
+	// Peer probe failure restores the hostnames
+	app.xo.MockPeerProbe = func(exec_host, newnode string) error {
+		return errors.New("probe failed")
+	}
+	updateReq = &api.NodeUpdateRequest{}
+	updateReq.Hostnames.Storage = sort.StringSlice{"storage.a3"}
+	_, err = c.NodeUpdate(id, updateReq)
+	tests.Assert(t, err != nil)
+
+	info, err = c.NodeInfo(id)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, info.Hostnames.Storage[0] == "storage.a2")
+
+	// The old storage name is still registered and the new one is free
+	app.xo.MockPeerProbe = func(exec_host, newnode string) error {
+		return nil
+	}
+	updateReq.Hostnames.Storage = sort.StringSlice{"storage.a2"}
+	_, err = c.NodeUpdate(nodes[1].Id, updateReq)
+	tests.Assert(t, err != nil)
+
+	updateReq.Hostnames.Storage = sort.StringSlice{"storage.a3"}
+	info, err = c.NodeUpdate(nodes[1].Id, updateReq)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, info.Hostnames.Storage[0] == "storage.a3")
+
+	// Give the node a brick
+	err = app.db.Update(func(tx *bolt.Tx) error {
+		node, err := NewNodeEntryFromId(tx, id)
+		tests.Assert(t, err == nil)
+		device, err := NewDeviceEntryFromId(tx, node.Devices[0])
+		tests.Assert(t, err == nil)
+		brick := NewBrickEntry(10, 20, 5, device.Info.Id, id)
+		err = brick.Save(tx)
+		tests.Assert(t, err == nil)
+		device.BrickAdd(brick.Info.Id)
+		return device.Save(tx)
+	})
+	tests.Assert(t, err == nil)
+
+	// The storage hostnames cannot change while it has bricks
+	probed = ""
+	updateReq = &api.NodeUpdateRequest{}
+	updateReq.Hostnames.Storage = sort.StringSlice{"storage.a4"}
+	_, err = c.NodeUpdate(id, updateReq)
+	tests.Assert(t, err != nil)
+	tests.Assert(t, strings.Contains(err.Error(), "has bricks"), err)
+	tests.Assert(t, probed == "")
+
+	info, err = c.NodeInfo(id)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, info.Hostnames.Storage[0] == "storage.a2")
+
+	// The same storage hostnames and other settings are accepted
+	updateReq = &api.NodeUpdateRequest{Zone: 4}
+	updateReq.Hostnames.Manage = sort.StringSlice{"manage.a4"}
+	updateReq.Hostnames.Storage = sort.StringSlice{"storage.a2"}
+	info, err = c.NodeUpdate(id, updateReq)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, info.Zone == 4)
+	tests.Assert(t, info.Hostnames.Manage[0] == "manage.a4")
+	tests.Assert(t, info.Hostnames.Storage[0] == "storage.a2")
+}
@@ -18,8 +18,11 @@ package glusterfs
 
 import (
 	"bytes"
+	"github.com/gorilla/mux"
 	"github.com/heketi/tests"
 	"github.com/heketi/utils"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 )
@@ -160,3 +163,332 @@ func TestAppLogLevel(t *testing.T) {
 	tests.Assert(t, app != nil)
 	tests.Assert(t, logger.Level() == utils.LEVEL_NOLOG)
 }
+
+func TestAppConfigValidate(t *testing.T) {
+	keyfile := tests.Tempfile()
+	fp, err := os.Create(keyfile)
+	tests.Assert(t, err == nil)
+	fp.Close()
+	defer os.Remove(keyfile)
+
+	// Good configuration
+	config := &GlusterFSConfig{
+		Executor:     "ssh",
+		Allocator:    "simple",
+		Loglevel:     "info",
+		DBfile:       tests.Tempfile(),
+		BrickMaxSize: 1024,
+		BrickMinSize: 1,
+	}
+	config.SshConfig.PrivateKeyFile = keyfile
+	config.SshConfig.Port = "2222"
+	errs := config.Validate()
+	tests.Assert(t, len(errs) == 0, errs)
+
+	// Mock does not need any other settings
+	config = &GlusterFSConfig{
+		Executor: "mock",
+	}
+	errs = config.Validate()
+	tests.Assert(t, len(errs) == 0, errs)
+
+	// Neither does local
+	config = &GlusterFSConfig{
+		Executor: "local",
+	}
+	errs = config.Validate()
+	tests.Assert(t, len(errs) == 0, errs)
+
+	// All problems are returned at once
+	config = &GlusterFSConfig{
+		Executor:     "ssh",
+		Allocator:    "bad allocator",
+		Loglevel:     "bad level",
+		DBfile:       "/this/does/not/exist/heketi.db",
+		BrickMaxSize: 10,
+		BrickMinSize: 20,
+		BrickMaxNum:  -1,
+	}
+	config.SshConfig.Port = "badport"
+	errs = config.Validate()
+	tests.Assert(t, len(errs) == 7, errs)
+
+	// Limits and timeouts of the ssh executor
+	config = &GlusterFSConfig{
+		Executor: "ssh",
+	}
+	config.SshConfig.PrivateKeyFile = keyfile
+	config.SshConfig.MaxConnectionsPerHost = 4
+	config.SshConfig.MaxConnections = 16
+	config.SshConfig.BrickCreateTimeout = 30
+	errs = config.Validate()
+	tests.Assert(t, len(errs) == 0, errs)
+
+	config.SshConfig.MaxConnections = -1
+	config.SshConfig.DeviceSetupTimeout = -1
+	errs = config.Validate()
+	tests.Assert(t, len(errs) == 2, errs)
+
+	config.SshConfig.RetryAttempts = -1
+	config.SshConfig.RetryBackoff = -1
+	errs = config.Validate()
+	tests.Assert(t, len(errs) == 4, errs)
+
+	// The ssh-agent can be used instead of a key file
+	defer os.Setenv("SSH_AUTH_SOCK", os.Getenv("SSH_AUTH_SOCK"))
+	os.Setenv("SSH_AUTH_SOCK", "/tmp/agent.sock")
+	config = &GlusterFSConfig{
+		Executor: "ssh",
+	}
+	config.SshConfig.Agent = true
+	config.SshConfig.KnownHostsFile = keyfile
+	errs = config.Validate()
+	tests.Assert(t, len(errs) == 0, errs)
+
+	os.Unsetenv("SSH_AUTH_SOCK")
+	config.SshConfig.KnownHostsFile = "/this/does/not/exist"
+	errs = config.Validate()
+	tests.Assert(t, len(errs) == 2, errs)
+
+	// Unreadable key file
+	config = &GlusterFSConfig{
+		Executor: "ssh",
+	}
+	config.SshConfig.PrivateKeyFile = "/this/does/not/exist"
+	errs = config.Validate()
+	tests.Assert(t, len(errs) == 1, errs)
+
+	// Unknown executor
+	config = &GlusterFSConfig{
+		Executor: "unknown",
+	}
+	errs = config.Validate()
+	tests.Assert(t, len(errs) == 1, errs)
+
+	// Kubernetes requires a namespace
+	config = &GlusterFSConfig{
+		Executor: "kubernetes",
+	}
+	errs = config.Validate()
+	tests.Assert(t, len(errs) == 1, errs)
+
+	// Unless it is taken from the service account of the pod
+	defer os.Setenv("KUBERNETES_SERVICE_HOST", os.Getenv("KUBERNETES_SERVICE_HOST"))
+	defer os.Setenv("KUBERNETES_SERVICE_PORT", os.Getenv("KUBERNETES_SERVICE_PORT"))
+	os.Setenv("KUBERNETES_SERVICE_HOST", "10.0.0.1")
+	os.Setenv("KUBERNETES_SERVICE_PORT", "443")
+	errs = config.Validate()
+	tests.Assert(t, len(errs) == 0, errs)
+
+	// which is not used with an API server configured
+	config.KubeConfig.Host = "https://myhost:8443"
+	errs = config.Validate()
+	tests.Assert(t, len(errs) == 1, errs)
+	config.KubeConfig.Host = ""
+
+	os.Setenv("KUBERNETES_SERVICE_PORT", "")
+	errs = config.Validate()
+	tests.Assert(t, len(errs) == 1, errs)
+	os.Setenv("KUBERNETES_SERVICE_HOST", "")
+
+	config.KubeConfig.Namespace = "default"
+	errs = config.Validate()
+	tests.Assert(t, len(errs) == 0, errs)
+
+	config.KubeConfig.RetryAttempts = -1
+	errs = config.Validate()
+	tests.Assert(t, len(errs) == 1, errs)
+
+	// Minimum size is compared against the default maximum
+	config = &GlusterFSConfig{
+		Executor:     "mock",
+		BrickMinSize: int(BrickMaxSize/GB) + 1,
+	}
+	errs = config.Validate()
+	tests.Assert(t, len(errs) == 1, errs)
+
+	// Device health sweep needs a threshold
+	config = &GlusterFSConfig{
+		Executor:             "mock",
+		DeviceHealthInterval: 60,
+	}
+	errs = config.Validate()
+	tests.Assert(t, len(errs) == 1, errs)
+
+	config.MaxPendingSectors = 10
+	errs = config.Validate()
+	tests.Assert(t, len(errs) == 0, errs)
+
+	config.MaxReallocatedSectors = -1
+	errs = config.Validate()
+	tests.Assert(t, len(errs) == 1, errs)
+
+	// Node heartbeat
+	config = &GlusterFSConfig{
+		Executor:              "mock",
+		NodeHeartbeatInterval: -1,
+		NodeHeartbeatFailures: -1,
+	}
+	errs = config.Validate()
+	tests.Assert(t, len(errs) == 2, errs)
+
+	// Thin pool warning threshold
+	config = &GlusterFSConfig{
+		Executor:            "mock",
+		ThinPoolWarnPercent: 101,
+	}
+	errs = config.Validate()
+	tests.Assert(t, len(errs) == 1, errs)
+}
+
+func TestAppReload(t *testing.T) {
+	dbfile := tests.Tempfile()
+	defer os.Remove(dbfile)
+
+	bmax, bmin, bnum := BrickMaxSize, BrickMinSize, BrickMaxNum
+	defer func() {
+		BrickMaxSize, BrickMinSize, BrickMaxNum = bmax, bmin, bnum
+	}()
+
+	app := NewTestApp(dbfile)
+	defer app.Close()
+	logger.SetLevel(utils.LEVEL_INFO)
+	defer logger.SetLevel(utils.LEVEL_INFO)
+
+	// Change the log level and limits.  Settings which
+	// need a restart are not changed
+	data := []byte(`{
+		"glusterfs" : {
+			"executor" : "ssh",
+			"allocator" : "simple",
+			"db" : "/some/other/place.db",
+			"loglevel" : "debug",
+			"brick_max_size_gb" : 1024,
+			"max_bricks_per_volume" : 33
+		}
+	}`)
+	err := app.Reload(bytes.NewReader(data))
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, logger.Level() == utils.LEVEL_DEBUG)
+	tests.Assert(t, BrickMaxNum == 33)
+	tests.Assert(t, BrickMaxSize == 1*TB)
+	tests.Assert(t, BrickMinSize == defaultBrickMinSize)
+	tests.Assert(t, app.conf.Executor == "mock")
+	tests.Assert(t, app.conf.DBfile == dbfile)
+
+	// Limits removed from the configuration go back to their defaults
+	data = []byte(`{
+		"glusterfs" : {
+			"executor" : "mock",
+			"allocator" : "simple",
+			"db" : "` + dbfile + `",
+			"loglevel" : "debug"
+		}
+	}`)
+	err = app.Reload(bytes.NewReader(data))
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, BrickMaxNum == defaultBrickMaxNum)
+	tests.Assert(t, BrickMaxSize == defaultBrickMaxSize)
+
+	// Nothing is changed if the configuration is not valid
+	data = []byte(`{
+		"glusterfs" : {
+			"executor" : "mock",
+			"allocator" : "simple",
+			"db" : "` + dbfile + `",
+			"loglevel" : "warning",
+			"brick_max_size_gb" : 1,
+			"brick_min_size_gb" : 2
+		}
+	}`)
+	err = app.Reload(bytes.NewReader(data))
+	tests.Assert(t, err != nil)
+	tests.Assert(t, logger.Level() == utils.LEVEL_DEBUG)
+	tests.Assert(t, BrickMaxSize == defaultBrickMaxSize)
+	tests.Assert(t, BrickMinSize == defaultBrickMinSize)
+
+	// Bad json
+	err = app.Reload(bytes.NewReader([]byte(`{ bad json }`)))
+	tests.Assert(t, err != nil)
+}
+
+func TestAppReloadKubeExec(t *testing.T) {
+	dbfile := tests.Tempfile()
+	defer os.Remove(dbfile)
+
+	// The namespace comes from the environment
+	defer os.Setenv("HEKETI_KUBE_NAMESPACE", os.Getenv("HEKETI_KUBE_NAMESPACE"))
+	os.Setenv("HEKETI_KUBE_NAMESPACE", "mynamespace")
+
+	data := []byte(`{
+		"glusterfs" : {
+			"executor" : "kubernetes",
+			"allocator" : "simple",
+			"db" : "` + dbfile + `",
+			"kubeexec" : {
+				"host" : "https://myhost:8443"
+			}
+		}
+	}`)
+	app := NewApp(bytes.NewReader(data))
+	tests.Assert(t, app != nil)
+	defer app.Close()
+
+	// The configuration is kept as read
+	tests.Assert(t, app.conf.KubeConfig.Namespace == "")
+
+	// so that reloading it unchanged is not taken as a change,
+	// and a change is still refused
+	err := app.Reload(bytes.NewReader(data))
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, app.conf.KubeConfig.Namespace == "")
+	tests.Assert(t, app.conf.KubeConfig.Host == "https://myhost:8443")
+
+	err = app.Reload(bytes.NewReader([]byte(`{
+		"glusterfs" : {
+			"executor" : "kubernetes",
+			"allocator" : "simple",
+			"db" : "` + dbfile + `",
+			"kubeexec" : {
+				"host" : "https://otherhost:8443"
+			}
+		}
+	}`)))
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, app.conf.KubeConfig.Host == "https://myhost:8443")
+}
+
+func TestAppConfigInfo(t *testing.T) {
+	dbfile := tests.Tempfile()
+	defer os.Remove(dbfile)
+
+	bnum := BrickMaxNum
+	defer func() {
+		BrickMaxNum = bnum
+	}()
+
+	app := NewTestApp(dbfile)
+	defer app.Close()
+	app.conf.KubeConfig.Password = "secret"
+	BrickMaxNum = 10
+
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	r, err := http.Get(ts.URL + "/config")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusOK)
+
+	var conf GlusterFSConfig
+	err = utils.GetJsonFromResponse(r, &conf)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, conf.Executor == "mock")
+	tests.Assert(t, conf.Allocator == "simple")
+	tests.Assert(t, conf.DBfile == dbfile)
+	tests.Assert(t, conf.KubeConfig.Password != "secret")
+	tests.Assert(t, conf.BrickMaxNum == 10)
+	tests.Assert(t, conf.BrickMaxSize == int(BrickMaxSize/GB))
+	tests.Assert(t, conf.BrickMinSize == int(BrickMinSize/GB))
+}
@@ -0,0 +1,219 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package glusterfs
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/mux"
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+)
+
+const (
+	defaultThinPoolWarnPercent = 80
+)
+
+// Bricks of a volume on the same device, which can be read together
+type deviceBricks struct {
+	host     string
+	deviceId string
+	bricks   []string
+}
+
+// Returns the actual usage of the thin pools and bricks of the device
+func (a *App) DeviceUsage(w http.ResponseWriter, r *http.Request) {
+	// Get the id from the URL
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var (
+		device *DeviceEntry
+		host   string
+	)
+	err := a.db.View(func(tx *bolt.Tx) error {
+		var err error
+		device, err = NewDeviceEntryFromId(tx, id)
+		if err == ErrNotFound {
+			http.Error(w, "Id not found", http.StatusNotFound)
+			return err
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		node, err := NewNodeEntryFromId(tx, device.NodeId)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+		host = node.ManageHostName()
+
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	bricks, err := a.readBrickUsage(&deviceBricks{
+		host:     host,
+		deviceId: device.Info.Id,
+		bricks:   device.Bricks,
+	})
+	if err != nil {
+		logger.Err(err)
+		http.Error(w, err.Error(), executorErrorStatus(err))
+		return
+	}
+
+	msg := &api.DeviceUsageResponse{
+		Id:      id,
+		Storage: device.Info.Storage,
+		Bricks:  bricks,
+	}
+	for _, brick := range bricks {
+		msg.Used += uint64(float64(brick.PoolSize) * brick.PoolDataPercent / 100)
+	}
+
+	// Write msg
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(msg); err != nil {
+		panic(err)
+	}
+}
+
+// Returns the actual usage of the bricks of the volume
+func (a *App) VolumeUsage(w http.ResponseWriter, r *http.Request) {
+	// Get the id from the URL
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	// Group the bricks by device
+	devices := make([]*deviceBricks, 0)
+	err := a.db.View(func(tx *bolt.Tx) error {
+		volume, err := NewVolumeEntryFromId(tx, id)
+		if err == ErrNotFound {
+			http.Error(w, "Id not found", http.StatusNotFound)
+			return err
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		found := make(map[string]*deviceBricks)
+		for _, brickId := range volume.BricksIds() {
+			brick, err := NewBrickEntryFromId(tx, brickId)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return err
+			}
+
+			device, ok := found[brick.Info.DeviceId]
+			if !ok {
+				node, err := NewNodeEntryFromId(tx, brick.Info.NodeId)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return err
+				}
+
+				device = &deviceBricks{
+					host:     node.ManageHostName(),
+					deviceId: brick.Info.DeviceId,
+				}
+				found[brick.Info.DeviceId] = device
+				devices = append(devices, device)
+			}
+			device.bricks = append(device.bricks, brickId)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	msg := &api.VolumeUsageResponse{
+		Id:     id,
+		Bricks: make([]api.BrickUsageInfo, 0),
+	}
+	for _, device := range devices {
+		bricks, err := a.readBrickUsage(device)
+		if err != nil {
+			logger.Err(err)
+			http.Error(w, err.Error(), executorErrorStatus(err))
+			return
+		}
+
+		for _, brick := range bricks {
+			msg.Used += brick.Used
+		}
+		msg.Bricks = append(msg.Bricks, bricks...)
+	}
+
+	// Write msg
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(msg); err != nil {
+		panic(err)
+	}
+}
+
+// Reads the usage of the bricks on a device from its node and warns
+// about thin pools which have passed the configured threshold
+func (a *App) readBrickUsage(device *deviceBricks) ([]api.BrickUsageInfo, error) {
+	a.confLock.RLock()
+	threshold := float64(a.conf.ThinPoolWarnPercent)
+	a.confLock.RUnlock()
+	if threshold == 0 {
+		threshold = defaultThinPoolWarnPercent
+	}
+
+	usage, err := a.executor.DeviceUsage(device.host, device.deviceId)
+	if err != nil {
+		return nil, err
+	}
+
+	bricks := make([]api.BrickUsageInfo, 0, len(device.bricks))
+	for _, id := range device.bricks {
+		info := api.BrickUsageInfo{
+			Id: id,
+		}
+
+		// A brick without a thin pool is reported with no usage
+		if brick, ok := usage.Bricks[id]; ok {
+			info.Size = brick.Size
+			info.Used = brick.Used
+			info.PoolSize = brick.PoolSize
+			info.PoolDataPercent = brick.PoolDataPercent
+			info.PoolMetadataPercent = brick.PoolMetadataPercent
+		} else {
+			logger.Warning("Thin pool of brick %v not found on %v", id, device.host)
+		}
+
+		if info.PoolDataPercent >= threshold || info.PoolMetadataPercent >= threshold {
+			logger.Warning("Thin pool of brick %v on %v is full: data %.2f%%, metadata %.2f%%",
+				id, device.host, info.PoolDataPercent, info.PoolMetadataPercent)
+			info.PoolWarning = true
+		}
+
+		bricks = append(bricks, info)
+	}
+
+	return bricks, nil
+}
@@ -0,0 +1,201 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package glusterfs
+
+import (
+	"errors"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/mux"
+	client "github.com/heketi/heketi/client/api/go-client"
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+	"github.com/heketi/tests"
+)
+
+func TestDeviceUsage(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Create a client
+	c := client.NewClientNoAuth(ts.URL)
+	tests.Assert(t, c != nil)
+
+	err := setupSampleDbWithTopology(app,
+		1,      // clusters
+		1,      // nodes_per_cluster
+		1,      // devices_per_node,
+		500*GB, // disksize
+	)
+	tests.Assert(t, err == nil)
+
+	v := createSampleVolumeEntry(10)
+	v.Info.Durability.Type = api.DurabilityDistributeOnly
+	err = v.Create(app.db, app.executor, app.allocator)
+	tests.Assert(t, err == nil)
+
+	var device *DeviceEntry
+	err = app.db.View(func(tx *bolt.Tx) error {
+		devices, err := DeviceList(tx)
+		if err != nil {
+			return err
+		}
+		device, err = NewDeviceEntryFromId(tx, devices[0])
+		return err
+	})
+	tests.Assert(t, err == nil)
+	tests.Assert(t, len(device.Bricks) > 0)
+
+	// Unknown device
+	_, err = c.DeviceUsage("123")
+	tests.Assert(t, err != nil)
+
+	// The first brick's thin pool is almost full
+	full := device.Bricks[0]
+	app.xo.MockDeviceUsage = func(host, vgid string) (*executors.DeviceUsageInfo, error) {
+		tests.Assert(t, vgid == device.Info.Id)
+		usage := &executors.DeviceUsageInfo{
+			Bricks: map[string]*executors.BrickUsageInfo{},
+		}
+		for _, id := range device.Bricks {
+			usage.Bricks[id] = &executors.BrickUsageInfo{
+				PoolSize:            1000,
+				PoolDataPercent:     10,
+				PoolMetadataPercent: 1,
+				Size:                900,
+				Used:                90,
+			}
+		}
+		usage.Bricks[full].PoolDataPercent = 90
+		return usage, nil
+	}
+
+	usage, err := c.DeviceUsage(device.Info.Id)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, usage.Id == device.Info.Id)
+	tests.Assert(t, usage.Storage.Used == device.Info.Storage.Used)
+	tests.Assert(t, len(usage.Bricks) == len(device.Bricks))
+	tests.Assert(t, usage.Used == uint64(100*(len(device.Bricks)-1)+900), usage.Used)
+	for _, brick := range usage.Bricks {
+		tests.Assert(t, brick.Size == 900)
+		tests.Assert(t, brick.Used == 90)
+		tests.Assert(t, brick.PoolSize == 1000)
+		tests.Assert(t, brick.PoolWarning == (brick.Id == full), brick.Id)
+	}
+
+	// A higher threshold does not warn
+	app.conf.ThinPoolWarnPercent = 95
+	usage, err = c.DeviceUsage(device.Info.Id)
+	tests.Assert(t, err == nil, err)
+	for _, brick := range usage.Bricks {
+		tests.Assert(t, !brick.PoolWarning)
+	}
+
+	// Usage cannot be read
+	app.xo.MockDeviceUsage = func(host, vgid string) (*executors.DeviceUsageInfo, error) {
+		return nil, errors.New("lvs failed")
+	}
+
+	_, err = c.DeviceUsage(device.Info.Id)
+	tests.Assert(t, err != nil)
+}
+
+func TestVolumeUsage(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Create a client
+	c := client.NewClientNoAuth(ts.URL)
+	tests.Assert(t, c != nil)
+
+	err := setupSampleDbWithTopology(app,
+		1,    // clusters
+		4,    // nodes_per_cluster
+		2,    // devices_per_node,
+		5*TB, // disksize
+	)
+	tests.Assert(t, err == nil)
+
+	v := createSampleVolumeEntry(100)
+	err = v.Create(app.db, app.executor, app.allocator)
+	tests.Assert(t, err == nil)
+
+	// Unknown volume
+	_, err = c.VolumeUsage("123")
+	tests.Assert(t, err != nil)
+
+	// Every brick of the volume uses 10 KB.  Bricks of other
+	// volumes are not reported.
+	bricks := make(map[string]bool)
+	for _, id := range v.Bricks {
+		bricks[id] = true
+	}
+	app.xo.MockDeviceUsage = func(host, vgid string) (*executors.DeviceUsageInfo, error) {
+		usage := &executors.DeviceUsageInfo{
+			Bricks: map[string]*executors.BrickUsageInfo{},
+		}
+		for id := range bricks {
+			usage.Bricks[id] = &executors.BrickUsageInfo{
+				PoolSize: 1000,
+				Size:     100,
+				Used:     10,
+			}
+		}
+		return usage, nil
+	}
+
+	usage, err := c.VolumeUsage(v.Info.Id)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, usage.Id == v.Info.Id)
+	tests.Assert(t, len(usage.Bricks) == len(v.Bricks))
+	tests.Assert(t, usage.Used == uint64(10*len(v.Bricks)))
+	for _, brick := range usage.Bricks {
+		tests.Assert(t, bricks[brick.Id])
+		tests.Assert(t, !brick.PoolWarning)
+	}
+
+	// Usage cannot be read
+	app.xo.MockDeviceUsage = func(host, vgid string) (*executors.DeviceUsageInfo, error) {
+		return nil, errors.New("lvs failed")
+	}
+
+	_, err = c.VolumeUsage(v.Info.Id)
+	tests.Assert(t, err != nil)
+}
@@ -18,11 +18,13 @@ package glusterfs
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/boltdb/bolt"
 	"github.com/gorilla/mux"
+	"github.com/heketi/heketi/executors"
 	"github.com/heketi/heketi/pkg/glusterfs/api"
 	"github.com/heketi/utils"
 )
@@ -31,6 +33,40 @@ const (
 	VOLUME_CREATE_MAX_SNAPSHOT_FACTOR = 100
 )
 
+// Checks the durability type and values are supported
+func validateDurability(durability *api.VolumeDurabilityInfo) error {
+	switch durability.Type {
+	case api.DurabilityEC:
+	case api.DurabilityReplicate:
+	case api.DurabilityDistributeOnly:
+	case "":
+	default:
+		return errors.New("Unknown durability type")
+	}
+
+	// Check replica values
+	if durability.Type == api.DurabilityReplicate {
+		if durability.Replicate.Replica > 3 {
+			return errors.New("Invalid replica value")
+		}
+	}
+
+	// Check Disperse combinations
+	if durability.Type == api.DurabilityEC {
+		d := durability.Disperse
+		// Place here correct combinations
+		switch {
+		case d.Data == 4 && d.Redundancy == 2:
+		case d.Data == 8 && d.Redundancy == 3:
+		case d.Data == 8 && d.Redundancy == 4:
+		default:
+			return fmt.Errorf("Invalid dispersion combination: %v+%v", d.Data, d.Redundancy)
+		}
+	}
+
+	return nil
+}
+
 func (a *App) VolumeCreate(w http.ResponseWriter, r *http.Request) {
 
 	var msg api.VolumeCreateRequest
@@ -40,15 +76,10 @@ func (a *App) VolumeCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check durability type
-	switch msg.Durability.Type {
-	case api.DurabilityEC:
-	case api.DurabilityReplicate:
-	case api.DurabilityDistributeOnly:
-	case "":
-		msg.Durability.Type = api.DurabilityDistributeOnly
-	default:
-		http.Error(w, "Unknown durability type", http.StatusBadRequest)
+	// Check durability.  If no type is given, the default
+	// of the cluster where the volume is created is used.
+	if err := validateDurability(&msg.Durability); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -64,30 +95,6 @@ func (a *App) VolumeCreate(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Check replica values
-	if msg.Durability.Type == api.DurabilityReplicate {
-		if msg.Durability.Replicate.Replica > 3 {
-			http.Error(w, "Invalid replica value", http.StatusBadRequest)
-			return
-		}
-	}
-
-	// Check Disperse combinations
-	if msg.Durability.Type == api.DurabilityEC {
-		d := msg.Durability.Disperse
-		// Place here correct combinations
-		switch {
-		case d.Data == 4 && d.Redundancy == 2:
-		case d.Data == 8 && d.Redundancy == 3:
-		case d.Data == 8 && d.Redundancy == 4:
-		default:
-			http.Error(w,
-				fmt.Sprintf("Invalid dispersion combination: %v+%v", d.Data, d.Redundancy),
-				http.StatusBadRequest)
-			return
-		}
-	}
-
 	// Check that the clusters requested are avilable
 	err = a.db.View(func(tx *bolt.Tx) error {
 
@@ -112,6 +119,20 @@ func (a *App) VolumeCreate(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
+		// Check at least one cluster matches the selector
+		if len(msg.Clusters) != 0 {
+			clusters = msg.Clusters
+		}
+		clusters, err = ClusterListMatching(tx, clusters, msg.ClusterSelector)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+		if len(clusters) == 0 {
+			http.Error(w, "No clusters match the cluster selector", http.StatusBadRequest)
+			return ErrNotFound
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -121,6 +142,13 @@ func (a *App) VolumeCreate(w http.ResponseWriter, r *http.Request) {
 	// Create a volume entry
 	vol := NewVolumeEntryFromRequest(&msg)
 
+	if isDryRun(r) {
+		a.dryRun(w, func(db *bolt.DB, executor executors.Executor, allocator Allocator) error {
+			return vol.Create(db, executor, allocator)
+		})
+		return
+	}
+
 	// Add device in an asynchronous function
 	a.asyncManager.AsyncHttpRedirectFunc(w, r, func() (string, error) {
 
@@ -235,6 +263,13 @@ func (a *App) VolumeDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if isDryRun(r) {
+		a.dryRun(w, func(db *bolt.DB, executor executors.Executor, allocator Allocator) error {
+			return volume.Destroy(db, executor)
+		})
+		return
+	}
+
 	a.asyncManager.AsyncHttpRedirectFunc(w, r, func() (string, error) {
 
 		// Actually destroy the Volume here
@@ -300,11 +335,19 @@ func (a *App) VolumeExpand(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if isDryRun(r) {
+		a.dryRun(w, func(db *bolt.DB, executor executors.Executor, allocator Allocator) error {
+			return volume.Expand(db, executor, allocator, msg.Size, false)
+		})
+		return
+	}
+
 	// Expand device in an asynchronous function
 	a.asyncManager.AsyncHttpRedirectFunc(w, r, func() (string, error) {
 
 		logger.Info("Expanding volume %v", volume.Info.Id)
-		err := volume.Expand(a.db, a.executor, a.allocator, msg.Size)
+		err := volume.Expand(a.db, a.executor, a.allocator, msg.Size,
+			msg.WaitForRebalance)
 		if err != nil {
 			logger.LogError("Failed to expand volume %v", volume.Info.Id)
 			return "", err
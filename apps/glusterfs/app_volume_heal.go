@@ -0,0 +1,141 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package glusterfs
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/mux"
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+)
+
+// Returns the self-heal status of each brick in the volume
+func (a *App) VolumeHealInfo(w http.ResponseWriter, r *http.Request) {
+	// Get the id from the URL
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var (
+		volume *VolumeEntry
+		host   string
+		bricks map[string]string
+	)
+	err := a.db.View(func(tx *bolt.Tx) error {
+		var err error
+		volume, err = NewVolumeEntryFromId(tx, id)
+		if err == ErrNotFound {
+			http.Error(w, "Id not found", http.StatusNotFound)
+			return err
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		host, bricks, err = volume.brickHosts(tx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	heal, err := a.executor.VolumeHealInfo(host, volume.Info.Name)
+	if err != nil {
+		logger.Err(err)
+		http.Error(w, err.Error(), executorErrorStatus(err))
+		return
+	}
+
+	msg := &api.VolumeHealResponse{
+		Id:     id,
+		Bricks: make([]api.BrickHealInfo, 0, len(heal.Bricks)),
+	}
+	msg.Pending, msg.Complete = heal.Pending()
+	for _, brick := range heal.Bricks {
+		msg.Bricks = append(msg.Bricks, api.BrickHealInfo{
+			Id:        bricks[brick.Name],
+			Name:      brick.Name,
+			Connected: brick.Connected,
+			Entries:   brick.Entries,
+		})
+	}
+
+	// Write msg
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(msg); err != nil {
+		panic(err)
+	}
+}
+
+// Starts a full self-heal of the volume
+func (a *App) VolumeHeal(w http.ResponseWriter, r *http.Request) {
+	// Get the id from the URL
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var (
+		volume *VolumeEntry
+		host   string
+	)
+	err := a.db.View(func(tx *bolt.Tx) error {
+		var err error
+		volume, err = NewVolumeEntryFromId(tx, id)
+		if err == ErrNotFound {
+			http.Error(w, "Id not found", http.StatusNotFound)
+			return err
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		if volume.Info.Durability.Type == api.DurabilityDistributeOnly {
+			http.Error(w, "Volume has no redundancy to heal from",
+				http.StatusConflict)
+			return ErrConflict
+		}
+
+		host, _, err = volume.brickHosts(tx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	a.asyncManager.AsyncHttpRedirectFunc(w, r, func() (string, error) {
+		logger.Info("Starting heal on volume %v", volume.Info.Id)
+		err := a.executor.VolumeHeal(host, volume.Info.Name)
+		if err != nil {
+			logger.LogError("Failed to start heal on volume %v: %v",
+				volume.Info.Id, err)
+			return "", err
+		}
+
+		return "/volumes/" + volume.Info.Id + "/heal", nil
+	})
+}
@@ -0,0 +1,191 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package glusterfs
+
+import (
+	"errors"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/mux"
+	client "github.com/heketi/heketi/client/api/go-client"
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+	"github.com/heketi/tests"
+)
+
+func TestVolumeHealInfo(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Create a client
+	c := client.NewClientNoAuth(ts.URL)
+	tests.Assert(t, c != nil)
+
+	err := setupSampleDbWithTopology(app,
+		1,    // clusters
+		4,    // nodes_per_cluster
+		2,    // devices_per_node,
+		5*TB, // disksize
+	)
+	tests.Assert(t, err == nil)
+
+	v := createSampleVolumeEntry(100)
+	err = v.Create(app.db, app.executor, app.allocator)
+	tests.Assert(t, err == nil)
+
+	// Names of the bricks as gluster reports them
+	names := make(map[string]string)
+	err = app.db.View(func(tx *bolt.Tx) error {
+		for _, id := range v.BricksIds() {
+			brick, err := NewBrickEntryFromId(tx, id)
+			if err != nil {
+				return err
+			}
+			node, err := NewNodeEntryFromId(tx, brick.Info.NodeId)
+			if err != nil {
+				return err
+			}
+			names[node.StorageHostName()+":"+brick.Info.Path] = id
+		}
+		return nil
+	})
+	tests.Assert(t, err == nil)
+
+	// Unknown volume
+	_, err = c.VolumeHealInfo("123")
+	tests.Assert(t, err != nil)
+
+	app.xo.MockVolumeHealInfo = func(host, volume string) (*executors.VolumeHealInfo, error) {
+		tests.Assert(t, volume == v.Info.Name)
+		info := &executors.VolumeHealInfo{}
+		for name := range names {
+			info.Bricks = append(info.Bricks, executors.BrickHealInfo{
+				Name:      name,
+				Connected: true,
+				Entries:   3,
+			})
+		}
+		info.Bricks = append(info.Bricks, executors.BrickHealInfo{
+			Name:      "unknown:/brick",
+			Connected: false,
+			Entries:   -1,
+		})
+		return info, nil
+	}
+
+	heal, err := c.VolumeHealInfo(v.Info.Id)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, heal.Id == v.Info.Id)
+	tests.Assert(t, heal.Pending == 3*len(names))
+	tests.Assert(t, !heal.Complete)
+	tests.Assert(t, len(heal.Bricks) == len(names)+1)
+	for _, brick := range heal.Bricks {
+		if brick.Name == "unknown:/brick" {
+			tests.Assert(t, brick.Id == "")
+			tests.Assert(t, brick.Entries == -1)
+		} else {
+			tests.Assert(t, brick.Id == names[brick.Name], brick.Name)
+			tests.Assert(t, brick.Entries == 3)
+		}
+	}
+
+	// Heal info cannot be read
+	app.xo.MockVolumeHealInfo = func(host, volume string) (*executors.VolumeHealInfo, error) {
+		return nil, errors.New("Volume heal failed")
+	}
+
+	_, err = c.VolumeHealInfo(v.Info.Id)
+	tests.Assert(t, err != nil)
+}
+
+func TestVolumeHeal(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Create a client
+	c := client.NewClientNoAuth(ts.URL)
+	tests.Assert(t, c != nil)
+
+	err := setupSampleDbWithTopology(app,
+		1,    // clusters
+		4,    // nodes_per_cluster
+		2,    // devices_per_node,
+		5*TB, // disksize
+	)
+	tests.Assert(t, err == nil)
+
+	v := createSampleVolumeEntry(100)
+	err = v.Create(app.db, app.executor, app.allocator)
+	tests.Assert(t, err == nil)
+
+	// Unknown volume
+	_, err = c.VolumeHeal("123")
+	tests.Assert(t, err != nil)
+
+	healed := false
+	app.xo.MockVolumeHeal = func(host, volume string) error {
+		tests.Assert(t, volume == v.Info.Name)
+		healed = true
+		return nil
+	}
+
+	heal, err := c.VolumeHeal(v.Info.Id)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, healed)
+	tests.Assert(t, heal.Id == v.Info.Id)
+	tests.Assert(t, heal.Complete)
+
+	// Heal fails to start
+	app.xo.MockVolumeHeal = func(host, volume string) error {
+		return errors.New("Launching heal operation has been unsuccessful")
+	}
+
+	_, err = c.VolumeHeal(v.Info.Id)
+	tests.Assert(t, err != nil)
+
+	// Nothing to heal from on a distributed volume
+	dv := createSampleVolumeEntry(100)
+	dv.Info.Durability.Type = api.DurabilityDistributeOnly
+	err = dv.Create(app.db, app.executor, app.allocator)
+	tests.Assert(t, err == nil)
+
+	_, err = c.VolumeHeal(dv.Info.Id)
+	tests.Assert(t, err != nil)
+}
@@ -0,0 +1,147 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package glusterfs
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/mux"
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+	"github.com/heketi/utils"
+)
+
+// Returns the progress of the rebalance on each node of the volume
+func (a *App) VolumeRebalanceStatus(w http.ResponseWriter, r *http.Request) {
+	// Get the id from the URL
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	volume, host, err := a.volumeCommandHost(w, id)
+	if err != nil {
+		return
+	}
+
+	status, err := a.executor.VolumeRebalanceStatus(host, volume.Info.Name)
+	if err != nil {
+		logger.Err(err)
+		http.Error(w, err.Error(), executorErrorStatus(err))
+		return
+	}
+
+	msg := &api.VolumeRebalanceResponse{
+		Id:     id,
+		Status: status.Status,
+		Nodes:  make([]api.NodeRebalanceInfo, 0, len(status.Nodes)),
+	}
+	for _, node := range status.Nodes {
+		msg.Nodes = append(msg.Nodes, api.NodeRebalanceInfo{
+			Name:     node.Name,
+			Status:   node.Status,
+			Files:    node.Files,
+			Size:     node.Size,
+			Lookups:  node.Lookups,
+			Failures: node.Failures,
+			Skipped:  node.Skipped,
+			RunTime:  node.RunTime,
+		})
+	}
+
+	// Write msg
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(msg); err != nil {
+		panic(err)
+	}
+}
+
+// Starts or stops a rebalance of the volume
+func (a *App) VolumeRebalance(w http.ResponseWriter, r *http.Request) {
+	// Get the id from the URL
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var msg api.VolumeRebalanceRequest
+	err := utils.GetJsonFromRequest(r, &msg)
+	if err != nil {
+		http.Error(w, "request unable to be parsed", 422)
+		return
+	}
+
+	// Check the message
+	var rebalance func(host, volume string) error
+	switch msg.Action {
+	case "start":
+		rebalance = a.executor.VolumeRebalanceStart
+	case "stop":
+		rebalance = a.executor.VolumeRebalanceStop
+	default:
+		http.Error(w, "Unknown rebalance action: "+msg.Action,
+			http.StatusBadRequest)
+		return
+	}
+
+	volume, host, err := a.volumeCommandHost(w, id)
+	if err != nil {
+		return
+	}
+
+	a.asyncManager.AsyncHttpRedirectFunc(w, r, func() (string, error) {
+		logger.Info("Rebalance %v on volume %v", msg.Action, volume.Info.Id)
+		err := rebalance(host, volume.Info.Name)
+		if err != nil {
+			logger.LogError("Failed to %v rebalance on volume %v: %v",
+				msg.Action, volume.Info.Id, err)
+			return "", err
+		}
+
+		return "/volumes/" + volume.Info.Id + "/rebalance", nil
+	})
+}
+
+// Returns the volume and the host to send its commands to.  On
+// failure the error has already been written to the response.
+func (a *App) volumeCommandHost(w http.ResponseWriter,
+	id string) (*VolumeEntry, string, error) {
+
+	var (
+		volume *VolumeEntry
+		host   string
+	)
+	err := a.db.View(func(tx *bolt.Tx) error {
+		var err error
+		volume, err = NewVolumeEntryFromId(tx, id)
+		if err == ErrNotFound {
+			http.Error(w, "Id not found", http.StatusNotFound)
+			return err
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		host, _, err = volume.brickHosts(tx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		return nil
+	})
+
+	return volume, host, err
+}
@@ -0,0 +1,193 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package glusterfs
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gorilla/mux"
+	client "github.com/heketi/heketi/client/api/go-client"
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+	"github.com/heketi/tests"
+)
+
+func TestVolumeRebalanceStatus(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Create a client
+	c := client.NewClientNoAuth(ts.URL)
+	tests.Assert(t, c != nil)
+
+	err := setupSampleDbWithTopology(app,
+		1,    // clusters
+		4,    // nodes_per_cluster
+		2,    // devices_per_node,
+		5*TB, // disksize
+	)
+	tests.Assert(t, err == nil)
+
+	v := createSampleVolumeEntry(100)
+	err = v.Create(app.db, app.executor, app.allocator)
+	tests.Assert(t, err == nil)
+
+	// Unknown volume
+	_, err = c.VolumeRebalanceStatus("123")
+	tests.Assert(t, err != nil)
+
+	app.xo.MockVolumeRebalanceStatus = func(host, volume string) (*executors.VolumeRebalanceInfo, error) {
+		tests.Assert(t, volume == v.Info.Name)
+		return &executors.VolumeRebalanceInfo{
+			Status: "in progress",
+			Nodes: []executors.NodeRebalanceInfo{
+				executors.NodeRebalanceInfo{
+					Name:     "host1",
+					Status:   "in progress",
+					Files:    10,
+					Size:     2048,
+					Lookups:  20,
+					Failures: 1,
+					Skipped:  2,
+					RunTime:  4.5,
+				},
+			},
+		}, nil
+	}
+
+	status, err := c.VolumeRebalanceStatus(v.Info.Id)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, status.Id == v.Info.Id)
+	tests.Assert(t, status.Status == "in progress")
+	tests.Assert(t, len(status.Nodes) == 1)
+	tests.Assert(t, status.Nodes[0].Name == "host1")
+	tests.Assert(t, status.Nodes[0].Files == 10)
+	tests.Assert(t, status.Nodes[0].Size == 2048)
+	tests.Assert(t, status.Nodes[0].Lookups == 20)
+	tests.Assert(t, status.Nodes[0].Failures == 1)
+	tests.Assert(t, status.Nodes[0].Skipped == 2)
+	tests.Assert(t, status.Nodes[0].RunTime == 4.5)
+
+	// Status cannot be read
+	app.xo.MockVolumeRebalanceStatus = func(host, volume string) (*executors.VolumeRebalanceInfo, error) {
+		return nil, errors.New("Rebalance not started")
+	}
+
+	_, err = c.VolumeRebalanceStatus(v.Info.Id)
+	tests.Assert(t, err != nil)
+}
+
+func TestVolumeRebalance(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Create a client
+	c := client.NewClientNoAuth(ts.URL)
+	tests.Assert(t, c != nil)
+
+	err := setupSampleDbWithTopology(app,
+		1,    // clusters
+		4,    // nodes_per_cluster
+		2,    // devices_per_node,
+		5*TB, // disksize
+	)
+	tests.Assert(t, err == nil)
+
+	v := createSampleVolumeEntry(100)
+	err = v.Create(app.db, app.executor, app.allocator)
+	tests.Assert(t, err == nil)
+
+	// Bad JSON
+	r, err := http.Post(ts.URL+"/volumes/"+v.Info.Id+"/rebalance",
+		"application/json",
+		bytes.NewBuffer([]byte(`{ bad json`)))
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == 422)
+
+	// Unknown action
+	_, err = c.VolumeRebalance(v.Info.Id, &api.VolumeRebalanceRequest{
+		Action: "pause",
+	})
+	tests.Assert(t, err != nil)
+
+	// Unknown volume
+	_, err = c.VolumeRebalance("123", &api.VolumeRebalanceRequest{
+		Action: "start",
+	})
+	tests.Assert(t, err != nil)
+
+	started, stopped := false, false
+	app.xo.MockVolumeRebalanceStart = func(host, volume string) error {
+		tests.Assert(t, volume == v.Info.Name)
+		started = true
+		return nil
+	}
+	app.xo.MockVolumeRebalanceStop = func(host, volume string) error {
+		tests.Assert(t, volume == v.Info.Name)
+		stopped = true
+		return nil
+	}
+
+	status, err := c.VolumeRebalance(v.Info.Id, &api.VolumeRebalanceRequest{
+		Action: "start",
+	})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, started)
+	tests.Assert(t, !stopped)
+	tests.Assert(t, status.Id == v.Info.Id)
+
+	_, err = c.VolumeRebalance(v.Info.Id, &api.VolumeRebalanceRequest{
+		Action: "stop",
+	})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, stopped)
+
+	// Rebalance fails to start
+	app.xo.MockVolumeRebalanceStart = func(host, volume string) error {
+		return errors.New("Rebalance on volume is already started")
+	}
+
+	_, err = c.VolumeRebalance(v.Info.Id, &api.VolumeRebalanceRequest{
+		Action: "start",
+	})
+	tests.Assert(t, err != nil)
+}
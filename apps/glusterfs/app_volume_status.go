@@ -0,0 +1,119 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package glusterfs
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/mux"
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+)
+
+// Returns the live status of each brick in the volume as
+// reported by gluster
+func (a *App) VolumeStatus(w http.ResponseWriter, r *http.Request) {
+	// Get the id from the URL
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var (
+		volume *VolumeEntry
+		host   string
+		bricks map[string]string
+	)
+	err := a.db.View(func(tx *bolt.Tx) error {
+		var err error
+		volume, err = NewVolumeEntryFromId(tx, id)
+		if err == ErrNotFound {
+			http.Error(w, "Id not found", http.StatusNotFound)
+			return err
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		host, bricks, err = volume.brickHosts(tx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	status, err := a.executor.VolumeStatus(host, volume.Info.Name)
+	if err != nil {
+		logger.Err(err)
+		http.Error(w, err.Error(), executorErrorStatus(err))
+		return
+	}
+
+	msg := &api.VolumeStatusResponse{
+		Id:     id,
+		Bricks: make([]api.BrickStatusInfo, 0, len(bricks)),
+	}
+	reported := make(map[string]bool)
+	for _, brick := range status.Bricks {
+		reported[brick.Name] = true
+		msg.Bricks = append(msg.Bricks, api.BrickStatusInfo{
+			Id:     bricks[brick.Name],
+			Name:   brick.Name,
+			Online: brick.Online,
+			Port:   brick.Port,
+			Pid:    brick.Pid,
+			Storage: api.StorageSize{
+				Total: brick.SizeTotal / 1024,
+				Free:  brick.SizeFree / 1024,
+				Used:  (brick.SizeTotal - brick.SizeFree) / 1024,
+			},
+			InodesTotal: brick.InodesTotal,
+			InodesFree:  brick.InodesFree,
+		})
+		if !brick.Online {
+			msg.Degraded = true
+		}
+	}
+
+	// Bricks gluster did not report on are shown as offline
+	missing := make(sort.StringSlice, 0)
+	for name := range bricks {
+		if !reported[name] {
+			missing = append(missing, name)
+		}
+	}
+	missing.Sort()
+	for _, name := range missing {
+		msg.Bricks = append(msg.Bricks, api.BrickStatusInfo{
+			Id:   bricks[name],
+			Name: name,
+		})
+		msg.Degraded = true
+	}
+
+	// Write msg
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(msg); err != nil {
+		panic(err)
+	}
+}
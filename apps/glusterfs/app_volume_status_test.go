@@ -0,0 +1,177 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package glusterfs
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/mux"
+	client "github.com/heketi/heketi/client/api/go-client"
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/tests"
+)
+
+func TestVolumeStatus(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Create a client
+	c := client.NewClientNoAuth(ts.URL)
+	tests.Assert(t, c != nil)
+
+	err := setupSampleDbWithTopology(app,
+		1,    // clusters
+		4,    // nodes_per_cluster
+		2,    // devices_per_node,
+		5*TB, // disksize
+	)
+	tests.Assert(t, err == nil)
+
+	v := createSampleVolumeEntry(100)
+	err = v.Create(app.db, app.executor, app.allocator)
+	tests.Assert(t, err == nil)
+
+	// Names of the bricks as gluster reports them
+	names := make(map[string]string)
+	err = app.db.View(func(tx *bolt.Tx) error {
+		for _, id := range v.BricksIds() {
+			brick, err := NewBrickEntryFromId(tx, id)
+			if err != nil {
+				return err
+			}
+			node, err := NewNodeEntryFromId(tx, brick.Info.NodeId)
+			if err != nil {
+				return err
+			}
+			names[node.StorageHostName()+":"+brick.Info.Path] = id
+		}
+		return nil
+	})
+	tests.Assert(t, err == nil)
+	tests.Assert(t, len(names) > 1)
+
+	// Unknown volume
+	_, err = c.VolumeStatus("123")
+	tests.Assert(t, err != nil)
+
+	// All bricks online
+	app.xo.MockVolumeStatus = func(host, volume string) (*executors.VolumeStatusInfo, error) {
+		tests.Assert(t, volume == v.Info.Name)
+		info := &executors.VolumeStatusInfo{}
+		for name := range names {
+			info.Bricks = append(info.Bricks, executors.BrickStatusInfo{
+				Name:        name,
+				Online:      true,
+				Port:        49152,
+				Pid:         1000,
+				SizeTotal:   4 * 1024 * 1024,
+				SizeFree:    3 * 1024 * 1024,
+				InodesTotal: 100,
+				InodesFree:  90,
+			})
+		}
+		return info, nil
+	}
+
+	status, err := c.VolumeStatus(v.Info.Id)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, status.Id == v.Info.Id)
+	tests.Assert(t, !status.Degraded)
+	tests.Assert(t, len(status.Bricks) == len(names))
+	for _, brick := range status.Bricks {
+		tests.Assert(t, brick.Id == names[brick.Name], brick.Name)
+		tests.Assert(t, brick.Online)
+		tests.Assert(t, brick.Port == 49152)
+		tests.Assert(t, brick.Pid == 1000)
+		tests.Assert(t, brick.Storage.Total == 4*1024)
+		tests.Assert(t, brick.Storage.Free == 3*1024)
+		tests.Assert(t, brick.Storage.Used == 1024)
+		tests.Assert(t, brick.InodesTotal == 100)
+		tests.Assert(t, brick.InodesFree == 90)
+	}
+
+	// One brick is not reported at all
+	var missing string
+	for name := range names {
+		missing = name
+		break
+	}
+	app.xo.MockVolumeStatus = func(host, volume string) (*executors.VolumeStatusInfo, error) {
+		info := &executors.VolumeStatusInfo{}
+		for name := range names {
+			if name == missing {
+				continue
+			}
+			info.Bricks = append(info.Bricks, executors.BrickStatusInfo{
+				Name:   name,
+				Online: true,
+			})
+		}
+		return info, nil
+	}
+
+	status, err = c.VolumeStatus(v.Info.Id)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, status.Degraded)
+	tests.Assert(t, len(status.Bricks) == len(names))
+	for _, brick := range status.Bricks {
+		tests.Assert(t, brick.Online == (brick.Name != missing))
+		tests.Assert(t, brick.Id == names[brick.Name])
+	}
+
+	// Status cannot be read
+	app.xo.MockVolumeStatus = func(host, volume string) (*executors.VolumeStatusInfo, error) {
+		return nil, errors.New("Volume does not exist")
+	}
+
+	_, err = c.VolumeStatus(v.Info.Id)
+	tests.Assert(t, err != nil)
+
+	// Errors reaching the node are told apart from failed commands
+	for _, test := range []struct {
+		err    error
+		status int
+	}{
+		{&executors.CommandFailedError{Host: "host"}, http.StatusInternalServerError},
+		{&executors.HostUnreachableError{Host: "host", Err: errors.New("refused")}, http.StatusBadGateway},
+		{&executors.CommandTimeoutError{Host: "host"}, http.StatusGatewayTimeout},
+	} {
+		app.xo.MockVolumeStatus = func(host, volume string) (*executors.VolumeStatusInfo, error) {
+			return nil, test.err
+		}
+
+		r, err := http.Get(ts.URL + "/volumes/" + v.Info.Id + "/status")
+		tests.Assert(t, err == nil)
+		tests.Assert(t, r.StatusCode == test.status, r.StatusCode)
+		r.Body.Close()
+	}
+}
@@ -305,6 +305,47 @@ func TestVolumeCreateBadClusters(t *testing.T) {
 	tests.Assert(t, strings.Contains(string(body), "Cluster id bad not found"))
 }
 
+func TestVolumeCreateNoClustersMatchSelector(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Setup database
+	err := setupSampleDbWithTopology(app,
+		1,    // clusters
+		10,   // nodes_per_cluster
+		10,   // devices_per_node,
+		5*TB, // disksize)
+	)
+	tests.Assert(t, err == nil)
+
+	// VolumeCreate JSON Request
+	request := []byte(`{
+        "size" : 10,
+        "cluster_selector" : {
+            "purpose" : "block"
+        }
+    }`)
+
+	// Send request
+	r, err := http.Post(ts.URL+"/volumes", "application/json", bytes.NewBuffer(request))
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusBadRequest)
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, r.ContentLength))
+	tests.Assert(t, err == nil)
+	r.Body.Close()
+	tests.Assert(t, strings.Contains(string(body), "No clusters match"))
+}
+
 func TestVolumeCreateBadSnapshotFactor(t *testing.T) {
 	tmpfile := tests.Tempfile()
 	defer os.Remove(tmpfile)
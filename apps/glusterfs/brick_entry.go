@@ -30,6 +30,11 @@ type BrickEntry struct {
 	Info             api.BrickInfo
 	TpSize           uint64
 	PoolMetadataSize uint64
+
+	// Volume of the brick and the id of the first brick in its
+	// replica set.  Not set in bricks created by older versions.
+	VolumeId   string
+	BrickSetId string
 }
 
 func BrickList(tx *bolt.Tx) ([]string, error) {
@@ -124,13 +129,13 @@ func (b *BrickEntry) Unmarshal(buffer []byte) error {
 	return nil
 }
 
-func (b *BrickEntry) Create(db *bolt.DB, executor executors.Executor) error {
-	godbc.Require(db != nil)
-	godbc.Require(b.TpSize > 0)
-	godbc.Require(b.Info.Size > 0)
-
-	// Get node hostname
-	var host string
+// Returns the management hostname of the node of the brick and
+// how its cluster formats bricks
+func (b *BrickEntry) hostAndFormat(db *bolt.DB) (string, executors.BrickFormat, error) {
+	var (
+		host   string
+		format executors.BrickFormat
+	)
 	err := db.View(func(tx *bolt.Tx) error {
 		node, err := NewNodeEntryFromId(tx, b.Info.NodeId)
 		if err != nil {
@@ -139,8 +144,28 @@ func (b *BrickEntry) Create(db *bolt.DB, executor executors.Executor) error {
 
 		host = node.ManageHostName()
 		godbc.Check(host != "")
+
+		if node.Info.ClusterId == "" {
+			return nil
+		}
+		cluster, err := NewClusterEntryFromId(tx, node.Info.ClusterId)
+		if err != nil {
+			return err
+		}
+		format = clusterBrickFormat(&cluster.Info.Settings)
 		return nil
 	})
+
+	return host, format, err
+}
+
+func (b *BrickEntry) Create(db *bolt.DB, executor executors.Executor) error {
+	godbc.Require(db != nil)
+	godbc.Require(b.TpSize > 0)
+	godbc.Require(b.Info.Size > 0)
+
+	// Get node hostname, and how the cluster formats bricks
+	host, format, err := b.hostAndFormat(db)
 	if err != nil {
 		return err
 	}
@@ -152,6 +177,7 @@ func (b *BrickEntry) Create(db *bolt.DB, executor executors.Executor) error {
 	req.TpSize = b.TpSize
 	req.VgId = b.Info.DeviceId
 	req.PoolMetadataSize = b.PoolMetadataSize
+	req.Format = format
 
 	// Create brick on node
 	logger.Info("Creating brick %v", b.Info.Id)
@@ -172,18 +198,8 @@ func (b *BrickEntry) Destroy(db *bolt.DB, executor executors.Executor) error {
 	godbc.Require(b.TpSize > 0)
 	godbc.Require(b.Info.Size > 0)
 
-	// Get node hostname
-	var host string
-	err := db.View(func(tx *bolt.Tx) error {
-		node, err := NewNodeEntryFromId(tx, b.Info.NodeId)
-		if err != nil {
-			return err
-		}
-
-		host = node.ManageHostName()
-		godbc.Check(host != "")
-		return nil
-	})
+	// Get node hostname, and how the cluster formats bricks
+	host, format, err := b.hostAndFormat(db)
 	if err != nil {
 		return err
 	}
@@ -194,6 +210,8 @@ func (b *BrickEntry) Destroy(db *bolt.DB, executor executors.Executor) error {
 	req.Size = b.Info.Size
 	req.TpSize = b.TpSize
 	req.VgId = b.Info.DeviceId
+	req.Path = b.Info.Path
+	req.Format = format
 
 	// Delete brick on node
 	logger.Info("Deleting brick %v", b.Info.Id)
@@ -210,18 +228,8 @@ func (b *BrickEntry) DestroyCheck(db *bolt.DB, executor executors.Executor) erro
 	godbc.Require(b.TpSize > 0)
 	godbc.Require(b.Info.Size > 0)
 
-	// Get node hostname
-	var host string
-	err := db.View(func(tx *bolt.Tx) error {
-		node, err := NewNodeEntryFromId(tx, b.Info.NodeId)
-		if err != nil {
-			return err
-		}
-
-		host = node.ManageHostName()
-		godbc.Check(host != "")
-		return nil
-	})
+	// Get node hostname, and how the cluster formats bricks
+	host, format, err := b.hostAndFormat(db)
 	if err != nil {
 		return err
 	}
@@ -232,6 +240,8 @@ func (b *BrickEntry) DestroyCheck(db *bolt.DB, executor executors.Executor) erro
 	req.Size = b.Info.Size
 	req.TpSize = b.TpSize
 	req.VgId = b.Info.DeviceId
+	req.Path = b.Info.Path
+	req.Format = format
 
 	// Check brick on node
 	return executor.BrickDestroyCheck(host, req)
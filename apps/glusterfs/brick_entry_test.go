@@ -223,3 +223,65 @@ func TestBrickEntryDestroyCheck(t *testing.T) {
 	err = b.DestroyCheck(app.db, app.executor)
 	tests.Assert(t, err == nil, err)
 }
+
+func TestBrickEntryCreateClusterFormat(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+
+	// Cluster which formats its bricks its own way
+	c := createSampleClusterEntry()
+	err := c.SetSettings(&api.ClusterSettings{
+		BrickMkfsOptions: "-i size=512 -d su=128k,sw=10",
+		BrickChunkSize:   1280,
+		BrickMountRoot:   "/srv/heketi",
+	})
+	tests.Assert(t, err == nil, err)
+
+	n := NewNodeEntry()
+	n.Info.Id = "node"
+	n.Info.ClusterId = c.Info.Id
+	n.Info.Hostnames.Manage = []string{"manage"}
+	n.Info.Hostnames.Storage = []string{"storage"}
+
+	b := NewBrickEntry(10, 20, 5, "abc", "node")
+	err = app.db.Update(func(tx *bolt.Tx) error {
+		err := c.Save(tx)
+		tests.Assert(t, err == nil)
+		err = n.Save(tx)
+		tests.Assert(t, err == nil)
+		return b.Save(tx)
+	})
+	tests.Assert(t, err == nil)
+
+	var request *executors.BrickRequest
+	app.xo.MockBrickCreate = func(host string, brick *executors.BrickRequest) (*executors.BrickInfo, error) {
+		request = brick
+		return &executors.BrickInfo{
+			Path: "/srv/heketi/vg_abc/brick_" + brick.Name + "/brick",
+		}, nil
+	}
+	app.xo.MockBrickDestroy = func(host string, brick *executors.BrickRequest) error {
+		request = brick
+		return nil
+	}
+
+	// The settings of the cluster are sent with the brick
+	err = b.Create(app.db, app.executor)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, request.Path == "")
+	tests.Assert(t, request.Format == executors.BrickFormat{
+		MkfsOptions: "-i size=512 -d su=128k,sw=10",
+		ChunkSize:   1280,
+		MountRoot:   "/srv/heketi",
+	}, request.Format)
+
+	// The brick is destroyed where it was created
+	err = b.Destroy(app.db, app.executor)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, request.Path == b.Info.Path)
+	tests.Assert(t, request.Format.MountRoot == "/srv/heketi")
+}
@@ -19,9 +19,12 @@ package glusterfs
 import (
 	"bytes"
 	"encoding/gob"
+	"errors"
+	"fmt"
 	"sort"
 
 	"github.com/boltdb/bolt"
+	"github.com/heketi/heketi/executors"
 	"github.com/heketi/heketi/pkg/glusterfs/api"
 	"github.com/heketi/utils"
 	"github.com/lpabon/godbc"
@@ -40,6 +43,30 @@ func ClusterList(tx *bolt.Tx) ([]string, error) {
 	return list, nil
 }
 
+// Returns the clusters in the list which match the selector
+func ClusterListMatching(tx *bolt.Tx,
+	clusters []string,
+	selector map[string]string) ([]string, error) {
+
+	if len(selector) == 0 {
+		return clusters, nil
+	}
+
+	matching := make([]string, 0)
+	for _, id := range clusters {
+		entry, err := NewClusterEntryFromId(tx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		if entry.MatchesSelector(selector) {
+			matching = append(matching, id)
+		}
+	}
+
+	return matching, nil
+}
+
 func NewClusterEntry() *ClusterEntry {
 	entry := &ClusterEntry{}
 	entry.Info.Nodes = make(sort.StringSlice, 0)
@@ -149,3 +176,75 @@ func (c *ClusterEntry) VolumeDelete(id string) {
 func (c *ClusterEntry) NodeDelete(id string) {
 	c.Info.Nodes = utils.SortedStringsDelete(c.Info.Nodes, id)
 }
+
+// Checks and sets the settings of the cluster
+func (c *ClusterEntry) SetSettings(settings *api.ClusterSettings) error {
+	if settings.BrickMinSize < 0 ||
+		settings.BrickMaxSize < 0 ||
+		settings.BrickMaxNum < 0 {
+		return errors.New("Cluster limits cannot be negative")
+	}
+
+	// Check the effective sizes
+	limitsLock.RLock()
+	limits := NewBrickLimits(settings)
+	limitsLock.RUnlock()
+	if limits.MinSize > limits.MaxSize {
+		return fmt.Errorf("Minimum brick size %v GB is larger than maximum brick size %v GB",
+			limits.MinSize/GB, limits.MaxSize/GB)
+	}
+
+	if err := validateDurability(&settings.Durability); err != nil {
+		return err
+	}
+
+	format := clusterBrickFormat(settings)
+	if err := format.Validate(); err != nil {
+		return err
+	}
+
+	c.Info.Settings = *settings
+
+	return nil
+}
+
+// Returns how the bricks of a cluster with the given settings are
+// formatted and mounted.  Settings which are not set use those of
+// the executor.
+func clusterBrickFormat(settings *api.ClusterSettings) executors.BrickFormat {
+	return executors.BrickFormat{
+		MkfsOptions:  settings.BrickMkfsOptions,
+		MountOptions: settings.BrickMountOptions,
+		ChunkSize:    settings.BrickChunkSize,
+		MountRoot:    settings.BrickMountRoot,
+	}
+}
+
+// Checks and sets the labels of the cluster
+func (c *ClusterEntry) SetLabels(labels map[string]string) error {
+	for key := range labels {
+		if key == "" {
+			return errors.New("Cluster label keys cannot be empty")
+		}
+	}
+
+	c.Info.Labels = labels
+
+	return nil
+}
+
+// Returns true if the cluster has all the labels in the selector
+func (c *ClusterEntry) MatchesSelector(selector map[string]string) bool {
+	return matchesSelector(c.Info.Labels, selector)
+}
+
+// Returns true if all the key/values in the selector are in labels
+func matchesSelector(labels, selector map[string]string) bool {
+	for key, value := range selector {
+		if label, ok := labels[key]; !ok || label != value {
+			return false
+		}
+	}
+
+	return true
+}
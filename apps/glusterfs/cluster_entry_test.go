@@ -362,3 +362,145 @@ func TestNewClusterEntryNewInfoResponse(t *testing.T) {
 	tests.Assert(t, reflect.DeepEqual(info.Nodes, c.Info.Nodes))
 	tests.Assert(t, reflect.DeepEqual(info.Volumes, c.Info.Volumes))
 }
+
+func TestClusterEntrySetSettings(t *testing.T) {
+	c := createSampleClusterEntry()
+
+	// Negative values
+	err := c.SetSettings(&api.ClusterSettings{
+		BrickMaxNum: -1,
+	})
+	tests.Assert(t, err != nil)
+
+	// Minimum larger than maximum
+	err = c.SetSettings(&api.ClusterSettings{
+		BrickMinSize: 10,
+		BrickMaxSize: 5,
+	})
+	tests.Assert(t, err != nil)
+
+	// Minimum larger than the server maximum
+	err = c.SetSettings(&api.ClusterSettings{
+		BrickMinSize: int(BrickMaxSize/GB) + 1,
+	})
+	tests.Assert(t, err != nil)
+
+	// Bad durability
+	settings := &api.ClusterSettings{}
+	settings.Durability.Type = api.DurabilityEC
+	settings.Durability.Disperse.Data = 3
+	settings.Durability.Disperse.Redundancy = 1
+	err = c.SetSettings(settings)
+	tests.Assert(t, err != nil)
+	tests.Assert(t, c.Info.Settings.Durability.Type == "")
+
+	// Bad brick format
+	err = c.SetSettings(&api.ClusterSettings{
+		BrickChunkSize: 100,
+	})
+	tests.Assert(t, err != nil)
+	err = c.SetSettings(&api.ClusterSettings{
+		BrickMountOptions: "rw;reboot",
+	})
+	tests.Assert(t, err != nil)
+	err = c.SetSettings(&api.ClusterSettings{
+		BrickMountRoot: "mounts",
+	})
+	tests.Assert(t, err != nil)
+
+	// Good settings
+	settings.Durability.Disperse.Data = 8
+	settings.Durability.Disperse.Redundancy = 3
+	settings.BrickMinSize = 1
+	settings.BrickMaxSize = 100
+	settings.BrickChunkSize = 512
+	settings.BrickMountRoot = "/srv/heketi"
+	err = c.SetSettings(settings)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, reflect.DeepEqual(c.Info.Settings, *settings))
+
+	// Check limits
+	limits := NewBrickLimits(&c.Info.Settings)
+	tests.Assert(t, limits.MinSize == 1*GB)
+	tests.Assert(t, limits.MaxSize == 100*GB)
+	tests.Assert(t, limits.MaxNum == BrickMaxNum)
+}
+
+func TestClusterEntryLabels(t *testing.T) {
+	c := createSampleClusterEntry()
+
+	// Empty keys are not allowed
+	err := c.SetLabels(map[string]string{"": "block"})
+	tests.Assert(t, err != nil)
+
+	err = c.SetLabels(map[string]string{
+		"purpose": "block",
+		"disk":    "ssd",
+	})
+	tests.Assert(t, err == nil)
+
+	// Everything matches an empty selector
+	tests.Assert(t, c.MatchesSelector(nil))
+	tests.Assert(t, c.MatchesSelector(map[string]string{}))
+
+	tests.Assert(t, c.MatchesSelector(map[string]string{"purpose": "block"}))
+	tests.Assert(t, c.MatchesSelector(map[string]string{
+		"purpose": "block",
+		"disk":    "ssd",
+	}))
+	tests.Assert(t, !c.MatchesSelector(map[string]string{"purpose": "file"}))
+	tests.Assert(t, !c.MatchesSelector(map[string]string{
+		"purpose": "block",
+		"zone":    "east",
+	}))
+}
+
+func TestClusterListMatching(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+
+	block := createSampleClusterEntry()
+	block.SetLabels(map[string]string{"purpose": "block"})
+	file := createSampleClusterEntry()
+	file.SetLabels(map[string]string{"purpose": "file"})
+	err := app.db.Update(func(tx *bolt.Tx) error {
+		err := block.Save(tx)
+		if err != nil {
+			return err
+		}
+		return file.Save(tx)
+	})
+	tests.Assert(t, err == nil)
+
+	err = app.db.View(func(tx *bolt.Tx) error {
+		clusters, err := ClusterList(tx)
+		tests.Assert(t, err == nil)
+
+		matching, err := ClusterListMatching(tx, clusters, nil)
+		tests.Assert(t, err == nil)
+		tests.Assert(t, len(matching) == 2)
+
+		matching, err = ClusterListMatching(tx, clusters,
+			map[string]string{"purpose": "block"})
+		tests.Assert(t, err == nil)
+		tests.Assert(t, len(matching) == 1)
+		tests.Assert(t, matching[0] == block.Info.Id)
+
+		matching, err = ClusterListMatching(tx, clusters,
+			map[string]string{"purpose": "other"})
+		tests.Assert(t, err == nil)
+		tests.Assert(t, len(matching) == 0)
+
+		// Unknown cluster
+		_, err = ClusterListMatching(tx, []string{"abc"},
+			map[string]string{"purpose": "block"})
+		tests.Assert(t, err == ErrNotFound)
+
+		return nil
+	})
+	tests.Assert(t, err == nil)
+}
@@ -68,6 +68,7 @@ func NewDeviceEntryFromRequest(req *api.DeviceAddRequest) *DeviceEntry {
 	device := NewDeviceEntry()
 	device.Info.Id = utils.GenUUID()
 	device.Info.Name = req.Name
+	device.Info.Tags = req.Tags
 	device.NodeId = req.NodeId
 
 	return device
@@ -244,6 +245,44 @@ func (d *DeviceEntry) SetState(tx *bolt.Tx,
 	return nil
 }
 
+// Replaces the tags of the device.  If the device is being used
+// by the allocator, it is updated with the new tags.  Devices of
+// nodes which are not online are not in the allocator.
+func (d *DeviceEntry) SetTags(tx *bolt.Tx,
+	a Allocator,
+	tags map[string]string) error {
+
+	for key := range tags {
+		if key == "" {
+			return errors.New("Device tag keys cannot be empty")
+		}
+	}
+
+	node, err := NewNodeEntryFromId(tx, d.NodeId)
+	if err != nil {
+		return err
+	}
+
+	if !d.isOnline() || !node.isOnline() {
+		d.Info.Tags = tags
+		return nil
+	}
+
+	err = d.removeDeviceFromRing(tx, a)
+	if err != nil {
+		return err
+	}
+
+	d.Info.Tags = tags
+
+	return d.addDeviceToRing(tx, a)
+}
+
+// Returns true if the device has all the tags in the selector
+func (d *DeviceEntry) MatchesSelector(selector map[string]string) bool {
+	return matchesSelector(d.Info.Tags, selector)
+}
+
 func (d *DeviceEntry) NewInfoResponse(tx *bolt.Tx) (*api.DeviceInfoResponse, error) {
 
 	godbc.Require(tx != nil)
@@ -252,6 +291,7 @@ func (d *DeviceEntry) NewInfoResponse(tx *bolt.Tx) (*api.DeviceInfoResponse, err
 	info.Id = d.Info.Id
 	info.Name = d.Info.Name
 	info.Storage = d.Info.Storage
+	info.Tags = d.Info.Tags
 	info.State = d.State
 	info.Bricks = make([]api.BrickInfo, 0)
 
@@ -311,6 +351,33 @@ func (d *DeviceEntry) StorageSet(amount uint64) {
 	d.Info.Storage.Total = amount
 }
 
+// Sets the total size of the device and recalculates the used and
+// free storage from the bricks allocated on it
+func (d *DeviceEntry) StorageResync(tx *bolt.Tx, total uint64) error {
+	godbc.Require(tx != nil)
+
+	var used uint64
+	for _, id := range d.Bricks {
+		brick, err := NewBrickEntryFromId(tx, id)
+		if err != nil {
+			return err
+		}
+		used += brick.TotalSize()
+	}
+
+	if used > total {
+		return fmt.Errorf("Device %v has %v KB allocated to bricks, "+
+			"which is more than its new size of %v KB",
+			d.Info.Id, used, total)
+	}
+
+	d.Info.Storage.Total = total
+	d.Info.Storage.Used = used
+	d.Info.Storage.Free = total - used
+
+	return nil
+}
+
 func (d *DeviceEntry) StorageAllocate(amount uint64) {
 	d.Info.Storage.Free -= amount
 	d.Info.Storage.Used += amount
@@ -692,3 +692,178 @@ func TestDeviceSetStateOfflineOnline(t *testing.T) {
 
 	})
 }
+
+func TestDeviceEntrySetTags(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+
+	// Create a cluster with one device
+	err := setupSampleDbWithTopology(app,
+		1,      // clusters
+		1,      // nodes_per_cluster
+		1,      // devices_per_node,
+		500*GB, // disksize)
+	)
+	tests.Assert(t, err == nil)
+
+	var clusterId, deviceId string
+	err = app.db.Update(func(tx *bolt.Tx) error {
+		clusters, err := ClusterList(tx)
+		if err != nil {
+			return err
+		}
+		clusterId = clusters[0]
+
+		devices, err := DeviceList(tx)
+		if err != nil {
+			return err
+		}
+		deviceId = devices[0]
+
+		device, err := NewDeviceEntryFromId(tx, deviceId)
+		if err != nil {
+			return err
+		}
+
+		// Empty keys are not allowed
+		err = device.SetTags(tx, app.allocator, map[string]string{"": "ssd"})
+		tests.Assert(t, err != nil)
+
+		err = device.SetTags(tx, app.allocator, map[string]string{"class": "ssd"})
+		tests.Assert(t, err == nil)
+		tests.Assert(t, device.MatchesSelector(map[string]string{"class": "ssd"}))
+		tests.Assert(t, !device.MatchesSelector(map[string]string{"class": "hdd"}))
+
+		return device.Save(tx)
+	})
+	tests.Assert(t, err == nil)
+
+	// The allocator uses the new tags
+	ch, _, errc := app.allocator.GetNodes(clusterId, utils.GenUUID(),
+		map[string]string{"class": "ssd"})
+	devices := 0
+	for d := range ch {
+		devices++
+		tests.Assert(t, d == deviceId)
+	}
+	err = <-errc
+	tests.Assert(t, err == nil)
+	tests.Assert(t, devices == 1)
+
+	// Tags are saved
+	err = app.db.View(func(tx *bolt.Tx) error {
+		device, err := NewDeviceEntryFromId(tx, deviceId)
+		if err != nil {
+			return err
+		}
+		tests.Assert(t, device.Info.Tags["class"] == "ssd")
+
+		info, err := device.NewInfoResponse(tx)
+		if err != nil {
+			return err
+		}
+		tests.Assert(t, info.Tags["class"] == "ssd")
+		return nil
+	})
+	tests.Assert(t, err == nil)
+
+	// Tagging a device of an offline node does not return it to the
+	// allocator
+	err = app.db.Update(func(tx *bolt.Tx) error {
+		device, err := NewDeviceEntryFromId(tx, deviceId)
+		if err != nil {
+			return err
+		}
+
+		node, err := NewNodeEntryFromId(tx, device.NodeId)
+		if err != nil {
+			return err
+		}
+		err = node.SetState(tx, app.allocator, api.EntryStateOffline)
+		if err != nil {
+			return err
+		}
+		err = node.Save(tx)
+		if err != nil {
+			return err
+		}
+
+		err = device.SetTags(tx, app.allocator, map[string]string{"class": "hdd"})
+		tests.Assert(t, err == nil)
+		tests.Assert(t, device.Info.Tags["class"] == "hdd")
+
+		return device.Save(tx)
+	})
+	tests.Assert(t, err == nil)
+
+	ch, _, errc = app.allocator.GetNodes(clusterId, utils.GenUUID(), nil)
+	devices = 0
+	for range ch {
+		devices++
+	}
+	err = <-errc
+	tests.Assert(t, err == nil)
+	tests.Assert(t, devices == 0, devices)
+}
+
+func TestDeviceEntryStorageResync(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+
+	// Create a device with two bricks
+	d := NewDeviceEntry()
+	d.Info.Id = "abc"
+	d.StorageSet(1000)
+
+	err := app.db.Update(func(tx *bolt.Tx) error {
+		for _, id := range []string{"b1", "b2"} {
+			b := &BrickEntry{}
+			b.Info.Id = id
+			b.TpSize = 100
+			b.PoolMetadataSize = 10
+			d.BrickAdd(id)
+			d.StorageAllocate(b.TotalSize())
+			if err := b.Save(tx); err != nil {
+				return err
+			}
+		}
+		return d.Save(tx)
+	})
+	tests.Assert(t, err == nil)
+
+	// Device has grown
+	err = app.db.Update(func(tx *bolt.Tx) error {
+		return d.StorageResync(tx, 2000)
+	})
+	tests.Assert(t, err == nil)
+	tests.Assert(t, d.Info.Storage.Total == 2000)
+	tests.Assert(t, d.Info.Storage.Used == 220)
+	tests.Assert(t, d.Info.Storage.Free == 1780)
+
+	// Used storage is taken from the bricks
+	d.Info.Storage.Used = 5
+	err = app.db.Update(func(tx *bolt.Tx) error {
+		return d.StorageResync(tx, 500)
+	})
+	tests.Assert(t, err == nil)
+	tests.Assert(t, d.Info.Storage.Total == 500)
+	tests.Assert(t, d.Info.Storage.Used == 220)
+	tests.Assert(t, d.Info.Storage.Free == 280)
+
+	// Device is smaller than its bricks
+	err = app.db.Update(func(tx *bolt.Tx) error {
+		return d.StorageResync(tx, 200)
+	})
+	tests.Assert(t, err != nil)
+	tests.Assert(t, d.Info.Storage.Total == 500)
+	tests.Assert(t, d.Info.Storage.Used == 220)
+	tests.Assert(t, d.Info.Storage.Free == 280)
+}
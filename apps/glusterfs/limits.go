@@ -16,9 +16,68 @@
 
 package glusterfs
 
-var (
+import (
+	"sync"
+
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+)
+
+const (
 	// Default limits
-	BrickMinSize = uint64(4 * GB)
-	BrickMaxSize = uint64(4 * TB)
-	BrickMaxNum  = 100
+	defaultBrickMinSize = uint64(4 * GB)
+	defaultBrickMaxSize = uint64(4 * TB)
+	defaultBrickMaxNum  = 100
+)
+
+var (
+	// Limits currently in use.  These can be changed by the
+	// configuration file and reloaded while the server is running
+	BrickMinSize = defaultBrickMinSize
+	BrickMaxSize = defaultBrickMaxSize
+	BrickMaxNum  = defaultBrickMaxNum
+
+	// Protects the limits above when the configuration is reloaded
+	limitsLock sync.RWMutex
 )
+
+// Must be called with limitsLock held
+func resetLimits() {
+	BrickMinSize = defaultBrickMinSize
+	BrickMaxSize = defaultBrickMaxSize
+	BrickMaxNum = defaultBrickMaxNum
+}
+
+// Limits used when allocating bricks in a cluster
+type BrickLimits struct {
+	// Sizes in KB
+	MinSize uint64
+	MaxSize uint64
+	MaxNum  int
+}
+
+// Returns the limits for a cluster with the given settings.  Settings
+// which are not set, or a nil settings, use the server limits.
+// Must be called with limitsLock held.
+func NewBrickLimits(settings *api.ClusterSettings) *BrickLimits {
+	limits := &BrickLimits{
+		MinSize: BrickMinSize,
+		MaxSize: BrickMaxSize,
+		MaxNum:  BrickMaxNum,
+	}
+
+	if settings == nil {
+		return limits
+	}
+
+	if settings.BrickMinSize != 0 {
+		limits.MinSize = uint64(settings.BrickMinSize) * GB
+	}
+	if settings.BrickMaxSize != 0 {
+		limits.MaxSize = uint64(settings.BrickMaxSize) * GB
+	}
+	if settings.BrickMaxNum != 0 {
+		limits.MaxNum = settings.BrickMaxNum
+	}
+
+	return limits
+}
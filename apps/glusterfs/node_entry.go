@@ -21,7 +21,9 @@ import (
 	"encoding/gob"
 	"errors"
 	"fmt"
+	"reflect"
 	"sort"
+	"time"
 
 	"github.com/boltdb/bolt"
 	"github.com/heketi/heketi/pkg/glusterfs/api"
@@ -34,6 +36,14 @@ type NodeEntry struct {
 
 	Info    api.NodeInfo
 	Devices sort.StringSlice
+
+	// Heartbeat
+	LastSeen          time.Time
+	HeartbeatFailures int
+
+	// Set when the node was set offline by the heartbeat
+	// instead of by an administrator
+	AutoOffline bool
 }
 
 func NewNodeEntry() *NodeEntry {
@@ -52,6 +62,8 @@ func NewNodeEntryFromRequest(req *api.NodeAddRequest) *NodeEntry {
 	node.Info.ClusterId = req.ClusterId
 	node.Info.Hostnames = req.Hostnames
 	node.Info.Zone = req.Zone
+	node.Info.SshUser = req.SshUser
+	node.Info.SshPort = req.SshPort
 
 	return node
 }
@@ -240,6 +252,11 @@ func (n *NodeEntry) SetState(tx *bolt.Tx,
 			return nil
 		case api.EntryStateFailed:
 		case api.EntryStateOffline:
+		case api.EntryStateMaintenance:
+			err := n.checkReplicaSetsInMaintenance(tx)
+			if err != nil {
+				return err
+			}
 		default:
 			return fmt.Errorf("Unknown state type: %v", s)
 		}
@@ -263,6 +280,29 @@ func (n *NodeEntry) SetState(tx *bolt.Tx,
 			if err != nil {
 				return err
 			}
+		case api.EntryStateFailed:
+			// Only thing to do here is to set the state
+		case api.EntryStateMaintenance:
+			err := n.checkReplicaSetsInMaintenance(tx)
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("Unknown state type: %v", s)
+		}
+		n.State = s
+
+	case api.EntryStateMaintenance:
+		switch s {
+		case api.EntryStateMaintenance:
+			return nil
+		case api.EntryStateOnline:
+			// Add disks back
+			err := n.addAllDisksToRing(tx, a)
+			if err != nil {
+				return err
+			}
+		case api.EntryStateOffline:
 		case api.EntryStateFailed:
 			// Only thing to do here is to set the state
 		default:
@@ -274,6 +314,202 @@ func (n *NodeEntry) SetState(tx *bolt.Tx,
 	return nil
 }
 
+// Returns the volumes which have bricks on the node
+func (n *NodeEntry) Volumes(tx *bolt.Tx) ([]*VolumeEntry, error) {
+	godbc.Require(tx != nil)
+
+	list, err := VolumeList(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	volumes := make([]*VolumeEntry, 0)
+	for _, id := range list {
+		volume, err := NewVolumeEntryFromId(tx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, brickId := range volume.Bricks {
+			brick, err := NewBrickEntryFromId(tx, brickId)
+			if err != nil {
+				return nil, err
+			}
+			if brick.Info.NodeId == n.Info.Id {
+				volumes = append(volumes, volume)
+				break
+			}
+		}
+	}
+
+	return volumes, nil
+}
+
+// Only one node of a replica set may be in maintenance at a time,
+// otherwise the data in the set may not be available.  For bricks
+// without replica set information all the bricks in the volume are
+// considered to be in the same set.
+func (n *NodeEntry) checkReplicaSetsInMaintenance(tx *bolt.Tx) error {
+
+	volumes, err := n.Volumes(tx)
+	if err != nil {
+		return err
+	}
+
+	nodes := make(map[string]*NodeEntry)
+	for _, volume := range volumes {
+		if volume.Info.Durability.Type == api.DurabilityDistributeOnly {
+			continue
+		}
+
+		// Load the bricks of the volume
+		bricks := make([]*BrickEntry, 0)
+		for _, brickId := range volume.Bricks {
+			brick, err := NewBrickEntryFromId(tx, brickId)
+			if err != nil {
+				return err
+			}
+			bricks = append(bricks, brick)
+		}
+
+		for _, brick := range bricks {
+			if brick.Info.NodeId != n.Info.Id {
+				continue
+			}
+
+			for _, peer := range bricks {
+				if peer.Info.NodeId == n.Info.Id ||
+					peer.BrickSetId != brick.BrickSetId {
+					continue
+				}
+
+				node, ok := nodes[peer.Info.NodeId]
+				if !ok {
+					node, err = NewNodeEntryFromId(tx, peer.Info.NodeId)
+					if err != nil {
+						return err
+					}
+					nodes[peer.Info.NodeId] = node
+				}
+
+				if node.State == api.EntryStateMaintenance {
+					return fmt.Errorf("Node %v is in maintenance and has bricks "+
+						"in the same replica set of volume %v",
+						node.ManageHostName(), volume.Info.Name)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns true if any device of the node holds bricks
+func (n *NodeEntry) hasBricks(tx *bolt.Tx) (bool, error) {
+	for _, deviceId := range n.Devices {
+		device, err := NewDeviceEntryFromId(tx, deviceId)
+		if err != nil {
+			return false, err
+		}
+		if len(device.Bricks) != 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Returns true if the request changes the storage hostnames.  Gluster
+// knows the bricks of the node by its storage hostname, so they cannot
+// be changed while the node has bricks.
+func (n *NodeEntry) changesStorageHostnames(req *api.NodeUpdateRequest) bool {
+	return len(req.Hostnames.Storage) != 0 &&
+		!reflect.DeepEqual(req.Hostnames.Storage, n.Info.Hostnames.Storage)
+}
+
+// Sets the values given in the request
+func (n *NodeEntry) applyUpdate(req *api.NodeUpdateRequest) {
+	if req.Zone != 0 {
+		n.Info.Zone = req.Zone
+	}
+	if len(req.Hostnames.Manage) != 0 {
+		n.Info.Hostnames.Manage = req.Hostnames.Manage
+	}
+	if len(req.Hostnames.Storage) != 0 {
+		n.Info.Hostnames.Storage = req.Hostnames.Storage
+	}
+	switch req.SshUser {
+	case "":
+	case api.NodeSshDefault:
+		n.Info.SshUser = ""
+	default:
+		n.Info.SshUser = req.SshUser
+	}
+	switch req.SshPort {
+	case "":
+	case api.NodeSshDefault:
+		n.Info.SshPort = ""
+	default:
+		n.Info.SshPort = req.SshPort
+	}
+}
+
+// Changes the hostnames, zone and ssh settings of the node.  The
+// devices of the node are placed in the allocator ring again under
+// the new zone.  Registering the new hostnames is done by the caller.
+func (n *NodeEntry) Update(tx *bolt.Tx,
+	a Allocator,
+	req *api.NodeUpdateRequest) error {
+
+	err := n.removeAllDisksFromRing(tx, a)
+	if err != nil {
+		return err
+	}
+
+	n.applyUpdate(req)
+
+	if n.isOnline() {
+		return n.addAllDisksToRing(tx, a)
+	}
+
+	return nil
+}
+
+// Records the result of a heartbeat.  After the given number of
+// consecutive failures an online node is set offline.  A node set
+// offline by the heartbeat is set online again once it answers.
+func (n *NodeEntry) Heartbeat(tx *bolt.Tx,
+	a Allocator,
+	reachable bool,
+	maxFailures int) error {
+
+	if !reachable {
+		n.HeartbeatFailures++
+		if n.isOnline() && n.HeartbeatFailures >= maxFailures {
+			err := n.SetState(tx, a, api.EntryStateOffline)
+			if err != nil {
+				return err
+			}
+			n.AutoOffline = true
+			logger.Warning("Node %v has not answered %v heartbeats. Set offline",
+				n.ManageHostName(), n.HeartbeatFailures)
+		}
+		return nil
+	}
+
+	n.LastSeen = time.Now()
+	n.HeartbeatFailures = 0
+	if n.AutoOffline && n.State == api.EntryStateOffline {
+		err := n.SetState(tx, a, api.EntryStateOnline)
+		if err != nil {
+			return err
+		}
+		logger.Info("Node %v is answering again. Set online", n.ManageHostName())
+	}
+	n.AutoOffline = false
+
+	return nil
+}
+
 func (n *NodeEntry) NewInfoReponse(tx *bolt.Tx) (*api.NodeInfoResponse, error) {
 
 	godbc.Require(tx != nil)
@@ -283,7 +519,13 @@ func (n *NodeEntry) NewInfoReponse(tx *bolt.Tx) (*api.NodeInfoResponse, error) {
 	info.Hostnames = n.Info.Hostnames
 	info.Id = n.Info.Id
 	info.Zone = n.Info.Zone
+	info.SshUser = n.Info.SshUser
+	info.SshPort = n.Info.SshPort
 	info.State = n.State
+	info.HeartbeatFailures = n.HeartbeatFailures
+	if !n.LastSeen.IsZero() {
+		info.LastSeen = n.LastSeen.UTC().Format(time.RFC3339)
+	}
 	info.DevicesInfo = make([]api.DeviceInfoResponse, 0)
 
 	// Add each drive information
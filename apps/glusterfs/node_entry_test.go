@@ -583,3 +583,243 @@ func TestNodeSetStateOfflineOnline(t *testing.T) {
 
 	})
 }
+
+func TestNodeEntryHeartbeat(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+
+	// Create allocator
+	mockAllocator := NewMockAllocator(app.db)
+	app.allocator = mockAllocator
+
+	// Create cluster entry
+	c := NewClusterEntry()
+	c.Info.Id = "cluster"
+
+	// Create a node with one device
+	n := NewNodeEntry()
+	n.Info.Id = "node"
+	n.Info.ClusterId = "cluster"
+	n.Info.Hostnames.Manage = []string{"manage"}
+	n.Devices = sort.StringSlice{"d1"}
+
+	d := NewDeviceEntry()
+	d.Info.Id = "d1"
+	d.Info.Name = "/d1"
+	d.NodeId = "node"
+
+	mockAllocator.AddDevice(c, n, d)
+
+	err := app.db.Update(func(tx *bolt.Tx) error {
+		err := c.Save(tx)
+		tests.Assert(t, err == nil)
+
+		err = n.Save(tx)
+		tests.Assert(t, err == nil)
+
+		err = d.Save(tx)
+		tests.Assert(t, err == nil)
+
+		// Node answers
+		err = n.Heartbeat(tx, mockAllocator, true, 2)
+		tests.Assert(t, err == nil)
+		tests.Assert(t, !n.LastSeen.IsZero())
+		tests.Assert(t, n.HeartbeatFailures == 0)
+		lastSeen := n.LastSeen
+
+		// One failure keeps the node online
+		err = n.Heartbeat(tx, mockAllocator, false, 2)
+		tests.Assert(t, err == nil)
+		tests.Assert(t, n.HeartbeatFailures == 1)
+		tests.Assert(t, n.State == api.EntryStateOnline)
+		tests.Assert(t, n.LastSeen == lastSeen)
+		tests.Assert(t, len(mockAllocator.clustermap[c.Info.Id]) == 1)
+
+		// Second failure sets it offline
+		err = n.Heartbeat(tx, mockAllocator, false, 2)
+		tests.Assert(t, err == nil)
+		tests.Assert(t, n.HeartbeatFailures == 2)
+		tests.Assert(t, n.State == api.EntryStateOffline)
+		tests.Assert(t, n.AutoOffline)
+		tests.Assert(t, len(mockAllocator.clustermap[c.Info.Id]) == 0)
+
+		// Node answers again and is set back online
+		err = n.Heartbeat(tx, mockAllocator, true, 2)
+		tests.Assert(t, err == nil)
+		tests.Assert(t, n.HeartbeatFailures == 0)
+		tests.Assert(t, n.State == api.EntryStateOnline)
+		tests.Assert(t, !n.AutoOffline)
+		tests.Assert(t, len(mockAllocator.clustermap[c.Info.Id]) == 1)
+
+		// A node set offline by an administrator stays offline
+		err = n.SetState(tx, mockAllocator, api.EntryStateOffline)
+		tests.Assert(t, err == nil)
+		err = n.Heartbeat(tx, mockAllocator, true, 2)
+		tests.Assert(t, err == nil)
+		tests.Assert(t, n.State == api.EntryStateOffline)
+		tests.Assert(t, len(mockAllocator.clustermap[c.Info.Id]) == 0)
+
+		return nil
+	})
+	tests.Assert(t, err == nil)
+
+	// Heartbeat is shown in the info response
+	err = app.db.View(func(tx *bolt.Tx) error {
+		info, err := n.NewInfoReponse(tx)
+		tests.Assert(t, err == nil)
+		tests.Assert(t, info.LastSeen != "")
+		tests.Assert(t, info.HeartbeatFailures == 0)
+		return nil
+	})
+	tests.Assert(t, err == nil)
+}
+
+func TestNodeSetStateMaintenance(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+
+	err := setupSampleDbWithTopology(app,
+		1,      // clusters
+		5,      // nodes_per_cluster
+		1,      // devices_per_node,
+		500*GB, // disksize
+	)
+	tests.Assert(t, err == nil)
+
+	// Create a replica 2 volume, which has two replica sets
+	v := createSampleVolumeEntry(100)
+	err = v.Create(app.db, app.executor, app.allocator)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, len(v.Bricks) == 4)
+
+	// Place each replica set on its own pair of nodes, leaving
+	// the last node without bricks
+	var nodes []string
+	err = app.db.Update(func(tx *bolt.Tx) error {
+		cluster, err := NewClusterEntryFromId(tx, v.Info.Cluster)
+		tests.Assert(t, err == nil)
+		nodes = cluster.Info.Nodes
+
+		sets := make(map[string]int)
+		for _, id := range v.Bricks {
+			brick, err := NewBrickEntryFromId(tx, id)
+			tests.Assert(t, err == nil)
+			tests.Assert(t, brick.VolumeId == v.Info.Id)
+			if _, ok := sets[brick.BrickSetId]; !ok {
+				sets[brick.BrickSetId] = len(sets) * 2
+			}
+			brick.Info.NodeId = nodes[sets[brick.BrickSetId]]
+			sets[brick.BrickSetId]++
+			tests.Assert(t, brick.Save(tx) == nil)
+		}
+		tests.Assert(t, len(sets) == 2, sets)
+		return nil
+	})
+	tests.Assert(t, err == nil)
+
+	setStates := func(s api.EntryState, ids ...string) error {
+		return app.db.Update(func(tx *bolt.Tx) error {
+			for _, id := range ids {
+				node, err := NewNodeEntryFromId(tx, id)
+				tests.Assert(t, err == nil)
+				err = node.SetState(tx, app.allocator, s)
+				if err != nil {
+					return err
+				}
+				tests.Assert(t, node.Save(tx) == nil)
+			}
+			return nil
+		})
+	}
+
+	// Volumes on the node
+	err = app.db.View(func(tx *bolt.Tx) error {
+		node, err := NewNodeEntryFromId(tx, nodes[0])
+		tests.Assert(t, err == nil)
+		volumes, err := node.Volumes(tx)
+		tests.Assert(t, err == nil)
+		tests.Assert(t, len(volumes) == 1)
+		tests.Assert(t, volumes[0].Info.Id == v.Info.Id)
+
+		node, err = NewNodeEntryFromId(tx, nodes[4])
+		tests.Assert(t, err == nil)
+		volumes, err = node.Volumes(tx)
+		tests.Assert(t, err == nil)
+		tests.Assert(t, len(volumes) == 0)
+		return nil
+	})
+	tests.Assert(t, err == nil)
+
+	// First node of the first set
+	err = setStates(api.EntryStateMaintenance, nodes[0])
+	tests.Assert(t, err == nil, err)
+
+	// Second node of the same set is refused
+	err = setStates(api.EntryStateMaintenance, nodes[1])
+	tests.Assert(t, err != nil)
+
+	// Nodes of other sets or without bricks are allowed
+	err = setStates(api.EntryStateMaintenance, nodes[2], nodes[4])
+	tests.Assert(t, err == nil, err)
+
+	// No new bricks are placed on a node in maintenance
+	err = app.db.View(func(tx *bolt.Tx) error {
+		node, err := NewNodeEntryFromId(tx, nodes[0])
+		tests.Assert(t, err == nil)
+		tests.Assert(t, node.State == api.EntryStateMaintenance)
+
+		ch, _, errc := app.allocator.GetNodes(v.Info.Cluster, utils.GenUUID(), nil)
+		devices := 0
+		for deviceId := range ch {
+			devices++
+			tests.Assert(t, deviceId != node.Devices[0])
+		}
+		tests.Assert(t, <-errc == nil)
+		tests.Assert(t, devices == 2, devices)
+		return nil
+	})
+	tests.Assert(t, err == nil)
+
+	// Once the first node is back the second can be serviced
+	err = setStates(api.EntryStateOnline, nodes[0], nodes[2], nodes[4])
+	tests.Assert(t, err == nil, err)
+	err = setStates(api.EntryStateMaintenance, nodes[1])
+	tests.Assert(t, err == nil, err)
+
+	// Maintenance can move to offline and failed, but failed is final
+	err = setStates(api.EntryStateOffline, nodes[1])
+	tests.Assert(t, err == nil, err)
+	err = setStates(api.EntryStateMaintenance, nodes[1])
+	tests.Assert(t, err == nil, err)
+	err = setStates(api.EntryStateFailed, nodes[1])
+	tests.Assert(t, err == nil, err)
+	err = setStates(api.EntryStateMaintenance, nodes[1])
+	tests.Assert(t, err != nil)
+
+	// Remove the replica set information, as in older bricks
+	err = app.db.Update(func(tx *bolt.Tx) error {
+		for _, id := range v.Bricks {
+			brick, err := NewBrickEntryFromId(tx, id)
+			tests.Assert(t, err == nil)
+			brick.VolumeId = ""
+			brick.BrickSetId = ""
+			tests.Assert(t, brick.Save(tx) == nil)
+		}
+		return nil
+	})
+	tests.Assert(t, err == nil)
+
+	// Now all the nodes of the volume are considered one set
+	err = setStates(api.EntryStateMaintenance, nodes[0])
+	tests.Assert(t, err == nil, err)
+	err = setStates(api.EntryStateMaintenance, nodes[2])
+	tests.Assert(t, err != nil)
+}
@@ -21,7 +21,7 @@ import (
 )
 
 type VolumeDurability interface {
-	BrickSizeGenerator(size uint64) func() (int, uint64, error)
+	BrickSizeGenerator(size uint64, limits *BrickLimits) func() (int, uint64, error)
 	BricksInSet() int
 	SetDurability()
 	SetExecutorVolumeRequest(v *executors.VolumeRequest)
@@ -42,7 +42,7 @@ func (d *VolumeDisperseDurability) SetDurability() {
 	}
 }
 
-func (d *VolumeDisperseDurability) BrickSizeGenerator(size uint64) func() (int, uint64, error) {
+func (d *VolumeDisperseDurability) BrickSizeGenerator(size uint64, limits *BrickLimits) func() (int, uint64, error) {
 
 	sets := 1
 	return func() (int, uint64, error) {
@@ -57,9 +57,9 @@ func (d *VolumeDisperseDurability) BrickSizeGenerator(size uint64) func() (int,
 			// number of data drives in the disperse request
 			brick_size /= uint64(d.Data)
 
-			if brick_size < BrickMinSize {
+			if brick_size < limits.MinSize {
 				return 0, 0, ErrMininumBrickSize
-			} else if brick_size <= BrickMaxSize {
+			} else if brick_size <= limits.MaxSize {
 				break
 			}
 		}
@@ -38,7 +38,7 @@ func (r *VolumeReplicaDurability) SetDurability() {
 	}
 }
 
-func (r *VolumeReplicaDurability) BrickSizeGenerator(size uint64) func() (int, uint64, error) {
+func (r *VolumeReplicaDurability) BrickSizeGenerator(size uint64, limits *BrickLimits) func() (int, uint64, error) {
 
 	sets := 1
 	return func() (int, uint64, error) {
@@ -49,9 +49,9 @@ func (r *VolumeReplicaDurability) BrickSizeGenerator(size uint64) func() (int, u
 			sets *= 2
 			brick_size = size / uint64(sets)
 
-			if brick_size < BrickMinSize {
+			if brick_size < limits.MinSize {
 				return 0, 0, ErrMininumBrickSize
-			} else if brick_size <= BrickMaxSize {
+			} else if brick_size <= limits.MaxSize {
 				break
 			}
 		}
@@ -84,7 +84,7 @@ func TestNoneDurability(t *testing.T) {
 	r := &NoneDurability{}
 	r.SetDurability()
 
-	gen := r.BrickSizeGenerator(100 * GB)
+	gen := r.BrickSizeGenerator(100*GB, NewBrickLimits(nil))
 
 	// Gen 1
 	sets, brick_size, err := gen()
@@ -128,7 +128,7 @@ func TestDisperseDurability(t *testing.T) {
 	r.Data = 8
 	r.Redundancy = 3
 
-	gen := r.BrickSizeGenerator(200 * GB)
+	gen := r.BrickSizeGenerator(200*GB, NewBrickLimits(nil))
 
 	// Gen 1
 	sets, brick_size, err := gen()
@@ -155,7 +155,7 @@ func TestDisperseDurabilityLargeBrickGenerator(t *testing.T) {
 	r.Data = 8
 	r.Redundancy = 3
 
-	gen := r.BrickSizeGenerator(800 * TB)
+	gen := r.BrickSizeGenerator(800*TB, NewBrickLimits(nil))
 
 	// Gen 1
 	sets, brick_size, err := gen()
@@ -169,7 +169,7 @@ func TestReplicaDurabilityGenerator(t *testing.T) {
 	r := &VolumeReplicaDurability{}
 	r.Replica = 2
 
-	gen := r.BrickSizeGenerator(100 * GB)
+	gen := r.BrickSizeGenerator(100*GB, NewBrickLimits(nil))
 
 	// Gen 1
 	sets, brick_size, err := gen()
@@ -211,7 +211,7 @@ func TestReplicaDurabilityLargeBrickGenerator(t *testing.T) {
 	r := &VolumeReplicaDurability{}
 	r.Replica = 2
 
-	gen := r.BrickSizeGenerator(100 * TB)
+	gen := r.BrickSizeGenerator(100*TB, NewBrickLimits(nil))
 
 	// Gen 1
 	sets, brick_size, err := gen()
@@ -80,32 +80,7 @@ func NewVolumeEntryFromRequest(req *api.VolumeCreateRequest) *VolumeEntry {
 	vol.Info.Size = req.Size
 
 	// Set default durability values
-	durability := vol.Info.Durability.Type
-	switch {
-
-	case durability == api.DurabilityReplicate:
-		logger.Debug("[%v] Replica %v",
-			vol.Info.Id,
-			vol.Info.Durability.Replicate.Replica)
-		vol.Durability = NewVolumeReplicaDurability(&vol.Info.Durability.Replicate)
-
-	case durability == api.DurabilityEC:
-		logger.Debug("[%v] EC %v + %v ",
-			vol.Info.Id,
-			vol.Info.Durability.Disperse.Data,
-			vol.Info.Durability.Disperse.Redundancy)
-		vol.Durability = NewVolumeDisperseDurability(&vol.Info.Durability.Disperse)
-
-	case durability == api.DurabilityDistributeOnly || durability == "":
-		logger.Debug("[%v] Distributed", vol.Info.Id)
-		vol.Durability = NewNoneDurability()
-
-	default:
-		panic(fmt.Sprintf("BUG: Unknown type: %v\n", vol.Info.Durability))
-	}
-
-	// Set the default values accordingly
-	vol.Durability.SetDurability()
+	vol.setDurability(vol.Info.Durability)
 
 	// Set default name
 	if req.Name == "" {
@@ -123,10 +98,64 @@ func NewVolumeEntryFromRequest(req *api.VolumeCreateRequest) *VolumeEntry {
 
 	// If it is zero, then it will be assigned during volume creation
 	vol.Info.Clusters = req.Clusters
+	vol.Info.ClusterSelector = req.ClusterSelector
+	vol.Info.DeviceSelector = req.DeviceSelector
 
 	return vol
 }
 
+// Sets up the durability of the volume.  Values which are not
+// provided are set to their defaults.
+func (v *VolumeEntry) setDurability(durability api.VolumeDurabilityInfo) {
+	v.Info.Durability = durability
+
+	switch {
+
+	case durability.Type == api.DurabilityReplicate:
+		logger.Debug("[%v] Replica %v",
+			v.Info.Id,
+			v.Info.Durability.Replicate.Replica)
+		v.Durability = NewVolumeReplicaDurability(&v.Info.Durability.Replicate)
+
+	case durability.Type == api.DurabilityEC:
+		logger.Debug("[%v] EC %v + %v ",
+			v.Info.Id,
+			v.Info.Durability.Disperse.Data,
+			v.Info.Durability.Disperse.Redundancy)
+		v.Durability = NewVolumeDisperseDurability(&v.Info.Durability.Disperse)
+
+	case durability.Type == api.DurabilityDistributeOnly || durability.Type == "":
+		logger.Debug("[%v] Distributed", v.Info.Id)
+		v.Durability = NewNoneDurability()
+
+	default:
+		panic(fmt.Sprintf("BUG: Unknown type: %v\n", v.Info.Durability))
+	}
+
+	// Set the default values accordingly
+	v.Durability.SetDurability()
+}
+
+// Returns the durability requested with the values not provided
+// taken from the cluster defaults
+func clusterDurability(requested api.VolumeDurabilityInfo,
+	defaults *api.VolumeDurabilityInfo) api.VolumeDurabilityInfo {
+
+	durability := requested
+	if durability.Type == "" {
+		durability.Type = defaults.Type
+		durability.Disperse = defaults.Disperse
+	}
+	if durability.Type == "" {
+		durability.Type = api.DurabilityDistributeOnly
+	}
+	if durability.Replicate.Replica == 0 {
+		durability.Replicate.Replica = defaults.Replicate.Replica
+	}
+
+	return durability
+}
+
 func NewVolumeEntryFromId(tx *bolt.Tx, id string) (*VolumeEntry, error) {
 	godbc.Require(tx != nil)
 
@@ -165,6 +194,8 @@ func (v *VolumeEntry) NewInfoResponse(tx *bolt.Tx) (*api.VolumeInfoResponse, err
 	info.Size = v.Info.Size
 	info.Durability = v.Info.Durability
 	info.Name = v.Info.Name
+	info.ClusterSelector = v.Info.ClusterSelector
+	info.DeviceSelector = v.Info.DeviceSelector
 
 	for _, brickid := range v.BricksIds() {
 		brick, err := NewBrickEntryFromId(tx, brickid)
@@ -231,20 +262,24 @@ func (v *VolumeEntry) Create(db *bolt.DB,
 		}
 	}()
 
-	// Get list of clusters
+	// Get list of clusters which match the selector
 	var clusters []string
-	if len(v.Info.Clusters) == 0 {
-		err := db.View(func(tx *bolt.Tx) error {
-			var err error
+	err := db.View(func(tx *bolt.Tx) error {
+		var err error
+		if len(v.Info.Clusters) == 0 {
 			clusters, err = ClusterList(tx)
-			return err
-
-		})
-		if err != nil {
-			return err
+			if err != nil {
+				return err
+			}
+		} else {
+			clusters = v.Info.Clusters
 		}
-	} else {
-		clusters = v.Info.Clusters
+
+		clusters, err = ClusterListMatching(tx, clusters, v.Info.ClusterSelector)
+		return err
+	})
+	if err != nil {
+		return err
 	}
 
 	// Check we have clusters
@@ -256,8 +291,24 @@ func (v *VolumeEntry) Create(db *bolt.DB,
 
 	// For each cluster look for storage space for this volume
 	var brick_entries []*BrickEntry
+	requested := v.Info.Durability
 	for _, cluster := range clusters {
-		var err error
+
+		// Use the defaults of the cluster for the durability
+		// values which were not requested
+		err := db.View(func(tx *bolt.Tx) error {
+			entry, err := NewClusterEntryFromId(tx, cluster)
+			if err != nil {
+				return err
+			}
+
+			v.setDurability(clusterDurability(requested, &entry.Info.Settings.Durability))
+			return nil
+		})
+		if err != nil {
+			logger.Err(err)
+			continue
+		}
 
 		// Check this cluster for space
 		brick_entries, err = v.allocBricksInCluster(db, allocator, cluster, v.Info.Size)
@@ -286,7 +337,7 @@ func (v *VolumeEntry) Create(db *bolt.DB,
 	}()
 
 	// Create the bricks on the nodes
-	err := CreateBricks(db, executor, brick_entries)
+	err = CreateBricks(db, executor, brick_entries)
 	if err != nil {
 		return err
 	}
@@ -435,7 +486,30 @@ func (v *VolumeEntry) Destroy(db *bolt.DB, executor executors.Executor) error {
 	return err
 }
 
+// Expands the volume by sizeGB.  If waitForRebalance is set, a
+// rebalance is started once the new bricks have been added and this
+// function only returns after the rebalance has finished.
 func (v *VolumeEntry) Expand(db *bolt.DB,
+	executor executors.Executor,
+	allocator Allocator,
+	sizeGB int,
+	waitForRebalance bool) error {
+
+	err := v.expandBricks(db, executor, allocator, sizeGB)
+	if err != nil {
+		return err
+	}
+
+	if !waitForRebalance {
+		return nil
+	}
+
+	// The volume has been expanded at this point, so a failed
+	// rebalance must not undo the new bricks
+	return v.rebalanceAndWait(db, executor)
+}
+
+func (v *VolumeEntry) expandBricks(db *bolt.DB,
 	executor executors.Executor,
 	allocator Allocator,
 	sizeGB int) (e error) {
@@ -513,6 +587,40 @@ func (v *VolumeEntry) Expand(db *bolt.DB,
 
 }
 
+// Returns the management host to send volume commands to, preferring
+// an online node, and a map of brick names in host:path format as
+// reported by gluster to their brick ids
+func (v *VolumeEntry) brickHosts(tx *bolt.Tx) (string, map[string]string, error) {
+	var (
+		host       string
+		hostOnline bool
+	)
+	bricks := make(map[string]string)
+	for _, id := range v.BricksIds() {
+		brick, err := NewBrickEntryFromId(tx, id)
+		if err != nil {
+			return "", nil, err
+		}
+
+		node, err := NewNodeEntryFromId(tx, brick.Info.NodeId)
+		if err != nil {
+			return "", nil, err
+		}
+
+		bricks[node.StorageHostName()+":"+brick.Info.Path] = id
+		if host == "" || (!hostOnline && node.isOnline()) {
+			host = node.ManageHostName()
+			hostOnline = node.isOnline()
+		}
+	}
+
+	if host == "" {
+		return "", nil, ErrNotFound
+	}
+
+	return host, bricks, nil
+}
+
 func (v *VolumeEntry) BricksIds() sort.StringSlice {
 	ids := make(sort.StringSlice, len(v.Bricks))
 	copy(ids, v.Bricks)
@@ -26,12 +26,31 @@ func (v *VolumeEntry) allocBricksInCluster(db *bolt.DB,
 	cluster string,
 	gbsize int) ([]*BrickEntry, error) {
 
+	// Do not allow the limits to change while allocating
+	limitsLock.RLock()
+	defer limitsLock.RUnlock()
+
+	// Get the limits of the cluster
+	var limits *BrickLimits
+	err := db.View(func(tx *bolt.Tx) error {
+		entry, err := NewClusterEntryFromId(tx, cluster)
+		if err != nil {
+			return err
+		}
+
+		limits = NewBrickLimits(&entry.Info.Settings)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	// This value will keep being halved until either
 	// space is found, or it is determined that the cluster is full
 	size := uint64(gbsize) * GB
 
 	// Setup a brick size generator
-	gen := v.Durability.BrickSizeGenerator(size)
+	gen := v.Durability.BrickSizeGenerator(size, limits)
 
 	// Continue adjust 'size' until space is found
 	for {
@@ -48,7 +67,7 @@ func (v *VolumeEntry) allocBricksInCluster(db *bolt.DB,
 		logger.Debug("sets = %v", sets)
 
 		// Check that the volume does not have too many bricks
-		if (sets*v.Durability.BricksInSet() + len(v.Bricks)) > BrickMaxNum {
+		if (sets*v.Durability.BricksInSet() + len(v.Bricks)) > limits.MaxNum {
 			logger.Debug("Maximum number of bricks reached")
 			// Try other clusters if possible
 			return nil, ErrMaxBricks
@@ -110,7 +129,8 @@ func (v *VolumeEntry) allocBricks(
 
 		// Get allocator generator
 		// The same generator should be used for the brick and its replicas
-		deviceCh, done, errc := allocator.GetNodes(cluster, brickId)
+		deviceCh, done, errc := allocator.GetNodes(cluster, brickId,
+			v.Info.DeviceSelector)
 		defer func() {
 			close(done)
 		}()
@@ -132,6 +152,11 @@ func (v *VolumeEntry) allocBricks(
 						return err
 					}
 
+					// Only use devices which match the selector
+					if !device.MatchesSelector(v.Info.DeviceSelector) {
+						continue
+					}
+
 					// Do not allow a device from the same node to be
 					// in the set
 					deviceOk := true
@@ -155,6 +180,8 @@ func (v *VolumeEntry) allocBricks(
 						if i == 0 {
 							brick.SetId(brickId)
 						}
+						brick.VolumeId = v.Info.Id
+						brick.BrickSetId = brickId
 
 						// Save the brick entry to create later
 						brick_entries = append(brick_entries, brick)
@@ -0,0 +1,87 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package glusterfs
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/heketi/heketi/executors"
+)
+
+var (
+	// How often the rebalance status is read while waiting, and
+	// how long to wait before giving up
+	rebalanceCheckInterval = 10 * time.Second
+	rebalanceTimeout       = 6 * time.Hour
+)
+
+// Starts a rebalance of the volume, unless one is already running,
+// and waits until it has finished.  A rebalance still running after
+// rebalanceTimeout is left running and an error is returned.
+func (v *VolumeEntry) rebalanceAndWait(db *bolt.DB,
+	executor executors.Executor) error {
+
+	var host string
+	err := db.View(func(tx *bolt.Tx) error {
+		var err error
+		host, _, err = v.brickHosts(tx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// The executor may have already started the rebalance
+	// when it expanded the volume
+	logger.Info("Starting rebalance on volume %v", v.Info.Id)
+	err = executor.VolumeRebalanceStart(host, v.Info.Name)
+	if err != nil {
+		status, serr := executor.VolumeRebalanceStatus(host, v.Info.Name)
+		if serr != nil || !status.InProgress() {
+			logger.Err(err)
+			return err
+		}
+	}
+
+	deadline := time.Now().Add(rebalanceTimeout)
+	for {
+		status, err := executor.VolumeRebalanceStatus(host, v.Info.Name)
+		if err != nil {
+			logger.Err(err)
+			return err
+		}
+
+		if !status.InProgress() {
+			if status.Status != "completed" {
+				return fmt.Errorf("Rebalance of volume %v finished with status: %v",
+					v.Info.Id, status.Status)
+			}
+			logger.Info("Rebalance of volume %v completed", v.Info.Id)
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return logger.LogError("Rebalance of volume %v did not finish within %v.  "+
+				"It is still running; check its progress with the volume rebalance status",
+				v.Info.Id, rebalanceTimeout)
+		}
+
+		time.Sleep(rebalanceCheckInterval)
+	}
+}
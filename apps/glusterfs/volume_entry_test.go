@@ -24,6 +24,7 @@ import (
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/boltdb/bolt"
 	"github.com/heketi/heketi/executors"
@@ -1177,11 +1178,11 @@ func TestVolumeEntryExpandNoSpace(t *testing.T) {
 	*vcopy = *v
 
 	// Asking for a large amount will require too many little bricks
-	err = v.Expand(app.db, app.executor, app.allocator, 5000)
+	err = v.Expand(app.db, app.executor, app.allocator, 5000, false)
 	tests.Assert(t, err == ErrMaxBricks, err)
 
 	// Asking for a small amount will set the bricks too small
-	err = v.Expand(app.db, app.executor, app.allocator, 10)
+	err = v.Expand(app.db, app.executor, app.allocator, 10, false)
 	tests.Assert(t, err == ErrMininumBrickSize, err)
 
 	// Check db is the same as before expansion
@@ -1224,7 +1225,7 @@ func TestVolumeEntryExpandMaxBrickLimit(t *testing.T) {
 
 	// Try to expand the volume, but it will return that the max number
 	// of bricks has been reached
-	err = v.Expand(app.db, app.executor, app.allocator, 100)
+	err = v.Expand(app.db, app.executor, app.allocator, 100, false)
 	tests.Assert(t, err == ErrMaxBricks, err)
 }
 
@@ -1261,7 +1262,7 @@ func TestVolumeEntryExpandCreateBricksFailure(t *testing.T) {
 	}
 
 	// Expand volume
-	err = v.Expand(app.db, app.executor, app.allocator, 500)
+	err = v.Expand(app.db, app.executor, app.allocator, 500, false)
 	tests.Assert(t, err == ErrMock)
 
 	// Check db is the same as before expansion
@@ -1301,7 +1302,7 @@ func TestVolumeEntryExpand(t *testing.T) {
 	tests.Assert(t, len(v.Bricks) == 4)
 
 	// Expand volume
-	err = v.Expand(app.db, app.executor, app.allocator, 1234)
+	err = v.Expand(app.db, app.executor, app.allocator, 1234, false)
 	tests.Assert(t, err == nil)
 	tests.Assert(t, v.Info.Size == 1024+1234)
 	tests.Assert(t, len(v.Bricks) == 8)
@@ -1318,6 +1319,91 @@ func TestVolumeEntryExpand(t *testing.T) {
 	tests.Assert(t, reflect.DeepEqual(entry, v))
 }
 
+func TestVolumeEntryExpandWaitForRebalance(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+
+	defer tests.Patch(&rebalanceCheckInterval, time.Millisecond).Restore()
+
+	err := setupSampleDbWithTopology(app,
+		1,    // clusters
+		4,    // nodes_per_cluster
+		2,    // devices_per_node,
+		5*TB, // disksize
+	)
+	tests.Assert(t, err == nil)
+
+	v := createSampleVolumeEntry(100)
+	err = v.Create(app.db, app.executor, app.allocator)
+	tests.Assert(t, err == nil)
+
+	// Rebalance runs for a few checks and then completes
+	started := false
+	checks := 0
+	app.xo.MockVolumeRebalanceStart = func(host, volume string) error {
+		started = true
+		return nil
+	}
+	app.xo.MockVolumeRebalanceStatus = func(host, volume string) (*executors.VolumeRebalanceInfo, error) {
+		checks++
+		if checks < 3 {
+			return &executors.VolumeRebalanceInfo{Status: "in progress"}, nil
+		}
+		return &executors.VolumeRebalanceInfo{Status: "completed"}, nil
+	}
+
+	err = v.Expand(app.db, app.executor, app.allocator, 100, true)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, started)
+	tests.Assert(t, checks == 3, checks)
+	tests.Assert(t, v.Info.Size == 200)
+
+	// Not waiting does not start a rebalance
+	started = false
+	err = v.Expand(app.db, app.executor, app.allocator, 100, false)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, !started)
+
+	// Already started by the executor when expanding
+	checks = 0
+	app.xo.MockVolumeRebalanceStart = func(host, volume string) error {
+		return errors.New("Rebalance on volume is already started")
+	}
+	err = v.Expand(app.db, app.executor, app.allocator, 100, true)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, checks == 3, checks)
+
+	// A failed rebalance is returned but the volume stays expanded
+	app.xo.MockVolumeRebalanceStart = func(host, volume string) error {
+		return nil
+	}
+	app.xo.MockVolumeRebalanceStatus = func(host, volume string) (*executors.VolumeRebalanceInfo, error) {
+		return &executors.VolumeRebalanceInfo{Status: "failed"}, nil
+	}
+	bricks := len(v.Bricks)
+	err = v.Expand(app.db, app.executor, app.allocator, 100, true)
+	tests.Assert(t, err != nil)
+	tests.Assert(t, v.Info.Size == 500)
+	tests.Assert(t, len(v.Bricks) > bricks)
+
+	// A rebalance which never finishes times out
+	defer tests.Patch(&rebalanceTimeout, 20*time.Millisecond).Restore()
+	checks = 0
+	app.xo.MockVolumeRebalanceStatus = func(host, volume string) (*executors.VolumeRebalanceInfo, error) {
+		checks++
+		return &executors.VolumeRebalanceInfo{Status: "in progress"}, nil
+	}
+	err = v.Expand(app.db, app.executor, app.allocator, 100, true)
+	tests.Assert(t, err != nil)
+	tests.Assert(t, strings.Contains(err.Error(), "did not finish"), err)
+	tests.Assert(t, checks > 1, checks)
+	tests.Assert(t, v.Info.Size == 600)
+}
+
 func TestVolumeEntryDoNotAllowDeviceOnSameNode(t *testing.T) {
 	tmpfile := tests.Tempfile()
 	defer os.Remove(tmpfile)
@@ -1403,3 +1489,237 @@ func TestVolumeEntryDestroyCheck(t *testing.T) {
 	tests.Assert(t, err == nil)
 
 }
+
+func TestVolumeEntryCreateClusterSettings(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+
+	// Create a cluster in the database
+	err := setupSampleDbWithTopology(app,
+		1,      // clusters
+		4,      // nodes_per_cluster
+		4,      // devices_per_node,
+		500*GB, // disksize)
+	)
+	tests.Assert(t, err == nil)
+
+	// Set the cluster to use replica 3 volumes
+	// and bricks no larger than 50GB
+	var clusterId string
+	err = app.db.Update(func(tx *bolt.Tx) error {
+		clusters, err := ClusterList(tx)
+		if err != nil {
+			return err
+		}
+		clusterId = clusters[0]
+
+		cluster, err := NewClusterEntryFromId(tx, clusterId)
+		if err != nil {
+			return err
+		}
+
+		settings := &api.ClusterSettings{
+			BrickMaxSize: 50,
+		}
+		settings.Durability.Type = api.DurabilityReplicate
+		settings.Durability.Replicate.Replica = 3
+		err = cluster.SetSettings(settings)
+		if err != nil {
+			return err
+		}
+
+		return cluster.Save(tx)
+	})
+	tests.Assert(t, err == nil)
+
+	// Create a volume without durability
+	req := &api.VolumeCreateRequest{}
+	req.Size = 100
+	v := NewVolumeEntryFromRequest(req)
+	err = v.Create(app.db, app.executor, app.allocator)
+	tests.Assert(t, err == nil, err)
+
+	// The cluster defaults were used
+	tests.Assert(t, v.Info.Cluster == clusterId)
+	tests.Assert(t, v.Info.Durability.Type == api.DurabilityReplicate)
+	tests.Assert(t, v.Durability.BricksInSet() == 3)
+	tests.Assert(t, len(v.Bricks) == 6, len(v.Bricks))
+
+	// A volume requesting a durability keeps it
+	req = &api.VolumeCreateRequest{}
+	req.Size = 100
+	req.Durability.Type = api.DurabilityDistributeOnly
+	v = NewVolumeEntryFromRequest(req)
+	err = v.Create(app.db, app.executor, app.allocator)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, v.Info.Durability.Type == api.DurabilityDistributeOnly)
+	tests.Assert(t, len(v.Bricks) == 2, len(v.Bricks))
+
+	// The cluster brick limit is used
+	err = app.db.Update(func(tx *bolt.Tx) error {
+		cluster, err := NewClusterEntryFromId(tx, clusterId)
+		if err != nil {
+			return err
+		}
+
+		err = cluster.SetSettings(&api.ClusterSettings{
+			BrickMaxNum: 2,
+		})
+		if err != nil {
+			return err
+		}
+
+		return cluster.Save(tx)
+	})
+	tests.Assert(t, err == nil)
+
+	req = &api.VolumeCreateRequest{}
+	req.Size = 100
+	req.Durability.Type = api.DurabilityReplicate
+	req.Durability.Replicate.Replica = 3
+	v = NewVolumeEntryFromRequest(req)
+	err = v.Create(app.db, app.executor, app.allocator)
+	tests.Assert(t, err == ErrNoSpace, err)
+}
+
+func TestVolumeEntryCreateClusterSelector(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+
+	// Create two clusters in the database
+	err := setupSampleDbWithTopology(app,
+		2,      // clusters
+		4,      // nodes_per_cluster
+		4,      // devices_per_node,
+		500*GB, // disksize)
+	)
+	tests.Assert(t, err == nil)
+
+	// Label the second cluster for block volumes
+	var blockCluster string
+	err = app.db.Update(func(tx *bolt.Tx) error {
+		clusters, err := ClusterList(tx)
+		if err != nil {
+			return err
+		}
+		blockCluster = clusters[1]
+
+		cluster, err := NewClusterEntryFromId(tx, blockCluster)
+		if err != nil {
+			return err
+		}
+
+		err = cluster.SetLabels(map[string]string{"purpose": "block"})
+		if err != nil {
+			return err
+		}
+
+		return cluster.Save(tx)
+	})
+	tests.Assert(t, err == nil)
+
+	// Create volumes only on the block cluster
+	for i := 0; i < 4; i++ {
+		v := createSampleVolumeEntry(10)
+		v.Info.ClusterSelector = map[string]string{"purpose": "block"}
+		err = v.Create(app.db, app.executor, app.allocator)
+		tests.Assert(t, err == nil, err)
+		tests.Assert(t, v.Info.Cluster == blockCluster)
+	}
+
+	// No cluster matches
+	v := createSampleVolumeEntry(10)
+	v.Info.ClusterSelector = map[string]string{"purpose": "file"}
+	err = v.Create(app.db, app.executor, app.allocator)
+	tests.Assert(t, err == ErrNoSpace, err)
+}
+
+func TestVolumeEntryCreateDeviceSelector(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+
+	// Create a cluster in the database
+	err := setupSampleDbWithTopology(app,
+		1,      // clusters
+		4,      // nodes_per_cluster
+		4,      // devices_per_node,
+		500*GB, // disksize)
+	)
+	tests.Assert(t, err == nil)
+
+	// Tag one device on each node as an ssd
+	ssds := make(map[string]bool)
+	err = app.db.Update(func(tx *bolt.Tx) error {
+		clusters, err := ClusterList(tx)
+		if err != nil {
+			return err
+		}
+
+		cluster, err := NewClusterEntryFromId(tx, clusters[0])
+		if err != nil {
+			return err
+		}
+
+		for _, nodeId := range cluster.Info.Nodes {
+			node, err := NewNodeEntryFromId(tx, nodeId)
+			if err != nil {
+				return err
+			}
+
+			device, err := NewDeviceEntryFromId(tx, node.Devices[0])
+			if err != nil {
+				return err
+			}
+
+			err = device.SetTags(tx, app.allocator, map[string]string{"class": "ssd"})
+			if err != nil {
+				return err
+			}
+			ssds[device.Info.Id] = true
+
+			err = device.Save(tx)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	tests.Assert(t, err == nil)
+
+	// Create a volume only on ssds
+	v := createSampleVolumeEntry(100)
+	v.Info.DeviceSelector = map[string]string{"class": "ssd"}
+	err = v.Create(app.db, app.executor, app.allocator)
+	tests.Assert(t, err == nil, err)
+
+	err = app.db.View(func(tx *bolt.Tx) error {
+		for _, brickId := range v.Bricks {
+			brick, err := NewBrickEntryFromId(tx, brickId)
+			if err != nil {
+				return err
+			}
+			tests.Assert(t, ssds[brick.Info.DeviceId])
+		}
+		return nil
+	})
+	tests.Assert(t, err == nil)
+
+	// No devices match
+	v = createSampleVolumeEntry(100)
+	v.Info.DeviceSelector = map[string]string{"class": "nvme"}
+	err = v.Create(app.db, app.executor, app.allocator)
+	tests.Assert(t, err == ErrNoSpace, err)
+}
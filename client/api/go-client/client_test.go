@@ -268,6 +268,26 @@ func TestClientCluster(t *testing.T) {
 	tests.Assert(t, len(list.Clusters) == 1)
 	tests.Assert(t, list.Clusters[0] == info.Id)
 
+	// Change the cluster settings
+	settings := &api.ClusterSettings{
+		BrickMaxNum: 10,
+	}
+	info, err = c.ClusterSetSettings(cluster.Id, settings)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, info.Settings.BrickMaxNum == 10)
+
+	// Bad settings
+	settings.BrickMaxNum = -1
+	_, err = c.ClusterSetSettings(cluster.Id, settings)
+	tests.Assert(t, err != nil)
+
+	// Change the cluster labels
+	info, err = c.ClusterSetLabels(cluster.Id, &api.ClusterLabelsRequest{
+		Labels: map[string]string{"purpose": "block"},
+	})
+	tests.Assert(t, err == nil)
+	tests.Assert(t, info.Labels["purpose"] == "block")
+
 	// Delete non-existent cluster
 	err = c.ClusterDelete("badid")
 	tests.Assert(t, err != nil)
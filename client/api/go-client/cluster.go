@@ -18,15 +18,27 @@ package client
 
 import (
 	"bytes"
+	"encoding/json"
 	"github.com/heketi/heketi/pkg/glusterfs/api"
 	"github.com/heketi/utils"
 	"net/http"
 )
 
 func (c *Client) ClusterCreate() (*api.ClusterInfoResponse, error) {
+	return c.ClusterCreateWithRequest(&api.ClusterCreateRequest{})
+}
+
+func (c *Client) ClusterCreateWithRequest(request *api.ClusterCreateRequest) (
+	*api.ClusterInfoResponse, error) {
+
+	// Marshal request to JSON
+	buffer, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
 
 	// Create a request
-	req, err := http.NewRequest("POST", c.host+"/clusters", bytes.NewBuffer([]byte(`{}`)))
+	req, err := http.NewRequest("POST", c.host+"/clusters", bytes.NewBuffer(buffer))
 	if err != nil {
 		return nil, err
 	}
@@ -150,3 +162,91 @@ func (c *Client) ClusterDelete(id string) error {
 
 	return nil
 }
+
+func (c *Client) ClusterSetSettings(id string, request *api.ClusterSettings) (
+	*api.ClusterInfoResponse, error) {
+
+	// Marshal request to JSON
+	buffer, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a request
+	req, err := http.NewRequest("POST",
+		c.host+"/clusters/"+id+"/settings",
+		bytes.NewBuffer(buffer))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send request
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, utils.GetErrorFromResponse(r)
+	}
+
+	// Read JSON response
+	var cluster api.ClusterInfoResponse
+	err = utils.GetJsonFromResponse(r, &cluster)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return &cluster, nil
+}
+
+func (c *Client) ClusterSetLabels(id string, request *api.ClusterLabelsRequest) (
+	*api.ClusterInfoResponse, error) {
+
+	// Marshal request to JSON
+	buffer, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a request
+	req, err := http.NewRequest("POST",
+		c.host+"/clusters/"+id+"/labels",
+		bytes.NewBuffer(buffer))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send request
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, utils.GetErrorFromResponse(r)
+	}
+
+	// Read JSON response
+	var cluster api.ClusterInfoResponse
+	err = utils.GetJsonFromResponse(r, &cluster)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return &cluster, nil
+}
@@ -135,6 +135,143 @@ func (c *Client) DeviceDelete(id string) error {
 	return nil
 }
 
+func (c *Client) DeviceSetTags(id string,
+	request *api.DeviceTagsRequest) error {
+
+	// Marshal request to JSON
+	buffer, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	// Create a request
+	req, err := http.NewRequest("POST",
+		c.host+"/devices/"+id+"/tags",
+		bytes.NewBuffer(buffer))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return err
+	}
+
+	// Get info
+	r, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	if r.StatusCode != http.StatusOK {
+		return utils.GetErrorFromResponse(r)
+	}
+	return nil
+}
+
+func (c *Client) DeviceResync(id string) (*api.DeviceResyncResponse, error) {
+
+	// Create a request
+	req, err := http.NewRequest("POST", c.host+"/devices/"+id+"/resync", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send request
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, utils.GetErrorFromResponse(r)
+	}
+
+	// Read JSON response
+	var resync api.DeviceResyncResponse
+	err = utils.GetJsonFromResponse(r, &resync)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return &resync, nil
+}
+
+func (c *Client) DeviceHealth(id string) (*api.DeviceHealthResponse, error) {
+
+	// Create request
+	req, err := http.NewRequest("GET", c.host+"/devices/"+id+"/health", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get info
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, utils.GetErrorFromResponse(r)
+	}
+
+	// Read JSON response
+	var health api.DeviceHealthResponse
+	err = utils.GetJsonFromResponse(r, &health)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return &health, nil
+}
+
+func (c *Client) DeviceUsage(id string) (*api.DeviceUsageResponse, error) {
+
+	// Create request
+	req, err := http.NewRequest("GET", c.host+"/devices/"+id+"/usage", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get info
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, utils.GetErrorFromResponse(r)
+	}
+
+	// Read JSON response
+	var usage api.DeviceUsageResponse
+	err = utils.GetJsonFromResponse(r, &usage)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return &usage, nil
+}
+
 func (c *Client) DeviceState(id string,
 	request *api.StateRequest) error {
 
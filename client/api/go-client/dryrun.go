@@ -0,0 +1,98 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+	"github.com/heketi/utils"
+)
+
+// Returns the commands which would be run to create the volume
+func (c *Client) VolumeCreateDryRun(request *api.VolumeCreateRequest) (
+	*api.DryRunResponse, error) {
+	return c.dryRun("POST", "/volumes", request)
+}
+
+// Returns the commands which would be run to expand the volume
+func (c *Client) VolumeExpandDryRun(id string, request *api.VolumeExpandRequest) (
+	*api.DryRunResponse, error) {
+	return c.dryRun("POST", "/volumes/"+id+"/expand", request)
+}
+
+// Returns the commands which would be run to delete the volume
+func (c *Client) VolumeDeleteDryRun(id string) (*api.DryRunResponse, error) {
+	return c.dryRun("DELETE", "/volumes/"+id, nil)
+}
+
+// Returns the commands which would be run to add the device
+func (c *Client) DeviceAddDryRun(request *api.DeviceAddRequest) (
+	*api.DryRunResponse, error) {
+	return c.dryRun("POST", "/devices", request)
+}
+
+func (c *Client) dryRun(method, path string, request interface{}) (
+	*api.DryRunResponse, error) {
+
+	// Marshal request to JSON
+	var body io.Reader
+	if request != nil {
+		buffer, err := json.Marshal(request)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewBuffer(buffer)
+	}
+
+	// Create a request
+	req, err := http.NewRequest(method, c.host+path+"?dryrun=true", body)
+	if err != nil {
+		return nil, err
+	}
+	if request != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send request
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, utils.GetErrorFromResponse(r)
+	}
+
+	// Read JSON response
+	var plan api.DryRunResponse
+	err = utils.GetJsonFromResponse(r, &plan)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return &plan, nil
+}
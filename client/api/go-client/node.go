@@ -75,6 +75,57 @@ func (c *Client) NodeAdd(request *api.NodeAddRequest) (*api.NodeInfoResponse, er
 	return &node, nil
 }
 
+func (c *Client) NodeUpdate(id string,
+	request *api.NodeUpdateRequest) (*api.NodeInfoResponse, error) {
+
+	// Marshal request to JSON
+	buffer, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a request
+	req, err := http.NewRequest("PATCH", c.host+"/nodes/"+id, bytes.NewBuffer(buffer))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send request
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != http.StatusAccepted {
+		return nil, utils.GetErrorFromResponse(r)
+	}
+
+	// Wait for response
+	r, err = c.waitForResponseWithTimer(r, time.Millisecond*250)
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, utils.GetErrorFromResponse(r)
+	}
+
+	// Read JSON response
+	var node api.NodeInfoResponse
+	err = utils.GetJsonFromResponse(r, &node)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return &node, nil
+}
+
 func (c *Client) NodeInfo(id string) (*api.NodeInfoResponse, error) {
 
 	// Create request
@@ -176,3 +227,39 @@ func (c *Client) NodeState(id string, request *api.StateRequest) error {
 	}
 	return nil
 }
+
+// Checks that the server can connect to the node and run
+// commands on it with sudo
+func (c *Client) NodeSshCheck(id string) (*api.NodeSshCheckResponse, error) {
+
+	// Create request
+	req, err := http.NewRequest("GET", c.host+"/nodes/"+id+"/ssh-check", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get info
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, utils.GetErrorFromResponse(r)
+	}
+
+	// Read JSON response
+	var check api.NodeSshCheckResponse
+	err = utils.GetJsonFromResponse(r, &check)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return &check, nil
+}
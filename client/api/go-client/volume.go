@@ -235,3 +235,235 @@ func (c *Client) VolumeDelete(id string) error {
 
 	return nil
 }
+
+func (c *Client) VolumeHealInfo(id string) (*api.VolumeHealResponse, error) {
+
+	// Create request
+	req, err := http.NewRequest("GET", c.host+"/volumes/"+id+"/heal", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get info
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, utils.GetErrorFromResponse(r)
+	}
+
+	// Read JSON response
+	var heal api.VolumeHealResponse
+	err = utils.GetJsonFromResponse(r, &heal)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return &heal, nil
+}
+
+func (c *Client) VolumeHeal(id string) (*api.VolumeHealResponse, error) {
+
+	// Create a request
+	req, err := http.NewRequest("POST", c.host+"/volumes/"+id+"/heal", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send request
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != http.StatusAccepted {
+		return nil, utils.GetErrorFromResponse(r)
+	}
+
+	// Wait for response
+	r, err = c.waitForResponseWithTimer(r, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, utils.GetErrorFromResponse(r)
+	}
+
+	// Read JSON response
+	var heal api.VolumeHealResponse
+	err = utils.GetJsonFromResponse(r, &heal)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return &heal, nil
+}
+
+func (c *Client) VolumeRebalanceStatus(id string) (*api.VolumeRebalanceResponse, error) {
+
+	// Create request
+	req, err := http.NewRequest("GET", c.host+"/volumes/"+id+"/rebalance", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get info
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, utils.GetErrorFromResponse(r)
+	}
+
+	// Read JSON response
+	var status api.VolumeRebalanceResponse
+	err = utils.GetJsonFromResponse(r, &status)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+func (c *Client) VolumeRebalance(id string, request *api.VolumeRebalanceRequest) (
+	*api.VolumeRebalanceResponse, error) {
+
+	// Marshal request to JSON
+	buffer, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a request
+	req, err := http.NewRequest("POST",
+		c.host+"/volumes/"+id+"/rebalance",
+		bytes.NewBuffer(buffer))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send request
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != http.StatusAccepted {
+		return nil, utils.GetErrorFromResponse(r)
+	}
+
+	// Wait for response
+	r, err = c.waitForResponseWithTimer(r, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, utils.GetErrorFromResponse(r)
+	}
+
+	// Read JSON response
+	var status api.VolumeRebalanceResponse
+	err = utils.GetJsonFromResponse(r, &status)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+func (c *Client) VolumeStatus(id string) (*api.VolumeStatusResponse, error) {
+
+	// Create request
+	req, err := http.NewRequest("GET", c.host+"/volumes/"+id+"/status", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get info
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, utils.GetErrorFromResponse(r)
+	}
+
+	// Read JSON response
+	var status api.VolumeStatusResponse
+	err = utils.GetJsonFromResponse(r, &status)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+func (c *Client) VolumeUsage(id string) (*api.VolumeUsageResponse, error) {
+
+	// Create request
+	req, err := http.NewRequest("GET", c.host+"/volumes/"+id+"/usage", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get info
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, utils.GetErrorFromResponse(r)
+	}
+
+	// Read JSON response
+	var usage api.VolumeUsageResponse
+	err = utils.GetJsonFromResponse(r, &usage)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return &usage, nil
+}
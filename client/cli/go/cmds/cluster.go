@@ -20,19 +20,35 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/heketi/heketi/client/api/go-client"
+	"github.com/heketi/heketi/pkg/glusterfs/api"
 	"github.com/spf13/cobra"
 )
 
+var (
+	clusterLabels []string
+)
+
 func init() {
 	RootCmd.AddCommand(clusterCommand)
 	clusterCommand.AddCommand(clusterCreateCommand)
 	clusterCommand.AddCommand(clusterDeleteCommand)
 	clusterCommand.AddCommand(clusterListCommand)
 	clusterCommand.AddCommand(clusterInfoCommand)
+	clusterCommand.AddCommand(clusterSetLabelsCommand)
+
+	clusterCreateCommand.Flags().StringSliceVar(&clusterLabels, "label", []string{},
+		"\n\tOptional: Label of the cluster in the form key=value."+
+			"\n\tCan be given more than once.  Volumes can be placed on clusters"+
+			"\n\twith a label using 'volume create --cluster-selector'")
+	clusterSetLabelsCommand.Flags().StringSliceVar(&clusterLabels, "label", []string{},
+		"\n\tLabel of the cluster in the form key=value."+
+			"\n\tCan be given more than once.  Labels not given are removed")
 	clusterCreateCommand.SilenceUsage = true
+	clusterSetLabelsCommand.SilenceUsage = true
 	clusterDeleteCommand.SilenceUsage = true
 	clusterInfoCommand.SilenceUsage = true
 	clusterListCommand.SilenceUsage = true
@@ -45,15 +61,28 @@ var clusterCommand = &cobra.Command{
 }
 
 var clusterCreateCommand = &cobra.Command{
-	Use:     "create",
-	Short:   "Create a cluster",
-	Long:    "Create a cluster",
-	Example: "  $ heketi-cli cluster create",
+	Use:   "create",
+	Short: "Create a cluster",
+	Long:  "Create a cluster",
+	Example: `  * Create a cluster:
+      $ heketi-cli cluster create
+
+  * Create a cluster used only for block hosting volumes:
+      $ heketi-cli cluster create --label=purpose=block
+`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		// Create request
+		req := &api.ClusterCreateRequest{}
+		labels, err := parseLabels(clusterLabels)
+		if err != nil {
+			return err
+		}
+		req.Labels = labels
+
 		// Create a client to talk to Heketi
 		heketi := client.NewClient(options.Url, options.User, options.Key)
 		// Create cluster
-		cluster, err := heketi.ClusterCreate()
+		cluster, err := heketi.ClusterCreateWithRequest(req)
 		if err != nil {
 			return err
 		}
@@ -134,6 +163,9 @@ var clusterInfoCommand = &cobra.Command{
 			fmt.Fprintf(stdout, string(data))
 		} else {
 			fmt.Fprintf(stdout, "Cluster id: %v\n", info.Id)
+			if len(info.Labels) != 0 {
+				fmt.Fprintf(stdout, "Labels: %v\n", formatLabels(info.Labels))
+			}
 			fmt.Fprintf(stdout, "Nodes:\n%v", strings.Join(info.Nodes, "\n"))
 			fmt.Fprintf(stdout, "\nVolumes:\n%v", strings.Join(info.Volumes, "\n"))
 		}
@@ -142,6 +174,42 @@ var clusterInfoCommand = &cobra.Command{
 	},
 }
 
+var clusterSetLabelsCommand = &cobra.Command{
+	Use:     "setlabels [cluster_id]",
+	Short:   "Replaces the labels of a cluster",
+	Long:    "Replaces the labels of a cluster",
+	Example: "  $ heketi-cli cluster setlabels 886a86a868711bef83001 --label=purpose=block",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s := cmd.Flags().Args()
+
+		//ensure proper number of args
+		if len(s) < 1 {
+			return errors.New("Cluster id missing")
+		}
+
+		//set clusterId
+		clusterId := cmd.Flags().Arg(0)
+
+		labels, err := parseLabels(clusterLabels)
+		if err != nil {
+			return err
+		}
+
+		// Create a client
+		heketi := client.NewClient(options.Url, options.User, options.Key)
+
+		req := &api.ClusterLabelsRequest{
+			Labels: labels,
+		}
+		_, err = heketi.ClusterSetLabels(clusterId, req)
+		if err == nil {
+			fmt.Fprintf(stdout, "Cluster %v labels updated\n", clusterId)
+		}
+
+		return err
+	},
+}
+
 var clusterListCommand = &cobra.Command{
 	Use:     "list",
 	Short:   "Lists the clusters managed by Heketi",
@@ -171,3 +239,31 @@ var clusterListCommand = &cobra.Command{
 		return nil
 	},
 }
+
+// Converts a list of key=value strings to a map
+func parseLabels(labels []string) (map[string]string, error) {
+	if len(labels) == 0 {
+		return nil, nil
+	}
+
+	m := make(map[string]string)
+	for _, label := range labels {
+		kv := strings.SplitN(label, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("Invalid label %v.  Must be in the form key=value", label)
+		}
+		m[kv[0]] = kv[1]
+	}
+
+	return m, nil
+}
+
+func formatLabels(labels map[string]string) string {
+	list := make([]string, 0, len(labels))
+	for key, value := range labels {
+		list = append(list, key+"="+value)
+	}
+	sort.Strings(list)
+
+	return strings.Join(list, ",")
+}
@@ -28,6 +28,7 @@ import (
 
 var (
 	device, nodeId string
+	deviceTags     []string
 )
 
 func init() {
@@ -37,13 +38,27 @@ func init() {
 	deviceCommand.AddCommand(deviceInfoCommand)
 	deviceCommand.AddCommand(deviceEnableCommand)
 	deviceCommand.AddCommand(deviceDisableCommand)
+	deviceCommand.AddCommand(deviceSetTagsCommand)
+	deviceCommand.AddCommand(deviceResyncCommand)
+	deviceCommand.AddCommand(deviceHealthCommand)
+	deviceCommand.AddCommand(deviceUsageCommand)
 	deviceAddCommand.Flags().StringVar(&device, "name", "",
 		"Name of device to add")
 	deviceAddCommand.Flags().StringVar(&nodeId, "node", "",
 		"Id of the node which has this device")
+	deviceAddCommand.Flags().StringSliceVar(&deviceTags, "tag", []string{},
+		"Optional: Tag of the device in the form key=value. Can be given more than once")
+	deviceAddCommand.Flags().BoolVar(&dryRun, "dry-run", false,
+		"Optional: Only show the commands which would be run on the node")
+	deviceSetTagsCommand.Flags().StringSliceVar(&deviceTags, "tag", []string{},
+		"Tag of the device in the form key=value. Can be given more than once")
 	deviceAddCommand.SilenceUsage = true
 	deviceDeleteCommand.SilenceUsage = true
 	deviceInfoCommand.SilenceUsage = true
+	deviceSetTagsCommand.SilenceUsage = true
+	deviceResyncCommand.SilenceUsage = true
+	deviceHealthCommand.SilenceUsage = true
+	deviceUsageCommand.SilenceUsage = true
 }
 
 var deviceCommand = &cobra.Command{
@@ -58,7 +73,8 @@ var deviceAddCommand = &cobra.Command{
 	Long:  "Add new device to node to be managed by Heketi",
 	Example: `  $ heketi-cli device add \
       --name=/dev/sdb
-      --node=3e098cb4407d7109806bb196d9e8f095 `,
+      --node=3e098cb4407d7109806bb196d9e8f095
+      --tag=class=ssd `,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Check arguments
 		if device == "" {
@@ -68,16 +84,30 @@ var deviceAddCommand = &cobra.Command{
 			return errors.New("Missing node id")
 		}
 
+		tags, err := parseLabels(deviceTags)
+		if err != nil {
+			return err
+		}
+
 		// Create request blob
 		req := &api.DeviceAddRequest{}
 		req.Name = device
 		req.NodeId = nodeId
+		req.Tags = tags
 
 		// Create a client
 		heketi := client.NewClient(options.Url, options.User, options.Key)
 
+		if dryRun {
+			plan, err := heketi.DeviceAddDryRun(req)
+			if err != nil {
+				return err
+			}
+			return printDryRun(plan)
+		}
+
 		// Add node
-		err := heketi.DeviceAdd(req)
+		err = heketi.DeviceAdd(req)
 		if err != nil {
 			return err
 		} else {
@@ -160,6 +190,9 @@ var deviceInfoCommand = &cobra.Command{
 				info.Storage.Total/(1024*1024),
 				info.Storage.Used/(1024*1024),
 				info.Storage.Free/(1024*1024))
+			if len(info.Tags) != 0 {
+				fmt.Fprintf(stdout, "Tags: %v\n", formatLabels(info.Tags))
+			}
 
 			fmt.Fprintf(stdout, "Bricks:\n")
 			for _, d := range info.Bricks {
@@ -239,3 +272,201 @@ var deviceDisableCommand = &cobra.Command{
 		return err
 	},
 }
+
+var deviceSetTagsCommand = &cobra.Command{
+	Use:     "settags [device_id]",
+	Short:   "Replaces the tags of a device",
+	Long:    "Replaces the tags of a device",
+	Example: "  $ heketi-cli device settags 886a86a868711bef83001 --tag=class=ssd",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s := cmd.Flags().Args()
+
+		//ensure proper number of args
+		if len(s) < 1 {
+			return errors.New("device id missing")
+		}
+
+		//set deviceId
+		deviceId := cmd.Flags().Arg(0)
+
+		tags, err := parseLabels(deviceTags)
+		if err != nil {
+			return err
+		}
+
+		// Create a client
+		heketi := client.NewClient(options.Url, options.User, options.Key)
+
+		req := &api.DeviceTagsRequest{
+			Tags: tags,
+		}
+		err = heketi.DeviceSetTags(deviceId, req)
+		if err == nil {
+			fmt.Fprintf(stdout, "Device %v tags updated\n", deviceId)
+		}
+
+		return err
+	},
+}
+
+var deviceResyncCommand = &cobra.Command{
+	Use:     "resync [device_id]",
+	Short:   "Updates the size of a device after it has changed",
+	Long:    "Updates the size of a device after it has changed",
+	Example: "  $ heketi-cli device resync 886a86a868711bef83001",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s := cmd.Flags().Args()
+
+		//ensure proper number of args
+		if len(s) < 1 {
+			return errors.New("device id missing")
+		}
+
+		//set deviceId
+		deviceId := cmd.Flags().Arg(0)
+
+		// Create a client
+		heketi := client.NewClient(options.Url, options.User, options.Key)
+
+		info, err := heketi.DeviceResync(deviceId)
+		if err != nil {
+			return err
+		}
+
+		if options.Json {
+			data, err := json.Marshal(info)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(stdout, string(data))
+		} else {
+			fmt.Fprintf(stdout, "Device %v resynced\n"+
+				"Size (GiB): %v -> %v\n"+
+				"Used (GiB): %v -> %v\n"+
+				"Free (GiB): %v -> %v\n",
+				info.Id,
+				info.Previous.Total/(1024*1024),
+				info.Current.Total/(1024*1024),
+				info.Previous.Used/(1024*1024),
+				info.Current.Used/(1024*1024),
+				info.Previous.Free/(1024*1024),
+				info.Current.Free/(1024*1024))
+		}
+
+		return nil
+	},
+}
+
+var deviceHealthCommand = &cobra.Command{
+	Use:     "health [device_id]",
+	Short:   "Retreives the SMART health of the device",
+	Long:    "Retreives the SMART health of the device",
+	Example: "  $ heketi-cli device health 886a86a868711bef83001",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s := cmd.Flags().Args()
+
+		//ensure proper number of args
+		if len(s) < 1 {
+			return errors.New("device id missing")
+		}
+
+		//set deviceId
+		deviceId := cmd.Flags().Arg(0)
+
+		// Create a client
+		heketi := client.NewClient(options.Url, options.User, options.Key)
+
+		info, err := heketi.DeviceHealth(deviceId)
+		if err != nil {
+			return err
+		}
+
+		if options.Json {
+			data, err := json.Marshal(info)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(stdout, string(data))
+		} else {
+			status := "PASSED"
+			if !info.Passed {
+				status = "FAILED"
+			}
+			fmt.Fprintf(stdout, "Device Id: %v\n"+
+				"SMART Status: %v\n"+
+				"Reallocated Sectors: %v\n"+
+				"Pending Sectors: %v\n"+
+				"Temperature (C): %v\n",
+				info.Id,
+				status,
+				info.ReallocatedSectors,
+				info.PendingSectors,
+				info.Temperature)
+		}
+
+		return nil
+	},
+}
+
+var deviceUsageCommand = &cobra.Command{
+	Use:     "usage [device_id]",
+	Short:   "Retreives the actual usage of the bricks on the device",
+	Long:    "Retreives the actual usage of the thin pools and bricks on the device",
+	Example: "  $ heketi-cli device usage 886a86a868711bef83001",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s := cmd.Flags().Args()
+
+		//ensure proper number of args
+		if len(s) < 1 {
+			return errors.New("device id missing")
+		}
+
+		//set deviceId
+		deviceId := cmd.Flags().Arg(0)
+
+		// Create a client
+		heketi := client.NewClient(options.Url, options.User, options.Key)
+
+		usage, err := heketi.DeviceUsage(deviceId)
+		if err != nil {
+			return err
+		}
+
+		if options.Json {
+			data, err := json.Marshal(usage)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(stdout, string(data))
+		} else {
+			fmt.Fprintf(stdout, "Device Id: %v\n"+
+				"Allocated (GiB): %v\n"+
+				"Written (GiB): %v\n"+
+				"Bricks:\n",
+				usage.Id,
+				usage.Storage.Used/(1024*1024),
+				usage.Used/(1024*1024))
+			printBrickUsage(usage.Bricks)
+		}
+
+		return nil
+	},
+}
+
+func printBrickUsage(bricks []api.BrickUsageInfo) {
+	for _, brick := range bricks {
+		warning := ""
+		if brick.PoolWarning {
+			warning = " WARNING"
+		}
+		fmt.Fprintf(stdout, "Id:%-35v Size (GiB):%-8v Used (GiB):%-8v "+
+			"Pool (GiB):%-8v Pool Data:%6.2f%% Pool Metadata:%6.2f%%%v\n",
+			brick.Id,
+			brick.Size/(1024*1024),
+			brick.Used/(1024*1024),
+			brick.PoolSize/(1024*1024),
+			brick.PoolDataPercent,
+			brick.PoolMetadataPercent,
+			warning)
+	}
+}
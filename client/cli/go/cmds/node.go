@@ -31,6 +31,8 @@ var (
 	managmentHostNames string
 	storageHostNames   string
 	clusterId          string
+	sshUser            string
+	sshPort            string
 )
 
 func init() {
@@ -40,13 +42,33 @@ func init() {
 	nodeCommand.AddCommand(nodeInfoCommand)
 	nodeCommand.AddCommand(nodeEnableCommand)
 	nodeCommand.AddCommand(nodeDisableCommand)
+	nodeCommand.AddCommand(nodeMaintenanceCommand)
+	nodeCommand.AddCommand(nodeUpdateCommand)
+	nodeCommand.AddCommand(nodeSshCheckCommand)
 	nodeAddCommand.Flags().IntVar(&zone, "zone", -1, "The zone in which the node should reside")
 	nodeAddCommand.Flags().StringVar(&clusterId, "cluster", "", "The cluster in which the node should reside")
 	nodeAddCommand.Flags().StringVar(&managmentHostNames, "management-host-name", "", "Managment host name")
 	nodeAddCommand.Flags().StringVar(&storageHostNames, "storage-host-name", "", "Storage host name")
+	nodeAddCommand.Flags().StringVar(&sshUser, "ssh-user", "",
+		"Optional: User to ssh to the node as.  Default is the one in the server configuration")
+	nodeAddCommand.Flags().StringVar(&sshPort, "ssh-port", "",
+		"Optional: Port to ssh to the node on.  Default is the one in the server configuration")
+	nodeUpdateCommand.Flags().IntVar(&zone, "zone", -1, "The new zone of the node")
+	nodeUpdateCommand.Flags().StringVar(&managmentHostNames, "management-host-name", "",
+		"The new managment host name")
+	nodeUpdateCommand.Flags().StringVar(&storageHostNames, "storage-host-name", "",
+		"The new storage host name.  Refused while the node has bricks")
+	nodeUpdateCommand.Flags().StringVar(&sshUser, "ssh-user", "",
+		"The new user to ssh to the node as.  Use '"+api.NodeSshDefault+
+			"' for the one in the server configuration")
+	nodeUpdateCommand.Flags().StringVar(&sshPort, "ssh-port", "",
+		"The new port to ssh to the node on.  Use '"+api.NodeSshDefault+
+			"' for the one in the server configuration")
 	nodeAddCommand.SilenceUsage = true
+	nodeUpdateCommand.SilenceUsage = true
 	nodeDeleteCommand.SilenceUsage = true
 	nodeInfoCommand.SilenceUsage = true
+	nodeSshCheckCommand.SilenceUsage = true
 }
 
 var nodeCommand = &cobra.Command{
@@ -86,6 +108,8 @@ var nodeAddCommand = &cobra.Command{
 		req.Hostnames.Manage = []string{managmentHostNames}
 		req.Hostnames.Storage = []string{storageHostNames}
 		req.Zone = zone
+		req.SshUser = sshUser
+		req.SshPort = sshPort
 
 		// Create a client
 		heketi := client.NewClient(options.Url, options.User, options.Key)
@@ -214,6 +238,108 @@ var nodeDisableCommand = &cobra.Command{
 	},
 }
 
+var nodeUpdateCommand = &cobra.Command{
+	Use:   "update [node_id]",
+	Short: "Change the zone or host names of a node",
+	Long:  "Change the zone or host names of a node",
+	Example: `  $ heketi-cli node update 886a86a868711bef83001 \
+      --zone=2 \
+      --storage-host-name=node1-storage2.gluster.lab.com
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s := cmd.Flags().Args()
+
+		//ensure proper number of args
+		if len(s) < 1 {
+			return errors.New("Node id missing")
+		}
+
+		//set nodeId
+		nodeId := cmd.Flags().Arg(0)
+
+		// Create request blob with only the values given
+		req := &api.NodeUpdateRequest{}
+		if zone != -1 {
+			if zone == 0 {
+				return errors.New("Zone cannot be zero")
+			}
+			req.Zone = zone
+		}
+		if managmentHostNames != "" {
+			req.Hostnames.Manage = []string{managmentHostNames}
+		}
+		if storageHostNames != "" {
+			req.Hostnames.Storage = []string{storageHostNames}
+		}
+		req.SshUser = sshUser
+		req.SshPort = sshPort
+		if req.Zone == 0 && req.Hostnames.Manage == nil && req.Hostnames.Storage == nil &&
+			req.SshUser == "" && req.SshPort == "" {
+			return errors.New("Nothing to update")
+		}
+
+		// Create a client
+		heketi := client.NewClient(options.Url, options.User, options.Key)
+
+		// Update node
+		node, err := heketi.NodeUpdate(nodeId, req)
+		if err != nil {
+			return err
+		}
+
+		if options.Json {
+			data, err := json.Marshal(node)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(stdout, string(data))
+		} else {
+			fmt.Fprintf(stdout, "Node %v updated\n"+
+				"Zone: %v\n"+
+				"Management Hostname: %v\n"+
+				"Storage Hostname: %v\n",
+				node.Id,
+				node.Zone,
+				node.Hostnames.Manage[0],
+				node.Hostnames.Storage[0])
+		}
+
+		return nil
+	},
+}
+
+var nodeMaintenanceCommand = &cobra.Command{
+	Use:     "maintenance [node_id]",
+	Short:   "Place a node in maintenance before servicing it",
+	Long:    "Place a node in maintenance before servicing it. Use enable when done",
+	Example: "  $ heketi-cli node maintenance 886a86a868711bef83001",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s := cmd.Flags().Args()
+
+		//ensure proper number of args
+		if len(s) < 1 {
+			return errors.New("Node id missing")
+		}
+
+		//set clusterId
+		nodeId := cmd.Flags().Arg(0)
+
+		// Create a client
+		heketi := client.NewClient(options.Url, options.User, options.Key)
+
+		//set url
+		req := &api.StateRequest{
+			State: api.EntryStateMaintenance,
+		}
+		err := heketi.NodeState(nodeId, req)
+		if err == nil {
+			fmt.Fprintf(stdout, "Node %v is now in maintenance\n", nodeId)
+		}
+
+		return err
+	},
+}
+
 var nodeInfoCommand = &cobra.Command{
 	Use:     "info [node_id]",
 	Short:   "Retreives information about the node",
@@ -257,6 +383,18 @@ var nodeInfoCommand = &cobra.Command{
 				info.Zone,
 				info.Hostnames.Manage[0],
 				info.Hostnames.Storage[0])
+			if info.SshUser != "" {
+				fmt.Fprintf(stdout, "SSH User: %v\n", info.SshUser)
+			}
+			if info.SshPort != "" {
+				fmt.Fprintf(stdout, "SSH Port: %v\n", info.SshPort)
+			}
+			if info.LastSeen != "" {
+				fmt.Fprintf(stdout, "Last Seen: %v\n", info.LastSeen)
+			}
+			if info.HeartbeatFailures != 0 {
+				fmt.Fprintf(stdout, "Heartbeat Failures: %v\n", info.HeartbeatFailures)
+			}
 			fmt.Fprintf(stdout, "Devices:\n")
 			for _, d := range info.DevicesInfo {
 				fmt.Fprintf(stdout, "Id:%-35v"+
@@ -276,3 +414,54 @@ var nodeInfoCommand = &cobra.Command{
 		return nil
 	},
 }
+
+var nodeSshCheckCommand = &cobra.Command{
+	Use:     "ssh-check [node_id]",
+	Short:   "Checks that the server can reach the node and use sudo on it",
+	Long:    "Checks that the server can reach the node and use sudo on it",
+	Example: "  $ heketi-cli node ssh-check 886a86a868711bef83001",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		//ensure proper number of args
+		s := cmd.Flags().Args()
+		if len(s) < 1 {
+			return errors.New("Node id missing")
+		}
+
+		// Set node id
+		nodeId := cmd.Flags().Arg(0)
+
+		// Create a client to talk to Heketi
+		heketi := client.NewClient(options.Url, options.User, options.Key)
+
+		// Check node
+		check, err := heketi.NodeSshCheck(nodeId)
+		if err != nil {
+			return err
+		}
+
+		if options.Json {
+			data, err := json.Marshal(check)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(stdout, string(data))
+		} else {
+			fmt.Fprintf(stdout, "Node Id: %v\n"+
+				"Management Hostname: %v\n"+
+				"Reachable: %v\n"+
+				"Sudo: %v\n",
+				check.Id,
+				check.Host,
+				check.Reachable,
+				check.Sudo)
+			if check.Error != "" {
+				fmt.Fprintf(stdout, "Error: %v\n", check.Error)
+			}
+		}
+
+		if !check.Reachable || !check.Sudo {
+			return fmt.Errorf("Check of node %v failed", nodeId)
+		}
+		return nil
+	},
+}
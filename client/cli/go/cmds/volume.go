@@ -45,6 +45,11 @@ var (
 	kubePvFile     string
 	kubePvEndpoint string
 	kubePv         bool
+	selector       []string
+	deviceSelector []string
+	waitRebalance  bool
+	stopRebalance  bool
+	dryRun         bool
 )
 
 func init() {
@@ -54,6 +59,12 @@ func init() {
 	volumeCommand.AddCommand(volumeExpandCommand)
 	volumeCommand.AddCommand(volumeInfoCommand)
 	volumeCommand.AddCommand(volumeListCommand)
+	volumeCommand.AddCommand(volumeHealInfoCommand)
+	volumeCommand.AddCommand(volumeHealCommand)
+	volumeCommand.AddCommand(volumeRebalanceStatusCommand)
+	volumeCommand.AddCommand(volumeRebalanceCommand)
+	volumeCommand.AddCommand(volumeStatusCommand)
+	volumeCommand.AddCommand(volumeUsageCommand)
 
 	volumeCreateCommand.Flags().IntVar(&size, "size", -1,
 		"\n\tSize of volume in GB")
@@ -84,6 +95,14 @@ func init() {
 			"\n\ton any of the configured clusters which have the available space."+
 			"\n\tProviding a set of clusters will ensure Heketi allocates storage"+
 			"\n\tfor this volume only in the clusters specified.")
+	volumeCreateCommand.Flags().StringSliceVar(&selector, "cluster-selector", []string{},
+		"\n\tOptional: Cluster label in the form key=value.  The volume will only"+
+			"\n\tbe allocated on clusters which have all the labels given."+
+			"\n\tCan be given more than once.")
+	volumeCreateCommand.Flags().StringSliceVar(&deviceSelector, "device-selector", []string{},
+		"\n\tOptional: Device tag in the form key=value.  The bricks of the volume"+
+			"\n\twill only be allocated on devices which have all the tags given."+
+			"\n\tCan be given more than once.")
 	volumeCreateCommand.Flags().BoolVar(&kubePv, "persistent-volume", false,
 		"\n\tOptional: Output to standard out a peristent volume JSON file for OpenShift or"+
 			"\n\tKubernetes with the name provided.")
@@ -96,11 +115,28 @@ func init() {
 		"\n\tAmount in GB to add to the volume")
 	volumeExpandCommand.Flags().StringVar(&id, "volume", "",
 		"\n\tId of volume to expand")
+	volumeExpandCommand.Flags().BoolVar(&waitRebalance, "wait-for-rebalance", false,
+		"\n\tOptional: Rebalance the volume after adding the new bricks and"+
+			"\n\twait until the rebalance has finished")
+	volumeRebalanceCommand.Flags().BoolVar(&stopRebalance, "stop", false,
+		"\n\tOptional: Stop the rebalance instead of starting it")
+	volumeCreateCommand.Flags().BoolVar(&dryRun, "dry-run", false,
+		"\n\tOptional: Only show the commands which would be run on each node")
+	volumeExpandCommand.Flags().BoolVar(&dryRun, "dry-run", false,
+		"\n\tOptional: Only show the commands which would be run on each node")
+	volumeDeleteCommand.Flags().BoolVar(&dryRun, "dry-run", false,
+		"\n\tOptional: Only show the commands which would be run on each node")
 	volumeCreateCommand.SilenceUsage = true
 	volumeDeleteCommand.SilenceUsage = true
 	volumeExpandCommand.SilenceUsage = true
 	volumeInfoCommand.SilenceUsage = true
 	volumeListCommand.SilenceUsage = true
+	volumeHealInfoCommand.SilenceUsage = true
+	volumeHealCommand.SilenceUsage = true
+	volumeRebalanceStatusCommand.SilenceUsage = true
+	volumeRebalanceCommand.SilenceUsage = true
+	volumeStatusCommand.SilenceUsage = true
+	volumeUsageCommand.SilenceUsage = true
 }
 
 var volumeCommand = &cobra.Command{
@@ -120,6 +156,12 @@ var volumeCreateCommand = &cobra.Command{
       $ heketi-cli volume create --size=100 \
         --clusters=0995098e1284ddccb46c7752d142c832,60d46d518074b13a04ce1022c8c7193c
 
+  * Create a 100GB replica 3 volume on a cluster labeled purpose=file:
+      $ heketi-cli volume create --size=100 --cluster-selector=purpose=file
+
+  * Create a 100GB replica 3 volume only on devices tagged class=ssd:
+      $ heketi-cli volume create --size=100 --device-selector=class=ssd
+
   * Create a 100GB replica 2 volume with 50GB of snapshot storage:
       $ heketi-cli volume create --size=100 --snapshot-factor=1.5 --replica=2
 
@@ -151,10 +193,24 @@ var volumeCreateCommand = &cobra.Command{
 			clusters_ = strings.Split(clusters, ",")
 		}
 
+		// Check cluster selector
+		clusterSelector, err := parseLabels(selector)
+		if err != nil {
+			return err
+		}
+
+		// Check device selector
+		devSelector, err := parseLabels(deviceSelector)
+		if err != nil {
+			return err
+		}
+
 		// Create request blob
 		req := &api.VolumeCreateRequest{}
 		req.Size = size
 		req.Clusters = clusters_
+		req.ClusterSelector = clusterSelector
+		req.DeviceSelector = devSelector
 		req.Durability.Type = api.DurabilityType(durability)
 		req.Durability.Replicate.Replica = replica
 		req.Durability.Disperse.Data = disperseData
@@ -172,6 +228,14 @@ var volumeCreateCommand = &cobra.Command{
 		// Create a client
 		heketi := client.NewClient(options.Url, options.User, options.Key)
 
+		if dryRun {
+			plan, err := heketi.VolumeCreateDryRun(req)
+			if err != nil {
+				return err
+			}
+			return printDryRun(plan)
+		}
+
 		// Add volume
 		volume, err := heketi.VolumeCreate(req)
 		if err != nil {
@@ -258,6 +322,14 @@ var volumeDeleteCommand = &cobra.Command{
 		// Create a client
 		heketi := client.NewClient(options.Url, options.User, options.Key)
 
+		if dryRun {
+			plan, err := heketi.VolumeDeleteDryRun(volumeId)
+			if err != nil {
+				return err
+			}
+			return printDryRun(plan)
+		}
+
 		//set url
 		err := heketi.VolumeDelete(volumeId)
 		if err == nil {
@@ -288,10 +360,19 @@ var volumeExpandCommand = &cobra.Command{
 		// Create request
 		req := &api.VolumeExpandRequest{}
 		req.Size = expandSize
+		req.WaitForRebalance = waitRebalance
 
 		// Create client
 		heketi := client.NewClient(options.Url, options.User, options.Key)
 
+		if dryRun {
+			plan, err := heketi.VolumeExpandDryRun(id, req)
+			if err != nil {
+				return err
+			}
+			return printDryRun(plan)
+		}
+
 		// Expand volume
 		volume, err := heketi.VolumeExpand(id, req)
 		if err != nil {
@@ -378,3 +459,300 @@ var volumeListCommand = &cobra.Command{
 		return nil
 	},
 }
+
+var volumeHealInfoCommand = &cobra.Command{
+	Use:     "heal-info [volume_id]",
+	Short:   "Retreives the self-heal status of the volume",
+	Long:    "Retreives the self-heal status of each brick in the volume",
+	Example: "  $ heketi-cli volume heal-info 886a86a868711bef83001",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		//ensure proper number of args
+		s := cmd.Flags().Args()
+		if len(s) < 1 {
+			return errors.New("Volume id missing")
+		}
+
+		// Set volume id
+		volumeId := cmd.Flags().Arg(0)
+
+		// Create a client to talk to Heketi
+		heketi := client.NewClient(options.Url, options.User, options.Key)
+
+		heal, err := heketi.VolumeHealInfo(volumeId)
+		if err != nil {
+			return err
+		}
+
+		return printVolumeHeal(heal)
+	},
+}
+
+var volumeHealCommand = &cobra.Command{
+	Use:     "heal [volume_id]",
+	Short:   "Starts a full self-heal of the volume",
+	Long:    "Starts a full self-heal of the volume",
+	Example: "  $ heketi-cli volume heal 886a86a868711bef83001",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		//ensure proper number of args
+		s := cmd.Flags().Args()
+		if len(s) < 1 {
+			return errors.New("Volume id missing")
+		}
+
+		// Set volume id
+		volumeId := cmd.Flags().Arg(0)
+
+		// Create a client to talk to Heketi
+		heketi := client.NewClient(options.Url, options.User, options.Key)
+
+		heal, err := heketi.VolumeHeal(volumeId)
+		if err != nil {
+			return err
+		}
+
+		return printVolumeHeal(heal)
+	},
+}
+
+func printVolumeHeal(heal *api.VolumeHealResponse) error {
+	if options.Json {
+		data, err := json.Marshal(heal)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, string(data))
+		return nil
+	}
+
+	fmt.Fprintf(stdout, "Volume Id: %v\n"+
+		"Pending Entries: %v\n"+
+		"Complete: %v\n"+
+		"Bricks:\n",
+		heal.Id,
+		heal.Pending,
+		heal.Complete)
+	for _, brick := range heal.Bricks {
+		entries := fmt.Sprintf("%v", brick.Entries)
+		if brick.Entries < 0 {
+			entries = "-"
+		}
+		fmt.Fprintf(stdout, "Id:%-35v Name:%-40v Connected:%-6v Entries:%v\n",
+			brick.Id,
+			brick.Name,
+			brick.Connected,
+			entries)
+	}
+	return nil
+}
+
+var volumeRebalanceStatusCommand = &cobra.Command{
+	Use:     "rebalance-status [volume_id]",
+	Short:   "Retreives the rebalance progress of the volume",
+	Long:    "Retreives the rebalance progress of the volume on each node",
+	Example: "  $ heketi-cli volume rebalance-status 886a86a868711bef83001",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		//ensure proper number of args
+		s := cmd.Flags().Args()
+		if len(s) < 1 {
+			return errors.New("Volume id missing")
+		}
+
+		// Set volume id
+		volumeId := cmd.Flags().Arg(0)
+
+		// Create a client to talk to Heketi
+		heketi := client.NewClient(options.Url, options.User, options.Key)
+
+		status, err := heketi.VolumeRebalanceStatus(volumeId)
+		if err != nil {
+			return err
+		}
+
+		return printVolumeRebalance(status)
+	},
+}
+
+var volumeRebalanceCommand = &cobra.Command{
+	Use:   "rebalance [volume_id]",
+	Short: "Starts or stops a rebalance of the volume",
+	Long:  "Starts or stops a rebalance of the volume",
+	Example: `  * Start a rebalance
+    $ heketi-cli volume rebalance 886a86a868711bef83001
+
+  * Stop a running rebalance
+    $ heketi-cli volume rebalance 886a86a868711bef83001 --stop
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		//ensure proper number of args
+		s := cmd.Flags().Args()
+		if len(s) < 1 {
+			return errors.New("Volume id missing")
+		}
+
+		// Set volume id
+		volumeId := cmd.Flags().Arg(0)
+
+		// Create request
+		req := &api.VolumeRebalanceRequest{}
+		if stopRebalance {
+			req.Action = "stop"
+		} else {
+			req.Action = "start"
+		}
+
+		// Create a client to talk to Heketi
+		heketi := client.NewClient(options.Url, options.User, options.Key)
+
+		status, err := heketi.VolumeRebalance(volumeId, req)
+		if err != nil {
+			return err
+		}
+
+		return printVolumeRebalance(status)
+	},
+}
+
+func printVolumeRebalance(status *api.VolumeRebalanceResponse) error {
+	if options.Json {
+		data, err := json.Marshal(status)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, string(data))
+		return nil
+	}
+
+	fmt.Fprintf(stdout, "Volume Id: %v\n"+
+		"Status: %v\n"+
+		"Nodes:\n",
+		status.Id,
+		status.Status)
+	for _, node := range status.Nodes {
+		fmt.Fprintf(stdout, "Name:%-30v Status:%-15v Files:%-8v Size:%-12v "+
+			"Scanned:%-8v Failures:%-6v Skipped:%-6v Run Time (s):%.2f\n",
+			node.Name,
+			node.Status,
+			node.Files,
+			node.Size,
+			node.Lookups,
+			node.Failures,
+			node.Skipped,
+			node.RunTime)
+	}
+	return nil
+}
+
+var volumeStatusCommand = &cobra.Command{
+	Use:     "status [volume_id]",
+	Short:   "Retreives the live status of the bricks in the volume",
+	Long:    "Retreives the live status of the bricks in the volume",
+	Example: "  $ heketi-cli volume status 886a86a868711bef83001",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		//ensure proper number of args
+		s := cmd.Flags().Args()
+		if len(s) < 1 {
+			return errors.New("Volume id missing")
+		}
+
+		// Set volume id
+		volumeId := cmd.Flags().Arg(0)
+
+		// Create a client to talk to Heketi
+		heketi := client.NewClient(options.Url, options.User, options.Key)
+
+		status, err := heketi.VolumeStatus(volumeId)
+		if err != nil {
+			return err
+		}
+
+		if options.Json {
+			data, err := json.Marshal(status)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(stdout, string(data))
+			return nil
+		}
+
+		fmt.Fprintf(stdout, "Volume Id: %v\n"+
+			"Degraded: %v\n"+
+			"Bricks:\n",
+			status.Id,
+			status.Degraded)
+		for _, brick := range status.Bricks {
+			fmt.Fprintf(stdout, "Id:%-35v Name:%-40v Online:%-6v Port:%-6v Pid:%-7v "+
+				"Size (GiB):%-8v Free (GiB):%-8v Inodes Free:%v/%v\n",
+				brick.Id,
+				brick.Name,
+				brick.Online,
+				brick.Port,
+				brick.Pid,
+				brick.Storage.Total/(1024*1024),
+				brick.Storage.Free/(1024*1024),
+				brick.InodesFree,
+				brick.InodesTotal)
+		}
+		return nil
+	},
+}
+
+var volumeUsageCommand = &cobra.Command{
+	Use:     "usage [volume_id]",
+	Short:   "Retreives the actual usage of the bricks in the volume",
+	Long:    "Retreives the actual usage of the bricks in the volume",
+	Example: "  $ heketi-cli volume usage 886a86a868711bef83001",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		//ensure proper number of args
+		s := cmd.Flags().Args()
+		if len(s) < 1 {
+			return errors.New("Volume id missing")
+		}
+
+		// Set volume id
+		volumeId := cmd.Flags().Arg(0)
+
+		// Create a client to talk to Heketi
+		heketi := client.NewClient(options.Url, options.User, options.Key)
+
+		usage, err := heketi.VolumeUsage(volumeId)
+		if err != nil {
+			return err
+		}
+
+		if options.Json {
+			data, err := json.Marshal(usage)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(stdout, string(data))
+			return nil
+		}
+
+		fmt.Fprintf(stdout, "Volume Id: %v\n"+
+			"Used on all bricks (GiB): %v\n"+
+			"Bricks:\n",
+			usage.Id,
+			usage.Used/(1024*1024))
+		printBrickUsage(usage.Bricks)
+		return nil
+	},
+}
+
+func printDryRun(plan *api.DryRunResponse) error {
+	if options.Json {
+		data, err := json.Marshal(plan)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, string(data))
+		return nil
+	}
+
+	for _, host := range plan.Commands {
+		fmt.Fprintf(stdout, "%v:\n", host.Host)
+		for _, command := range host.Commands {
+			fmt.Fprintf(stdout, "    %v\n", command)
+		}
+	}
+	return nil
+}
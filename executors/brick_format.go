@@ -0,0 +1,71 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package executors
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+)
+
+// Limits of the chunk size of lvm thin pools in KB
+const (
+	MinChunkSize = 64
+	MaxChunkSize = 1024 * 1024
+)
+
+var (
+	// The options and mount root end up in shell commands and
+	// fstab, so only plain characters are allowed
+	mkfsOptionsRegexp  = regexp.MustCompile(`^[A-Za-z0-9 =,._:/+-]*$`)
+	mountOptionsRegexp = regexp.MustCompile(`^[A-Za-z0-9=,._:/+-]*$`)
+	mountRootRegexp    = regexp.MustCompile(`^[A-Za-z0-9._/+-]*$`)
+)
+
+// Checks the settings which are set
+func (f *BrickFormat) Validate() error {
+	if !mkfsOptionsRegexp.MatchString(f.MkfsOptions) {
+		return fmt.Errorf("Invalid characters in mkfs options %q", f.MkfsOptions)
+	}
+	if !mountOptionsRegexp.MatchString(f.MountOptions) {
+		return fmt.Errorf("Invalid characters in mount options %q", f.MountOptions)
+	}
+
+	if f.ChunkSize < 0 {
+		return fmt.Errorf("Chunk size cannot be negative")
+	}
+	if f.ChunkSize != 0 && (f.ChunkSize < MinChunkSize ||
+		f.ChunkSize > MaxChunkSize ||
+		f.ChunkSize%MinChunkSize != 0) {
+		return fmt.Errorf("Chunk size %vK must be a multiple of %vK between %vK and %vK",
+			f.ChunkSize, MinChunkSize, MinChunkSize, MaxChunkSize)
+	}
+
+	if f.MountRoot != "" {
+		if !mountRootRegexp.MatchString(f.MountRoot) {
+			return fmt.Errorf("Invalid characters in mount root %q", f.MountRoot)
+		}
+		if !path.IsAbs(f.MountRoot) ||
+			path.Clean(f.MountRoot) != f.MountRoot ||
+			f.MountRoot == "/" {
+			return fmt.Errorf("Mount root %v must be a clean absolute path other than /",
+				f.MountRoot)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package executors
+
+import (
+	"testing"
+
+	"github.com/heketi/tests"
+)
+
+func TestBrickFormatValidate(t *testing.T) {
+	// Nothing set uses the defaults
+	f := &BrickFormat{}
+	tests.Assert(t, f.Validate() == nil)
+
+	f = &BrickFormat{
+		MkfsOptions:  "-i size=512 -n size=8192 -d su=128k,sw=10",
+		MountOptions: "rw,inode64,noatime,nouuid,logbsize=256k",
+		ChunkSize:    1280,
+		MountRoot:    "/srv/heketi/mounts",
+	}
+	tests.Assert(t, f.Validate() == nil)
+
+	for _, bad := range []BrickFormat{
+		{MkfsOptions: "-i size=512; rm -rf /"},
+		{MkfsOptions: "$(reboot)"},
+		{MountOptions: "rw noatime"},
+		{MountOptions: "rw,noatime\" /etc/fstab"},
+		{ChunkSize: -64},
+		{ChunkSize: 32},
+		{ChunkSize: 100},
+		{ChunkSize: MaxChunkSize + MinChunkSize},
+		{MountRoot: "relative/mounts"},
+		{MountRoot: "/"},
+		{MountRoot: "/var/lib/heketi/mounts/"},
+		{MountRoot: "/var/lib/../mounts"},
+		{MountRoot: "/var/lib/heketi mounts"},
+	} {
+		tests.Assert(t, bad.Validate() != nil, bad)
+	}
+}
@@ -0,0 +1,120 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dryrunexec
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/lpabon/godbc"
+
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/executors/sshexec"
+)
+
+// Commands which would have been run on a host, in order
+type HostCommands struct {
+	Host     string
+	Commands []string
+}
+
+// Generates the same commands as the ssh executor, but records
+// them instead of running them.  Commands which only read the state
+// of a node are answered as if the node was ready for the operation.
+type DryRunExecutor struct {
+	// Embed all sshexecutor functions
+	sshexec.SshExecutor
+
+	planLock sync.Mutex
+	plan     []HostCommands
+}
+
+const (
+	// Answer to vgdisplay -c for a new 1TB volume group with 4MB extents
+	dryRunVgDisplay = "vg:r/w:772:-1:0:0:0:-1:0:1:1:1073741824:4096:262143:0:262143:dryrun"
+)
+
+// The ssh configuration, if any, changes the commands generated in
+// the same way as for the ssh executor, like the brick format and
+// the rebalance after a volume expansion
+func NewDryRunExecutor(fstab string, config *sshexec.SshConfig) *DryRunExecutor {
+	d := &DryRunExecutor{}
+	d.Throttlemap = make(map[string]chan bool)
+	d.RemoteExecutor = d
+	d.plan = make([]HostCommands, 0)
+	d.SetConfig(config)
+
+	if fstab == "" {
+		d.Fstab = "/etc/fstab"
+	} else {
+		d.Fstab = fstab
+	}
+
+	godbc.Ensure(d.Fstab != "")
+
+	return d
+}
+
+func (d *DryRunExecutor) RemoteCommandExecute(host string,
+	commands []string,
+	timeoutMinutes int) ([]executors.CommandResult, error) {
+
+	d.planLock.Lock()
+	defer d.planLock.Unlock()
+
+	// Commands sent to the same host one after another are
+	// shown together
+	if n := len(d.plan); n > 0 && d.plan[n-1].Host == host {
+		d.plan[n-1].Commands = append(d.plan[n-1].Commands, commands...)
+	} else {
+		d.plan = append(d.plan, HostCommands{
+			Host:     host,
+			Commands: append([]string{}, commands...),
+		})
+	}
+
+	results := make([]executors.CommandResult, len(commands))
+	for i, command := range commands {
+		results[i].Command = command
+		if strings.Contains(command, "vgdisplay -c") {
+			results[i].Stdout = dryRunVgDisplay
+		}
+	}
+
+	return results, nil
+}
+
+// Returns the commands recorded so far
+func (d *DryRunExecutor) Plan() []HostCommands {
+	d.planLock.Lock()
+	defer d.planLock.Unlock()
+
+	plan := make([]HostCommands, len(d.plan))
+	copy(plan, d.plan)
+	return plan
+}
+
+// The checks only read the state of the node, so they are
+// not part of the plan
+func (d *DryRunExecutor) BrickDestroyCheck(host string,
+	brick *executors.BrickRequest) error {
+	return nil
+}
+
+func (d *DryRunExecutor) VolumeDestroyCheck(host, volume string) error {
+	return nil
+}
@@ -0,0 +1,142 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dryrunexec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/executors/sshexec"
+	"github.com/heketi/tests"
+)
+
+func TestNewDryRunExecutor(t *testing.T) {
+	d := NewDryRunExecutor("myfstab", nil)
+	tests.Assert(t, d.Fstab == "myfstab")
+	tests.Assert(t, d.Throttlemap != nil)
+	tests.Assert(t, len(d.Plan()) == 0)
+
+	// Defaults
+	d = NewDryRunExecutor("", nil)
+	tests.Assert(t, d.Fstab == "/etc/fstab")
+}
+
+func TestDryRunExecutorDeviceSetup(t *testing.T) {
+	d := NewDryRunExecutor("", nil)
+
+	info, err := d.DeviceSetup("host1", "/dev/sdb", "abc")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, info.Size == 4096*262143, info.Size)
+	tests.Assert(t, info.ExtentSize == 4096)
+
+	// All the commands for the host are shown together
+	plan := d.Plan()
+	tests.Assert(t, len(plan) == 1)
+	tests.Assert(t, plan[0].Host == "host1")
+	tests.Assert(t, len(plan[0].Commands) == 3, plan[0].Commands)
+	tests.Assert(t, strings.Contains(plan[0].Commands[0], "pvcreate"))
+	tests.Assert(t, strings.Contains(plan[0].Commands[1], "vgcreate vg_abc /dev/sdb"))
+	tests.Assert(t, strings.Contains(plan[0].Commands[2], "vgdisplay -c vg_abc"))
+}
+
+func TestDryRunExecutorBrickCreate(t *testing.T) {
+	d := NewDryRunExecutor("", nil)
+
+	for _, host := range []string{"host1", "host2"} {
+		brick, err := d.BrickCreate(host, &executors.BrickRequest{
+			VgId:             "xvgid",
+			Name:             "id",
+			TpSize:           100,
+			Size:             10,
+			PoolMetadataSize: 5,
+		})
+		tests.Assert(t, err == nil)
+		tests.Assert(t, brick.Path == "/var/lib/heketi/mounts/vg_xvgid/brick_id/brick")
+	}
+
+	// Commands for each host are kept apart
+	plan := d.Plan()
+	tests.Assert(t, len(plan) == 2)
+	tests.Assert(t, plan[0].Host == "host1")
+	tests.Assert(t, plan[1].Host == "host2")
+	for _, host := range plan {
+		tests.Assert(t, len(host.Commands) == 6, host.Commands)
+		tests.Assert(t, strings.Contains(host.Commands[1], "lvcreate"))
+		tests.Assert(t, strings.Contains(host.Commands[2], "mkfs.xfs"))
+	}
+
+	// The plan returned is a copy
+	plan[0].Host = "changed"
+	tests.Assert(t, d.Plan()[0].Host == "host1")
+}
+
+func TestDryRunExecutorVolumeDestroy(t *testing.T) {
+	d := NewDryRunExecutor("", nil)
+
+	// Checks are not part of the plan
+	err := d.VolumeDestroyCheck("host1", "vol")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, len(d.Plan()) == 0)
+
+	err = d.VolumeDestroy("host1", "vol")
+	tests.Assert(t, err == nil)
+	plan := d.Plan()
+	tests.Assert(t, len(plan) == 1)
+	tests.Assert(t, len(plan[0].Commands) == 2, plan[0].Commands)
+	tests.Assert(t, strings.Contains(plan[0].Commands[0], "volume stop vol"))
+	tests.Assert(t, strings.Contains(plan[0].Commands[1], "volume delete vol"))
+}
+
+func TestDryRunExecutorSshConfig(t *testing.T) {
+	d := NewDryRunExecutor("", &sshexec.SshConfig{
+		BrickMkfsOptions:     "-i size=1024",
+		BrickMountRoot:       "/bricks",
+		RebalanceOnExpansion: true,
+	})
+
+	// The brick format of the configuration is used
+	brick, err := d.BrickCreate("host1", &executors.BrickRequest{
+		VgId:             "xvgid",
+		Name:             "id",
+		TpSize:           100,
+		Size:             10,
+		PoolMetadataSize: 5,
+	})
+	tests.Assert(t, err == nil)
+	tests.Assert(t, brick.Path == "/bricks/vg_xvgid/brick_id/brick", brick.Path)
+
+	plan := d.Plan()
+	tests.Assert(t, len(plan) == 1)
+	tests.Assert(t, strings.Contains(plan[0].Commands[2], "mkfs.xfs -i size=1024"),
+		plan[0].Commands[2])
+
+	// Expansion is followed by a rebalance
+	_, err = d.VolumeExpand("host1", &executors.VolumeRequest{
+		Bricks: []executors.BrickInfo{
+			executors.BrickInfo{Host: "host1", Path: brick.Path},
+		},
+		Name: "vol",
+		Type: executors.DurabilityNone,
+	})
+	tests.Assert(t, err == nil)
+
+	plan = d.Plan()
+	commands := plan[0].Commands
+	tests.Assert(t, strings.Contains(commands[len(commands)-1], "volume rebalance vol start"),
+		commands)
+}
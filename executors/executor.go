@@ -16,11 +16,18 @@
 
 package executors
 
+import (
+	"strings"
+)
+
 type Executor interface {
 	PeerProbe(exec_host, newnode string) error
 	PeerDetach(exec_host, detachnode string) error
 	DeviceSetup(host, device, vgid string) (*DeviceInfo, error)
 	DeviceTeardown(host, device, vgid string) error
+	DeviceResync(host, device, vgid string) (*DeviceInfo, error)
+	DeviceHealth(host, device string) (*DeviceHealthInfo, error)
+	DeviceUsage(host, vgid string) (*DeviceUsageInfo, error)
 	BrickCreate(host string, brick *BrickRequest) (*BrickInfo, error)
 	BrickDestroy(host string, brick *BrickRequest) error
 	BrickDestroyCheck(host string, brick *BrickRequest) error
@@ -28,9 +35,24 @@ type Executor interface {
 	VolumeDestroy(host string, volume string) error
 	VolumeDestroyCheck(host, volume string) error
 	VolumeExpand(host string, volume *VolumeRequest) (*VolumeInfo, error)
+	VolumeHealInfo(host string, volume string) (*VolumeHealInfo, error)
+	VolumeHeal(host string, volume string) error
+	VolumeRebalanceStart(host string, volume string) error
+	VolumeRebalanceStop(host string, volume string) error
+	VolumeRebalanceStatus(host string, volume string) (*VolumeRebalanceInfo, error)
+	VolumeStatus(host string, volume string) (*VolumeStatusInfo, error)
+	Ping(host string) error
+	SetHostConnection(host string, conn HostConnection)
 	SetLogLevel(level string)
 }
 
+// Settings used to connect to a node instead of those of the
+// executor.  Values which are not set use the executor settings.
+type HostConnection struct {
+	User string
+	Port string
+}
+
 // Enumerate durability types
 type DurabilityType int
 
@@ -47,6 +69,33 @@ type DeviceInfo struct {
 	ExtentSize uint64
 }
 
+// Health of the disk as reported by SMART
+type DeviceHealthInfo struct {
+	Passed             bool
+	ReallocatedSectors uint64
+	PendingSectors     uint64
+
+	// Temperature in Celsius
+	Temperature int
+}
+
+// Actual usage of the bricks on a device, keyed by brick id
+type DeviceUsageInfo struct {
+	Bricks map[string]*BrickUsageInfo
+}
+
+type BrickUsageInfo struct {
+	// Size of the thin pool in KB and how full it is
+	PoolSize            uint64
+	PoolDataPercent     float64
+	PoolMetadataPercent float64
+
+	// Sizes in KB of the mounted brick filesystem,
+	// zero if it is not mounted
+	Size uint64
+	Used uint64
+}
+
 // Brick description
 type BrickRequest struct {
 	VgId             string
@@ -54,6 +103,27 @@ type BrickRequest struct {
 	TpSize           uint64
 	Size             uint64
 	PoolMetadataSize uint64
+
+	// Formatting and mounting of the brick.  Settings which are
+	// not set use those of the executor.
+	Format BrickFormat
+
+	// Path of an existing brick, used to find its mount point
+	// after the mount root has changed.  Empty for new bricks.
+	Path string
+}
+
+// How bricks are formatted and mounted
+type BrickFormat struct {
+	// Options given to mkfs.xfs and mount
+	MkfsOptions  string
+	MountOptions string
+
+	// Chunk size of the thin pool in KB
+	ChunkSize int
+
+	// Directory the bricks are mounted under
+	MountRoot string
 }
 
 // Returns information about the location of the brick
@@ -77,3 +147,77 @@ type VolumeRequest struct {
 
 type VolumeInfo struct {
 }
+
+// Self-heal status of each brick in a volume
+type VolumeHealInfo struct {
+	Bricks []BrickHealInfo
+}
+
+type BrickHealInfo struct {
+	// Brick in host:path format
+	Name      string
+	Connected bool
+
+	// Entries pending heal, or -1 if the brick could not be read
+	Entries int
+}
+
+// Returns the total entries pending heal and false if any
+// brick could not be read
+func (h *VolumeHealInfo) Pending() (int, bool) {
+	pending := 0
+	complete := true
+	for _, brick := range h.Bricks {
+		if !brick.Connected || brick.Entries < 0 {
+			complete = false
+			continue
+		}
+		pending += brick.Entries
+	}
+	return pending, complete
+}
+
+// Rebalance progress of a volume on each of its nodes
+type VolumeRebalanceInfo struct {
+	// Status aggregated over all the nodes
+	Status string
+	Nodes  []NodeRebalanceInfo
+}
+
+type NodeRebalanceInfo struct {
+	Name     string
+	Status   string
+	Files    uint64
+	Size     uint64
+	Lookups  uint64
+	Failures uint64
+	Skipped  uint64
+
+	// Run time in seconds
+	RunTime float64
+}
+
+// Returns true while the rebalance or fix-layout is still running
+func (r *VolumeRebalanceInfo) InProgress() bool {
+	return strings.HasSuffix(r.Status, "in progress")
+}
+
+// Live status of each brick in a volume
+type VolumeStatusInfo struct {
+	Bricks []BrickStatusInfo
+}
+
+type BrickStatusInfo struct {
+	// Brick in host:path format
+	Name   string
+	Online bool
+	Port   int
+	Pid    int
+
+	// Sizes in bytes
+	SizeTotal uint64
+	SizeFree  uint64
+
+	InodesTotal uint64
+	InodesFree  uint64
+}
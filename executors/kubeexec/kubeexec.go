@@ -19,22 +19,33 @@ package kubeexec
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/openshift/origin/pkg/cmd/util/tokencmd"
 	"k8s.io/kubernetes/pkg/api"
 	"k8s.io/kubernetes/pkg/client/restclient"
 	client "k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/client/unversioned/clientcmd"
 	"k8s.io/kubernetes/pkg/client/unversioned/remotecommand"
+	"k8s.io/kubernetes/pkg/fields"
+	"k8s.io/kubernetes/pkg/labels"
 
 	"github.com/lpabon/godbc"
 
+	"github.com/heketi/heketi/executors"
 	"github.com/heketi/heketi/executors/sshexec"
 	"github.com/heketi/utils"
 )
 
+// Finds the pods running GlusterFS
 type KubernetesClient interface {
+	ListPods(namespace string, options api.ListOptions) ([]api.Pod, error)
 }
 
 type KubernetesRemoteCommand interface {
@@ -43,6 +54,13 @@ type KubernetesRemoteCommand interface {
 type KubernetesRemoteCommandStream interface {
 }
 
+// Error returned by the stream when a command exits with
+// a non-zero status
+type exitStatusError interface {
+	error
+	ExitStatus() int
+}
+
 type KubeConfig struct {
 	Host      string `json:"host"`
 	Sudo      bool   `json:"sudo"`
@@ -52,6 +70,26 @@ type KubeConfig struct {
 	Password  string `json:"password"`
 	Namespace string `json:"namespace"`
 	Fstab     string `json:"fstab"`
+
+	// Bearer token used with the host instead of the user and password
+	Token string `json:"token"`
+
+	// kubeconfig file, and the context in it, used instead of the
+	// host and credentials above.  The current context is used if
+	// no context is given.
+	KubeConfigFile string `json:"kubeconfig"`
+	Context        string `json:"context"`
+
+	// Times commands are tried when the pod cannot be reached, and
+	// seconds to wait before the first retry.  Zero uses the default.
+	RetryAttempts int `json:"retry_attempts"`
+	RetryBackoff  int `json:"retry_backoff"`
+
+	// Label selector, such as "glusterfs-node=pod", of the GlusterFS
+	// pods.  When set, commands for a node run in the pod matching it
+	// which runs on the Kubernetes node named as the manage hostname.
+	// Otherwise the manage hostname is the name of the pod.
+	PodSelector string `json:"pod_selector"`
 }
 
 type KubeExecutor struct {
@@ -60,13 +98,59 @@ type KubeExecutor struct {
 
 	// save kube configuration
 	config *KubeConfig
+
+	// Pods found for each node, looked up again when they
+	// can no longer be reached
+	podSelector labels.Selector
+	podsLock    sync.Mutex
+	pods        map[string]string
 }
 
 var (
-	logger       = utils.NewLogger("[kubeexec]", utils.LEVEL_DEBUG)
-	tokenCreator = tokencmd.RequestToken
+	logger              = utils.NewLogger("[kubeexec]", utils.LEVEL_DEBUG)
+	tokenCreator        = tokencmd.RequestToken
+	newKubernetesClient = func(config *restclient.Config) (KubernetesClient, error) {
+		conn, err := client.New(config)
+		if err != nil {
+			return nil, err
+		}
+		return &kubeClient{conn}, nil
+	}
+	connectAndExec = (*KubeExecutor).ConnectAndExec
+
+	newStreamExecutor = remotecommand.NewExecutor
+	exitStatusRegexp  = regexp.MustCompile(`(?:exited with|Docker Container:) (\d+)`)
+
+	// Service account of the pod heketi runs in
+	inClusterConfig             = restclient.InClusterConfig
+	serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+	kubeConfigLoader = func(file, context string) (*restclient.Config, error) {
+		rules := &clientcmd.ClientConfigLoadingRules{ExplicitPath: file}
+		overrides := &clientcmd.ConfigOverrides{CurrentContext: context}
+		return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules,
+			overrides).ClientConfig()
+	}
 )
 
+// Kubernetes sets these in every container
+func inCluster() bool {
+	return os.Getenv("KUBERNETES_SERVICE_HOST") != "" &&
+		os.Getenv("KUBERNETES_SERVICE_PORT") != ""
+}
+
+type kubeClient struct {
+	*client.Client
+}
+
+func (c *kubeClient) ListPods(namespace string, options api.ListOptions) ([]api.Pod, error) {
+	pods, err := c.Pods(namespace).List(options)
+	if err != nil {
+		return nil, err
+	}
+	return pods.Items, nil
+}
+
 func setWithEnvVariables(config *KubeConfig) {
 	// Check Host e.g. "https://myhost:8443"
 	env := os.Getenv("HEKETI_KUBE_APIHOST")
@@ -104,6 +188,22 @@ func setWithEnvVariables(config *KubeConfig) {
 		config.Password = env
 	}
 
+	// Bearer token
+	env = os.Getenv("HEKETI_KUBE_TOKEN")
+	if "" != env {
+		config.Token = env
+	}
+
+	// kubeconfig file and context
+	env = os.Getenv("HEKETI_KUBE_CONFIG")
+	if "" != env {
+		config.KubeConfigFile = env
+	}
+	env = os.Getenv("HEKETI_KUBE_CONTEXT")
+	if "" != env {
+		config.Context = env
+	}
+
 	// Namespace / Project
 	env = os.Getenv("HEKETI_KUBE_NAMESPACE")
 	if "" != env {
@@ -115,6 +215,20 @@ func setWithEnvVariables(config *KubeConfig) {
 	if "" != env {
 		config.Fstab = env
 	}
+
+	// Label selector of the GlusterFS pods
+	env = os.Getenv("HEKETI_KUBE_POD_SELECTOR")
+	if "" != env {
+		config.PodSelector = env
+	}
+}
+
+// Returns true if the executor would connect with the service account
+// of the pod it runs in, which also provides the default namespace.
+// Settings from the environment are taken into account.
+func (c KubeConfig) UsesServiceAccount() bool {
+	setWithEnvVariables(&c)
+	return c.KubeConfigFile == "" && c.Host == "" && inCluster()
 }
 
 func NewKubeExecutor(config *KubeConfig) (*KubeExecutor, error) {
@@ -126,6 +240,7 @@ func NewKubeExecutor(config *KubeConfig) (*KubeExecutor, error) {
 	k.config = config
 	k.Throttlemap = make(map[string]chan bool)
 	k.RemoteExecutor = k
+	k.RetryPolicy = sshexec.NewRetryPolicy(config.RetryAttempts, config.RetryBackoff)
 
 	if k.config.Fstab == "" {
 		k.Fstab = "/etc/fstab"
@@ -134,10 +249,39 @@ func NewKubeExecutor(config *KubeConfig) (*KubeExecutor, error) {
 	}
 
 	// Check required values
+	switch {
+	case k.config.KubeConfigFile != "":
+		logger.Info("Connecting to Kubernetes with %v", k.config.KubeConfigFile)
+	case k.config.Host != "":
+		logger.Info("Connecting to Kubernetes at %v", k.config.Host)
+	case inCluster():
+		logger.Info("Connecting to Kubernetes with the service account of the pod")
+
+		// Default to the namespace heketi runs in
+		if k.config.Namespace == "" {
+			namespace, err := ioutil.ReadFile(serviceAccountNamespaceFile)
+			if err == nil {
+				k.config.Namespace = strings.TrimSpace(string(namespace))
+			}
+		}
+	default:
+		return nil, fmt.Errorf("Kubernetes host or kubeconfig must be provided " +
+			"in configuration when not running in a pod")
+	}
+
 	if k.config.Namespace == "" {
 		return nil, fmt.Errorf("Namespace must be provided in configuration")
 	}
 
+	if k.config.PodSelector != "" {
+		selector, err := labels.Parse(k.config.PodSelector)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid pod selector %v: %v", k.config.PodSelector, err)
+		}
+		k.podSelector = selector
+		k.pods = make(map[string]string)
+	}
+
 	godbc.Ensure(k != nil)
 	godbc.Ensure(k.Fstab != "")
 
@@ -146,54 +290,262 @@ func NewKubeExecutor(config *KubeConfig) (*KubeExecutor, error) {
 
 func (k *KubeExecutor) RemoteCommandExecute(host string,
 	commands []string,
-	timeoutMinutes int) ([]string, error) {
+	timeoutMinutes int) ([]executors.CommandResult, error) {
 
 	// Throttle
 	k.AccessConnection(host)
 	defer k.FreeConnection(host)
 
+	pod, cached, err := k.podName(host)
+	if err != nil {
+		return nil, err
+	}
+
 	// Execute
-	return k.ConnectAndExec(host,
+	results, err := connectAndExec(k, pod,
+		k.config.Namespace,
+		"pods",
+		commands,
+		timeoutMinutes)
+	if !executors.IsHostUnreachable(err) || k.podSelector == nil {
+		return results, err
+	}
+
+	// The pod may have been replaced.  Look it up again, and run
+	// the commands in the new pod if none of them ran.
+	k.forgetPod(host)
+	if !cached || len(results) != 0 {
+		return results, err
+	}
+
+	newPod, _, lookupErr := k.podName(host)
+	if lookupErr != nil || newPod == pod {
+		return results, err
+	}
+	logger.Info("Pod of node %v changed from %v to %v", host, pod, newPod)
+
+	return connectAndExec(k, newPod,
 		k.config.Namespace,
 		"pods",
 		commands,
 		timeoutMinutes)
 }
 
-func (k *KubeExecutor) ConnectAndExec(host, namespace, resource string,
-	commands []string,
-	timeoutMinutes int) ([]string, error) {
+// Returns the name of the pod of the node, and whether it was
+// found earlier instead of being looked up now
+func (k *KubeExecutor) podName(host string) (string, bool, error) {
+	if k.podSelector == nil {
+		return host, false, nil
+	}
 
-	// Used to return command output
-	buffers := make([]string, len(commands))
+	k.podsLock.Lock()
+	pod, ok := k.pods[host]
+	k.podsLock.Unlock()
+	if ok {
+		return pod, true, nil
+	}
+
+	pod, err := k.findPod(host)
+	if err != nil {
+		return "", false, err
+	}
+
+	k.podsLock.Lock()
+	k.pods[host] = pod
+	k.podsLock.Unlock()
+
+	return pod, false, nil
+}
+
+func (k *KubeExecutor) forgetPod(host string) {
+	k.podsLock.Lock()
+	defer k.podsLock.Unlock()
+
+	delete(k.pods, host)
+}
+
+// Looks for the running pod matching the selector on the node
+func (k *KubeExecutor) findPod(host string) (string, error) {
+	clientConfig, err := k.clientConfig(host)
+	if err != nil {
+		return "", err
+	}
+
+	conn, err := newKubernetesClient(clientConfig)
+	if err != nil {
+		logger.Err(err)
+		return "", &executors.HostUnreachableError{
+			Host: host,
+			Err:  fmt.Errorf("Unable to create a client connection: %v", err),
+		}
+	}
+
+	pods, err := conn.ListPods(k.config.Namespace, api.ListOptions{
+		LabelSelector: k.podSelector,
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", host),
+	})
+	if err != nil {
+		logger.Err(err)
+		return "", &executors.HostUnreachableError{
+			Host: host,
+			Err:  fmt.Errorf("Unable to list the pods: %v", err),
+		}
+	}
+
+	running := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		if pod.Status.Phase == api.PodRunning {
+			running = append(running, pod.Name)
+		}
+	}
+
+	switch len(running) {
+	case 0:
+		return "", &executors.HostUnreachableError{
+			Host: host,
+			Err: fmt.Errorf("No running pod matching [%v] on node %v",
+				k.config.PodSelector, host),
+		}
+	case 1:
+		logger.Debug("Found pod %v on node %v", running[0], host)
+		return running[0], nil
+	default:
+		return "", &executors.HostUnreachableError{
+			Host: host,
+			Err: fmt.Errorf("Found %v running pods matching [%v] on node %v: %v",
+				len(running), k.config.PodSelector, host, strings.Join(running, ", ")),
+		}
+	}
+}
+
+// Returns the configuration to connect to the Kubernetes API from
+// the kubeconfig file, the host and credentials, or the service
+// account of the pod heketi runs in, in that order
+func (k *KubeExecutor) clientConfig(host string) (*restclient.Config, error) {
+	if k.config.KubeConfigFile != "" {
+		clientConfig, err := kubeConfigLoader(k.config.KubeConfigFile, k.config.Context)
+		if err != nil {
+			logger.Err(err)
+			return nil, &executors.HostUnreachableError{
+				Host: host,
+				Err: fmt.Errorf("Unable to load kubeconfig %v: %v",
+					k.config.KubeConfigFile, err),
+			}
+		}
+		return clientConfig, nil
+	}
+
+	if k.config.Host == "" && inCluster() {
+		clientConfig, err := inClusterConfig()
+		if err != nil {
+			logger.Err(err)
+			return nil, &executors.HostUnreachableError{
+				Host: host,
+				Err:  fmt.Errorf("Unable to use the service account: %v", err),
+			}
+		}
+		return clientConfig, nil
+	}
 
-	// Create a Kube client configuration
 	clientConfig := &restclient.Config{}
 	clientConfig.Host = k.config.Host
 	clientConfig.CertFile = k.config.CertFile
 	clientConfig.Insecure = k.config.Insecure
 
 	// Login
-	if k.config.User != "" && k.config.Password != "" {
+	if k.config.Token != "" {
+		clientConfig.BearerToken = k.config.Token
+	} else if k.config.User != "" && k.config.Password != "" {
 		token, err := tokenCreator(clientConfig,
 			nil,
 			k.config.User,
 			k.config.Password)
 		if err != nil {
+			// Not retried: the same credentials would be refused again
 			logger.Err(err)
-			return nil, fmt.Errorf("User %v credentials not accepted", k.config.User)
+			return nil, fmt.Errorf("User %v credentials not accepted: %v",
+				k.config.User, err)
 		}
 		clientConfig.BearerToken = token
 	}
 
+	return clientConfig, nil
+}
+
+// Returns the exit status of the command if the error shows that it
+// ran and failed.  Clients of the version in use return the error
+// written by the kubelet as text, like "error executing remote
+// command: Error executing in Docker Container: 1", or "error
+// executing remote command: command '...' exited with 1: ...".
+func commandExitStatus(err error) (int, bool) {
+	if exitErr, ok := err.(exitStatusError); ok {
+		return exitErr.ExitStatus(), true
+	}
+
+	message := err.Error()
+	if !strings.Contains(message, "error executing remote command") {
+		return 0, false
+	}
+	if match := exitStatusRegexp.FindStringSubmatch(message); match != nil {
+		if status, err := strconv.Atoi(match[1]); err == nil {
+			return status, true
+		}
+	}
+	return -1, true
+}
+
+// Returns true if the API server refused to upgrade the connection
+// because of the credentials
+func credentialsRefused(err error) bool {
+	message := err.Error()
+	return strings.Contains(message, "Unauthorized") ||
+		strings.Contains(message, "Forbidden")
+}
+
+// Output of a command, which can be read on timeout while the
+// stream may still be writing to it
+type lockedBuffer struct {
+	lock sync.Mutex
+	b    bytes.Buffer
+}
+
+func (l *lockedBuffer) Write(p []byte) (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.b.Write(p)
+}
+
+func (l *lockedBuffer) String() string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.b.String()
+}
+
+func (k *KubeExecutor) ConnectAndExec(host, namespace, resource string,
+	commands []string,
+	timeoutMinutes int) ([]executors.CommandResult, error) {
+
+	// Used to return command output
+	results := make([]executors.CommandResult, 0, len(commands))
+	timeout := time.Duration(timeoutMinutes) * time.Minute
+
+	// Create a Kube client configuration
+	clientConfig, err := k.clientConfig(host)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get a client
 	conn, err := client.New(clientConfig)
 	if err != nil {
 		logger.Err(err)
-		return nil, fmt.Errorf("Unable to create a client connection")
+		return nil, &executors.HostUnreachableError{
+			Host: host,
+			Err:  fmt.Errorf("Unable to create a client connection: %v", err),
+		}
 	}
 
-	for index, command := range commands {
+	for _, command := range commands {
 
 		// Remove any whitespace
 		command = strings.Trim(command, " ")
@@ -216,27 +568,78 @@ func (k *KubeExecutor) ConnectAndExec(host, namespace, resource string,
 		}, api.ParameterCodec)
 
 		// Create SPDY connection
-		exec, err := remotecommand.NewExecutor(clientConfig, "POST", req.URL())
+		exec, err := newStreamExecutor(clientConfig, "POST", req.URL())
 		if err != nil {
 			logger.Err(err)
-			return nil, fmt.Errorf("Unable to setup a session with %v", host)
+			return results, &executors.HostUnreachableError{
+				Host: host,
+				Err:  fmt.Errorf("Unable to setup a session: %v", err),
+			}
 		}
 
 		// Create a buffer to trap session output
-		var b bytes.Buffer
-		var berr bytes.Buffer
-
-		// Excute command
-		err = exec.Stream(nil, nil, &b, &berr, false)
+		var b lockedBuffer
+		var berr lockedBuffer
+
+		// Excute command.  The command cannot be stopped on timeout,
+		// it is left running in the pod.
+		start := time.Now()
+		done := make(chan error, 1)
+		go func() {
+			done <- exec.Stream(nil, nil, &b, &berr, false)
+		}()
+		select {
+		case err = <-done:
+		case <-time.After(timeout):
+			results = append(results, executors.CommandResult{
+				Command:    command,
+				Stdout:     b.String(),
+				Stderr:     berr.String(),
+				ExitStatus: -1,
+				Duration:   time.Since(start),
+			})
+			logger.LogError("Timeout on command [%v] on %v: Stdout [%v]: Stderr [%v]",
+				command, host, b.String(), berr.String())
+			return results, &executors.CommandTimeoutError{
+				Host:    host,
+				Command: command,
+				Timeout: timeout,
+			}
+		}
+		result := executors.CommandResult{
+			Command:  command,
+			Stdout:   b.String(),
+			Stderr:   berr.String(),
+			Duration: time.Since(start),
+		}
 		if err != nil {
 			logger.LogError("Failed to run command [%v] on %v: Err[%v]: Stdout [%v]: Stderr [%v]",
 				command, host, err, b.String(), berr.String())
-			return nil, fmt.Errorf("Unable to execute command on %v: %v", host, berr.String())
+
+			if credentialsRefused(err) {
+				return results, fmt.Errorf("Kubernetes refused the credentials "+
+					"to run commands on %v: %v", host, err)
+			}
+
+			// Errors without an exit status come from the connection.
+			// Without any output the stream was never established and
+			// the command did not run, so no result is returned.
+			status, ran := commandExitStatus(err)
+			if !ran {
+				if result.Stdout != "" || result.Stderr != "" {
+					result.ExitStatus = -1
+					results = append(results, result)
+				}
+				return results, &executors.HostUnreachableError{Host: host, Err: err}
+			}
+			result.ExitStatus = status
+			results = append(results, result)
+			return results, &executors.CommandFailedError{Host: host, Result: result}
 		}
 		logger.Debug("Host: %v Command: %v\nResult: %v", host, command, b.String())
-		buffers[index] = b.String()
+		results = append(results, result)
 
 	}
 
-	return buffers, nil
+	return results, nil
 }
@@ -17,8 +17,19 @@
 package kubeexec
 
 import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
 	"testing"
 
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/client/restclient"
+	"k8s.io/kubernetes/pkg/client/unversioned/remotecommand"
+
+	"github.com/heketi/heketi/executors"
 	"github.com/heketi/tests"
 )
 
@@ -48,3 +59,509 @@ func TestNewKubeExecutorNoNamespace(t *testing.T) {
 	tests.Assert(t, err != nil)
 	tests.Assert(t, k == nil)
 }
+
+type fakeKubernetesClient struct {
+	pods  []api.Pod
+	lists int
+	t     *testing.T
+}
+
+func (c *fakeKubernetesClient) ListPods(namespace string,
+	options api.ListOptions) ([]api.Pod, error) {
+
+	c.lists++
+	tests.Assert(c.t, namespace == "mynamespace", namespace)
+	tests.Assert(c.t, options.LabelSelector.String() == "glusterfs-node=pod",
+		options.LabelSelector)
+	nodeName := strings.TrimPrefix(options.FieldSelector.String(), "spec.nodeName=")
+
+	pods := make([]api.Pod, 0)
+	for _, pod := range c.pods {
+		if pod.Spec.NodeName == nodeName {
+			pods = append(pods, pod)
+		}
+	}
+	return pods, nil
+}
+
+func newPod(name, node string, phase api.PodPhase) api.Pod {
+	pod := api.Pod{}
+	pod.Name = name
+	pod.Spec.NodeName = node
+	pod.Status.Phase = phase
+	return pod
+}
+
+func TestKubeExecutorPodName(t *testing.T) {
+	fake := &fakeKubernetesClient{t: t}
+	defer tests.Patch(&newKubernetesClient,
+		func(config *restclient.Config) (KubernetesClient, error) {
+			return fake, nil
+		}).Restore()
+
+	// Without a selector the hostname is the name of the pod
+	k, err := NewKubeExecutor(&KubeConfig{
+		Host:      "myhost",
+		Namespace: "mynamespace",
+	})
+	tests.Assert(t, err == nil)
+
+	pod, cached, err := k.podName("node1")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, pod == "node1")
+	tests.Assert(t, !cached)
+	tests.Assert(t, fake.lists == 0)
+
+	// With a selector the running pod on the node is used
+	k, err = NewKubeExecutor(&KubeConfig{
+		Host:        "myhost",
+		Namespace:   "mynamespace",
+		PodSelector: "glusterfs-node=pod",
+	})
+	tests.Assert(t, err == nil)
+
+	fake.pods = []api.Pod{
+		newPod("glusterfs-old", "node1", api.PodPending),
+		newPod("glusterfs-abcde", "node1", api.PodRunning),
+		newPod("glusterfs-fghij", "node2", api.PodRunning),
+		newPod("glusterfs-klmno", "node3", api.PodRunning),
+		newPod("glusterfs-pqrst", "node3", api.PodRunning),
+	}
+
+	pod, cached, err = k.podName("node1")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, pod == "glusterfs-abcde", pod)
+	tests.Assert(t, !cached)
+	tests.Assert(t, fake.lists == 1)
+
+	// It is only looked up once
+	pod, cached, err = k.podName("node1")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, pod == "glusterfs-abcde", pod)
+	tests.Assert(t, cached)
+	tests.Assert(t, fake.lists == 1)
+
+	pod, _, err = k.podName("node2")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, pod == "glusterfs-fghij", pod)
+
+	// More than one pod on the node
+	_, _, err = k.podName("node3")
+	tests.Assert(t, executors.IsHostUnreachable(err), err)
+	tests.Assert(t, strings.Contains(err.Error(), "Found 2 running pods"), err)
+
+	// No pod on the node
+	_, _, err = k.podName("node4")
+	tests.Assert(t, executors.IsHostUnreachable(err), err)
+	tests.Assert(t, strings.Contains(err.Error(), "No running pod"), err)
+
+	// Errors are not cached
+	fake.pods = append(fake.pods, newPod("glusterfs-uvwxy", "node4", api.PodRunning))
+	pod, _, err = k.podName("node4")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, pod == "glusterfs-uvwxy", pod)
+}
+
+func TestKubeExecutorPodReplaced(t *testing.T) {
+	fake := &fakeKubernetesClient{t: t}
+	defer tests.Patch(&newKubernetesClient,
+		func(config *restclient.Config) (KubernetesClient, error) {
+			return fake, nil
+		}).Restore()
+
+	// Pods which can be reached
+	reachable := map[string]bool{"glusterfs-abcde": true}
+	ran := make([]string, 0)
+	ranCommands := 0
+	defer tests.Patch(&connectAndExec,
+		func(k *KubeExecutor, host, namespace, resource string,
+			commands []string,
+			timeoutMinutes int) ([]executors.CommandResult, error) {
+
+			ran = append(ran, host)
+			if !reachable[host] {
+				results := make([]executors.CommandResult, ranCommands)
+				return results, &executors.HostUnreachableError{
+					Host: host,
+					Err:  errors.New("pod not found"),
+				}
+			}
+			return []executors.CommandResult{{Command: commands[0]}}, nil
+		}).Restore()
+
+	k, err := NewKubeExecutor(&KubeConfig{
+		Host:        "myhost",
+		Namespace:   "mynamespace",
+		PodSelector: "glusterfs-node=pod",
+	})
+	tests.Assert(t, err == nil)
+
+	fake.pods = []api.Pod{
+		newPod("glusterfs-abcde", "node1", api.PodRunning),
+	}
+	_, err = k.RemoteCommandExecute("node1", []string{"true"}, 1)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(ran) == 1 && ran[0] == "glusterfs-abcde", ran)
+
+	// The pod is replaced after a restart.  The commands run
+	// in the new pod once it is found.
+	fake.pods = []api.Pod{
+		newPod("glusterfs-fghij", "node1", api.PodRunning),
+	}
+	reachable = map[string]bool{"glusterfs-fghij": true}
+	ran = ran[:0]
+	_, err = k.RemoteCommandExecute("node1", []string{"true"}, 1)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(ran) == 2, ran)
+	tests.Assert(t, ran[0] == "glusterfs-abcde" && ran[1] == "glusterfs-fghij", ran)
+	tests.Assert(t, fake.lists == 2)
+
+	// Commands which had started are not run again, but
+	// the pod is looked up for the next ones
+	fake.pods = []api.Pod{
+		newPod("glusterfs-klmno", "node1", api.PodRunning),
+	}
+	reachable = map[string]bool{"glusterfs-klmno": true}
+	ranCommands = 1
+	ran = ran[:0]
+	_, err = k.RemoteCommandExecute("node1", []string{"true", "true"}, 1)
+	tests.Assert(t, executors.IsHostUnreachable(err), err)
+	tests.Assert(t, len(ran) == 1 && ran[0] == "glusterfs-fghij", ran)
+
+	ran = ran[:0]
+	_, err = k.RemoteCommandExecute("node1", []string{"true"}, 1)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(ran) == 1 && ran[0] == "glusterfs-klmno", ran)
+	tests.Assert(t, fake.lists == 3)
+}
+
+func TestKubeExecutorPodReplacedStream(t *testing.T) {
+	fake := &fakeKubernetesClient{t: t}
+	defer tests.Patch(&newKubernetesClient,
+		func(config *restclient.Config) (KubernetesClient, error) {
+			return fake, nil
+		}).Restore()
+
+	// The stream to the old pod cannot be established
+	streams := 0
+	failures := 0
+	defer tests.Patch(&newStreamExecutor,
+		func(config *restclient.Config, method string,
+			url *url.URL) (remotecommand.StreamExecutor, error) {
+			streams++
+			if failures > 0 {
+				failures--
+				return &fakeStream{
+					err: errors.New("unable to upgrade connection: pod not found"),
+				}, nil
+			}
+			return &fakeStream{}, nil
+		}).Restore()
+
+	k, err := NewKubeExecutor(&KubeConfig{
+		Host:        "myhost",
+		Namespace:   "mynamespace",
+		PodSelector: "glusterfs-node=pod",
+	})
+	tests.Assert(t, err == nil)
+
+	fake.pods = []api.Pod{
+		newPod("glusterfs-abcde", "node1", api.PodRunning),
+	}
+	_, err = k.RemoteCommandExecute("node1", []string{"true"}, 1)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, streams == 1, streams)
+
+	// The command runs in the new pod in the same call
+	fake.pods = []api.Pod{
+		newPod("glusterfs-fghij", "node1", api.PodRunning),
+	}
+	failures = 1
+	streams = 0
+	results, err := k.RemoteCommandExecute("node1", []string{"mkdir /brick"}, 1)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, streams == 2, streams)
+	tests.Assert(t, len(results) == 1, results)
+	tests.Assert(t, fake.lists == 2)
+}
+
+func TestNewKubeExecutorNoHost(t *testing.T) {
+	defer os.Setenv("KUBERNETES_SERVICE_HOST", os.Getenv("KUBERNETES_SERVICE_HOST"))
+	os.Unsetenv("KUBERNETES_SERVICE_HOST")
+
+	// Outside a pod a host or kubeconfig is required
+	k, err := NewKubeExecutor(&KubeConfig{
+		Namespace: "mynamespace",
+	})
+	tests.Assert(t, err != nil)
+	tests.Assert(t, k == nil)
+
+	k, err = NewKubeExecutor(&KubeConfig{
+		KubeConfigFile: "mykubeconfig",
+		Namespace:      "mynamespace",
+	})
+	tests.Assert(t, err == nil)
+	tests.Assert(t, k != nil)
+}
+
+func TestKubeExecutorClientConfigKubeConfig(t *testing.T) {
+	var file, context string
+	defer tests.Patch(&kubeConfigLoader,
+		func(f, c string) (*restclient.Config, error) {
+			file, context = f, c
+			return &restclient.Config{Host: "fromkubeconfig"}, nil
+		}).Restore()
+
+	k, err := NewKubeExecutor(&KubeConfig{
+		Host:           "myhost",
+		KubeConfigFile: "mykubeconfig",
+		Context:        "mycontext",
+		Namespace:      "mynamespace",
+	})
+	tests.Assert(t, err == nil)
+
+	// The kubeconfig file is used over the host
+	config, err := k.clientConfig("node1")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, config.Host == "fromkubeconfig")
+	tests.Assert(t, file == "mykubeconfig")
+	tests.Assert(t, context == "mycontext")
+
+	// Errors loading it are reported as the host being unreachable
+	defer tests.Patch(&kubeConfigLoader,
+		func(f, c string) (*restclient.Config, error) {
+			return nil, errors.New("no such file")
+		}).Restore()
+	config, err = k.clientConfig("node1")
+	tests.Assert(t, config == nil)
+	_, ok := err.(*executors.HostUnreachableError)
+	tests.Assert(t, ok)
+	tests.Assert(t, strings.Contains(err.Error(), "mykubeconfig"))
+}
+
+func TestKubeExecutorClientConfigToken(t *testing.T) {
+	called := false
+	defer tests.Patch(&tokenCreator,
+		func(config *restclient.Config, r io.Reader,
+			user, password string) (string, error) {
+			called = true
+			return "usertoken", nil
+		}).Restore()
+
+	k, err := NewKubeExecutor(&KubeConfig{
+		Host:      "myhost",
+		User:      "myuser",
+		Password:  "mypassword",
+		Token:     "mytoken",
+		Namespace: "mynamespace",
+	})
+	tests.Assert(t, err == nil)
+
+	// A token is used without logging in
+	config, err := k.clientConfig("node1")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, config.Host == "myhost")
+	tests.Assert(t, config.BearerToken == "mytoken")
+	tests.Assert(t, !called)
+
+	// Without it the user logs in
+	k.config.Token = ""
+	config, err = k.clientConfig("node1")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, config.BearerToken == "usertoken")
+	tests.Assert(t, called)
+
+	// Refused credentials are not worth retrying
+	defer tests.Patch(&tokenCreator,
+		func(config *restclient.Config, r io.Reader,
+			user, password string) (string, error) {
+			return "", errors.New("401 Unauthorized")
+		}).Restore()
+	config, err = k.clientConfig("node1")
+	tests.Assert(t, config == nil)
+	tests.Assert(t, err != nil)
+	tests.Assert(t, !executors.IsHostUnreachable(err), err)
+	tests.Assert(t, strings.Contains(err.Error(), "myuser"), err)
+}
+
+type fakeStream struct {
+	stdout string
+	err    error
+	block  chan struct{}
+}
+
+func (f *fakeStream) Stream(supportedProtocols []string,
+	stdin io.Reader,
+	stdout, stderr io.Writer,
+	tty bool) error {
+
+	if f.block != nil {
+		<-f.block
+	}
+	io.WriteString(stdout, f.stdout)
+	return f.err
+}
+
+type exitError struct {
+	status int
+}
+
+func (e *exitError) Error() string {
+	return "command failed"
+}
+
+func (e *exitError) ExitStatus() int {
+	return e.status
+}
+
+func TestKubeExecutorConnectAndExec(t *testing.T) {
+	stream := &fakeStream{}
+	defer tests.Patch(&newStreamExecutor,
+		func(config *restclient.Config, method string,
+			url *url.URL) (remotecommand.StreamExecutor, error) {
+			return stream, nil
+		}).Restore()
+
+	k, err := NewKubeExecutor(&KubeConfig{
+		Host:      "myhost",
+		Token:     "mytoken",
+		Namespace: "mynamespace",
+	})
+	tests.Assert(t, err == nil)
+
+	// Success
+	stream.stdout = "hello"
+	results, err := k.ConnectAndExec("pod1", "mynamespace", "pods",
+		[]string{"echo hello", "echo hello"}, 10)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(results) == 2)
+	tests.Assert(t, results[1].Stdout == "hello")
+	tests.Assert(t, results[1].ExitStatus == 0)
+
+	// Errors implementing ExitStatus()
+	stream.err = &exitError{status: 3}
+	results, err = k.ConnectAndExec("pod1", "mynamespace", "pods",
+		[]string{"false"}, 10)
+	tests.Assert(t, executors.IsCommandFailed(err), err)
+	tests.Assert(t, results[0].ExitStatus == 3)
+
+	// Plain errors written by the kubelet
+	stream.err = errors.New("error executing remote command: " +
+		"Error executing in Docker Container: 2")
+	results, err = k.ConnectAndExec("pod1", "mynamespace", "pods",
+		[]string{"false"}, 10)
+	tests.Assert(t, executors.IsCommandFailed(err), err)
+	tests.Assert(t, results[0].ExitStatus == 2)
+
+	stream.err = errors.New("error executing remote command: " +
+		"command 'false' exited with 1: ")
+	results, err = k.ConnectAndExec("pod1", "mynamespace", "pods",
+		[]string{"false"}, 10)
+	tests.Assert(t, executors.IsCommandFailed(err), err)
+	tests.Assert(t, results[0].ExitStatus == 1)
+
+	// The command failed without a status
+	stream.err = errors.New("error executing remote command: oops")
+	results, err = k.ConnectAndExec("pod1", "mynamespace", "pods",
+		[]string{"false"}, 10)
+	tests.Assert(t, executors.IsCommandFailed(err), err)
+	tests.Assert(t, results[0].ExitStatus == -1)
+
+	// The connection could not be established, so nothing ran
+	stream.stdout = ""
+	stream.err = errors.New("unable to upgrade connection: EOF")
+	results, err = k.ConnectAndExec("pod1", "mynamespace", "pods",
+		[]string{"false"}, 10)
+	tests.Assert(t, executors.IsHostUnreachable(err), err)
+	tests.Assert(t, len(results) == 0, results)
+
+	// The connection was lost after the command started
+	stream.stdout = "hello"
+	stream.err = errors.New("connection reset by peer")
+	results, err = k.ConnectAndExec("pod1", "mynamespace", "pods",
+		[]string{"true", "false"}, 10)
+	tests.Assert(t, executors.IsHostUnreachable(err), err)
+	tests.Assert(t, len(results) == 1, results)
+	tests.Assert(t, results[0].ExitStatus == -1)
+
+	// The credentials were refused
+	stream.err = errors.New("unable to upgrade connection: Unauthorized")
+	_, err = k.ConnectAndExec("pod1", "mynamespace", "pods",
+		[]string{"false"}, 10)
+	tests.Assert(t, err != nil)
+	tests.Assert(t, !executors.IsHostUnreachable(err), err)
+	tests.Assert(t, !executors.IsCommandFailed(err), err)
+
+	// The command does not finish in time
+	stream.err = nil
+	stream.block = make(chan struct{})
+	defer close(stream.block)
+	results, err = k.ConnectAndExec("pod1", "mynamespace", "pods",
+		[]string{"sleep 100"}, 0)
+	tests.Assert(t, executors.IsTimeout(err), err)
+	tests.Assert(t, len(results) == 1)
+	tests.Assert(t, results[0].ExitStatus == -1)
+}
+
+func TestKubeConfigUsesServiceAccount(t *testing.T) {
+	defer os.Setenv("KUBERNETES_SERVICE_HOST", os.Getenv("KUBERNETES_SERVICE_HOST"))
+	defer os.Setenv("KUBERNETES_SERVICE_PORT", os.Getenv("KUBERNETES_SERVICE_PORT"))
+	defer os.Setenv("HEKETI_KUBE_APIHOST", os.Getenv("HEKETI_KUBE_APIHOST"))
+	os.Setenv("KUBERNETES_SERVICE_HOST", "")
+	os.Setenv("KUBERNETES_SERVICE_PORT", "")
+	os.Setenv("HEKETI_KUBE_APIHOST", "")
+
+	config := &KubeConfig{}
+	tests.Assert(t, !config.UsesServiceAccount())
+
+	os.Setenv("KUBERNETES_SERVICE_HOST", "10.0.0.1")
+	tests.Assert(t, !config.UsesServiceAccount())
+
+	os.Setenv("KUBERNETES_SERVICE_PORT", "443")
+	tests.Assert(t, config.UsesServiceAccount())
+
+	config.KubeConfigFile = "mykubeconfig"
+	tests.Assert(t, !config.UsesServiceAccount())
+
+	config.KubeConfigFile = ""
+	os.Setenv("HEKETI_KUBE_APIHOST", "https://myhost:8443")
+	tests.Assert(t, !config.UsesServiceAccount())
+
+	// The configuration is not changed
+	tests.Assert(t, config.Host == "")
+}
+
+func TestKubeExecutorClientConfigInCluster(t *testing.T) {
+	defer os.Setenv("KUBERNETES_SERVICE_HOST", os.Getenv("KUBERNETES_SERVICE_HOST"))
+	defer os.Setenv("KUBERNETES_SERVICE_PORT", os.Getenv("KUBERNETES_SERVICE_PORT"))
+	os.Setenv("KUBERNETES_SERVICE_HOST", "10.0.0.1")
+	os.Setenv("KUBERNETES_SERVICE_PORT", "443")
+
+	namespace, err := ioutil.TempFile("", "namespace")
+	tests.Assert(t, err == nil)
+	defer os.Remove(namespace.Name())
+	_, err = namespace.WriteString("podnamespace\n")
+	tests.Assert(t, err == nil)
+	namespace.Close()
+
+	defer tests.Patch(&serviceAccountNamespaceFile, namespace.Name()).Restore()
+	defer tests.Patch(&inClusterConfig, func() (*restclient.Config, error) {
+		return &restclient.Config{Host: "https://10.0.0.1:443"}, nil
+	}).Restore()
+
+	// The namespace defaults to the one of the pod
+	k, err := NewKubeExecutor(&KubeConfig{})
+	tests.Assert(t, err == nil)
+	tests.Assert(t, k.config.Namespace == "podnamespace")
+
+	config, err := k.clientConfig("node1")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, config.Host == "https://10.0.0.1:443")
+
+	// A configured namespace is kept
+	k, err = NewKubeExecutor(&KubeConfig{
+		Namespace: "mynamespace",
+	})
+	tests.Assert(t, err == nil)
+	tests.Assert(t, k.config.Namespace == "mynamespace")
+}
@@ -0,0 +1,254 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package localexec
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/lpabon/godbc"
+
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/executors/sshexec"
+	"github.com/heketi/utils"
+)
+
+const (
+	// Name used for this machine in command errors
+	localHost = "localhost"
+)
+
+type Runner interface {
+	Run(commands []string, timeoutMinutes int) ([]executors.CommandResult, error)
+}
+
+type LocalConfig struct {
+	Fstab string `json:"fstab"`
+
+	// Name of this machine used as the management hostname of the
+	// node, when it is not its hostname or one of its addresses
+	Host string `json:"host"`
+}
+
+// Runs the commands on the machine heketi is running on.  Used
+// when heketi runs on the only storage node.  Commands sent to
+// any other host are refused.
+type LocalExecutor struct {
+	// Embed all sshexecutor functions
+	sshexec.SshExecutor
+
+	// save local configuration
+	config *LocalConfig
+	runner Runner
+
+	// Hosts found to be this machine
+	localLock  sync.Mutex
+	localHosts map[string]bool
+}
+
+var (
+	logger    = utils.NewLogger("[localexec]", utils.LEVEL_DEBUG)
+	runnerNew = func() Runner {
+		return &commandRunner{}
+	}
+
+	// Used to find out if a host is this machine
+	hostname       = os.Hostname
+	interfaceAddrs = net.InterfaceAddrs
+	lookupHost     = net.LookupHost
+)
+
+func NewLocalExecutor(config *LocalConfig) (*LocalExecutor, error) {
+	godbc.Require(config != nil)
+
+	l := &LocalExecutor{}
+	l.config = config
+	l.runner = runnerNew()
+	l.Throttlemap = make(map[string]chan bool)
+	l.RemoteExecutor = l
+	l.localHosts = make(map[string]bool)
+
+	if config.Fstab == "" {
+		l.Fstab = "/etc/fstab"
+	} else {
+		l.Fstab = config.Fstab
+	}
+
+	godbc.Ensure(l.Fstab != "")
+	godbc.Ensure(l.runner != nil)
+
+	return l, nil
+}
+
+func (l *LocalExecutor) RemoteCommandExecute(host string,
+	commands []string,
+	timeoutMinutes int) ([]executors.CommandResult, error) {
+
+	// Commands for another node would run against the disks
+	// of this machine
+	if !l.isLocalHost(host) {
+		return nil, fmt.Errorf("Unable to run commands on %v: the local "+
+			"executor only runs commands on this machine", host)
+	}
+
+	// Throttle
+	l.AccessConnection(host)
+	defer l.FreeConnection(host)
+
+	// Execute
+	return l.runner.Run(commands, timeoutMinutes)
+}
+
+// Returns true if the host is this machine: the configured host,
+// its hostname, or a name or address of one of its interfaces
+func (l *LocalExecutor) isLocalHost(host string) bool {
+	l.localLock.Lock()
+	defer l.localLock.Unlock()
+
+	if l.localHosts[host] {
+		return true
+	}
+
+	local := l.findLocalHost(host)
+	if local {
+		l.localHosts[host] = true
+	}
+	return local
+}
+
+func (l *LocalExecutor) findLocalHost(host string) bool {
+	if host == localHost || strings.EqualFold(host, l.config.Host) {
+		return true
+	}
+	if name, err := hostname(); err == nil && strings.EqualFold(host, name) {
+		return true
+	}
+
+	addrs, err := interfaceAddrs()
+	if err != nil {
+		logger.Err(err)
+		return false
+	}
+	local := make(map[string]bool)
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok {
+			local[ipnet.IP.String()] = true
+		}
+	}
+
+	ips := []string{host}
+	if net.ParseIP(host) == nil {
+		ips, err = lookupHost(host)
+		if err != nil {
+			logger.Warning("Unable to resolve %v: %v", host, err)
+			return false
+		}
+	}
+	for _, ip := range ips {
+		if parsed := net.ParseIP(ip); parsed != nil && local[parsed.String()] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Runs each command with bash and returns its output
+type commandRunner struct{}
+
+func (r *commandRunner) Run(commands []string,
+	timeoutMinutes int) ([]executors.CommandResult, error) {
+
+	timeout := time.Duration(timeoutMinutes) * time.Minute
+	results := make([]executors.CommandResult, 0, len(commands))
+
+	for _, command := range commands {
+
+		// Remove any whitespace
+		command = strings.Trim(command, " ")
+		result := executors.CommandResult{
+			Command:    command,
+			ExitStatus: -1,
+		}
+
+		// Create a buffer to trap command output
+		var b bytes.Buffer
+		var berr bytes.Buffer
+
+		cmd := exec.Command("/bin/bash", "-c", command)
+		cmd.Stdout = &b
+		cmd.Stderr = &berr
+
+		start := time.Now()
+		err := cmd.Start()
+		if err != nil {
+			logger.Err(err)
+			result.Stderr = err.Error()
+			results = append(results, result)
+			return results, &executors.CommandFailedError{Host: localHost, Result: result}
+		}
+
+		// Wait for the command to finish or time out
+		done := make(chan error, 1)
+		go func() {
+			done <- cmd.Wait()
+		}()
+		select {
+		case err = <-done:
+		case <-time.After(timeout):
+			cmd.Process.Kill()
+			<-done
+			result.Duration = time.Since(start)
+			result.Stdout = b.String()
+			result.Stderr = berr.String()
+			results = append(results, result)
+			logger.LogError("Command [%v] timed out", command)
+			return results, &executors.CommandTimeoutError{
+				Host:    localHost,
+				Command: command,
+				Timeout: timeout,
+			}
+		}
+		result.Duration = time.Since(start)
+		result.Stdout = b.String()
+		result.Stderr = berr.String()
+
+		if err != nil {
+			logger.LogError("Failed to run command [%v]: Err[%v]: Stdout [%v]: Stderr [%v]",
+				command, err, result.Stdout, result.Stderr)
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+					result.ExitStatus = status.ExitStatus()
+				}
+			}
+			results = append(results, result)
+			return results, &executors.CommandFailedError{Host: localHost, Result: result}
+		}
+		logger.Debug("Command: %v\nResult: %v", command, result.Stdout)
+		result.ExitStatus = 0
+		results = append(results, result)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,220 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package localexec
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/tests"
+)
+
+// Mock command runner
+type FakeRunner struct {
+	FakeRun func(commands []string, timeoutMinutes int) ([]executors.CommandResult, error)
+}
+
+func NewFakeRunner() *FakeRunner {
+	f := &FakeRunner{}
+
+	f.FakeRun = func(commands []string, timeoutMinutes int) ([]executors.CommandResult, error) {
+		return make([]executors.CommandResult, len(commands)), nil
+	}
+
+	return f
+}
+
+func (f *FakeRunner) Run(commands []string, timeoutMinutes int) ([]executors.CommandResult, error) {
+	return f.FakeRun(commands, timeoutMinutes)
+}
+
+func TestNewLocalExecutor(t *testing.T) {
+	config := &LocalConfig{
+		Fstab: "myfstab",
+	}
+
+	l, err := NewLocalExecutor(config)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, l.Fstab == "myfstab")
+	tests.Assert(t, l.Throttlemap != nil)
+	tests.Assert(t, l.config == config)
+	tests.Assert(t, l.runner != nil)
+
+	// Defaults
+	l, err = NewLocalExecutor(&LocalConfig{})
+	tests.Assert(t, err == nil)
+	tests.Assert(t, l.Fstab == "/etc/fstab")
+}
+
+func TestLocalExecutorCommands(t *testing.T) {
+	f := NewFakeRunner()
+	defer tests.Patch(&runnerNew, func() Runner {
+		return f
+	}).Restore()
+
+	l, err := NewLocalExecutor(&LocalConfig{
+		Host: "host1",
+	})
+	tests.Assert(t, err == nil)
+
+	// Commands sent to this machine are run locally
+	var ran []string
+	f.FakeRun = func(commands []string, timeoutMinutes int) ([]executors.CommandResult, error) {
+		ran = append(ran, commands...)
+		return make([]executors.CommandResult, len(commands)), nil
+	}
+
+	err = l.Ping("host1")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(ran) == 1)
+	tests.Assert(t, ran[0] == "sudo true", ran)
+
+	ran = nil
+	err = l.PeerProbe("host1", "host2")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(ran) > 0)
+	tests.Assert(t, strings.Contains(ran[0], "peer probe host2"), ran)
+
+	// Expanding does not need a ssh configuration
+	ran = nil
+	_, err = l.VolumeExpand("host1", &executors.VolumeRequest{
+		Name:    "vol1",
+		Type:    executors.DurabilityReplica,
+		Replica: 2,
+		Bricks: []executors.BrickInfo{
+			executors.BrickInfo{Host: "host1", Path: "/b1"},
+			executors.BrickInfo{Host: "host1", Path: "/b2"},
+		},
+	})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(ran) == 1, ran)
+
+	// Failures are returned
+	f.FakeRun = func(commands []string, timeoutMinutes int) ([]executors.CommandResult, error) {
+		return nil, errors.New("command failed")
+	}
+	err = l.Ping("host1")
+	tests.Assert(t, err != nil)
+}
+
+func TestLocalExecutorHosts(t *testing.T) {
+	f := NewFakeRunner()
+	defer tests.Patch(&runnerNew, func() Runner {
+		return f
+	}).Restore()
+	defer tests.Patch(&hostname, func() (string, error) {
+		return "storage1.example.com", nil
+	}).Restore()
+	defer tests.Patch(&interfaceAddrs, func() ([]net.Addr, error) {
+		return []net.Addr{
+			&net.IPNet{IP: net.ParseIP("127.0.0.1"), Mask: net.CIDRMask(8, 32)},
+			&net.IPNet{IP: net.ParseIP("192.168.10.5"), Mask: net.CIDRMask(24, 32)},
+		}, nil
+	}).Restore()
+	lookups := 0
+	defer tests.Patch(&lookupHost, func(host string) ([]string, error) {
+		lookups++
+		switch host {
+		case "storage1":
+			return []string{"192.168.10.5"}, nil
+		case "storage2":
+			return []string{"192.168.10.6"}, nil
+		}
+		return nil, errors.New("no such host")
+	}).Restore()
+
+	l, err := NewLocalExecutor(&LocalConfig{
+		Host: "gluster-local",
+	})
+	tests.Assert(t, err == nil)
+
+	ran := 0
+	f.FakeRun = func(commands []string, timeoutMinutes int) ([]executors.CommandResult, error) {
+		ran++
+		return make([]executors.CommandResult, len(commands)), nil
+	}
+
+	// Names and addresses of this machine
+	for _, host := range []string{
+		"gluster-local",
+		"localhost",
+		"STORAGE1.example.com",
+		"192.168.10.5",
+		"storage1",
+	} {
+		err = l.Ping(host)
+		tests.Assert(t, err == nil, host, err)
+	}
+	tests.Assert(t, ran == 5, ran)
+
+	// Found once
+	lookups = 0
+	err = l.Ping("storage1")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, lookups == 0)
+
+	// Other nodes are refused without running anything
+	ran = 0
+	for _, host := range []string{
+		"storage2",
+		"192.168.10.6",
+		"unknown",
+	} {
+		err = l.Ping(host)
+		tests.Assert(t, err != nil, host)
+		tests.Assert(t, strings.Contains(err.Error(), host), err)
+	}
+	_, err = l.DeviceSetup("storage2", "/dev/sdb", "vgid")
+	tests.Assert(t, err != nil)
+	err = l.BrickDestroy("storage2", &executors.BrickRequest{
+		VgId: "vgid",
+		Name: "brick",
+	})
+	tests.Assert(t, ran == 0, ran)
+}
+
+func TestCommandRunner(t *testing.T) {
+	r := &commandRunner{}
+
+	out, err := r.Run([]string{"echo hello", "  echo world  "}, 1)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(out) == 2)
+	tests.Assert(t, out[0].Command == "echo hello", out[0].Command)
+	tests.Assert(t, out[0].Stdout == "hello\n", out[0].Stdout)
+	tests.Assert(t, out[0].Ok())
+	tests.Assert(t, out[1].Command == "echo world", out[1].Command)
+	tests.Assert(t, out[1].Stdout == "world\n", out[1].Stdout)
+
+	// Stops at the first command which fails
+	out, err = r.Run([]string{"echo ok", "echo out; echo bad >&2; exit 3", "echo never"}, 1)
+	tests.Assert(t, err != nil)
+	tests.Assert(t, executors.IsCommandFailed(err), err)
+	tests.Assert(t, strings.Contains(err.Error(), "bad"), err)
+	tests.Assert(t, len(out) == 2)
+	tests.Assert(t, out[0].Ok())
+	tests.Assert(t, !out[1].Ok())
+	tests.Assert(t, out[1].ExitStatus == 3, out[1].ExitStatus)
+	tests.Assert(t, out[1].Stdout == "out\n", out[1].Stdout)
+	tests.Assert(t, out[1].Stderr == "bad\n", out[1].Stderr)
+
+	failed := err.(*executors.CommandFailedError)
+	tests.Assert(t, failed.Result.ExitStatus == 3)
+	tests.Assert(t, failed.Host == localHost)
+}
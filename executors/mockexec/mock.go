@@ -22,17 +22,28 @@ import (
 
 type MockExecutor struct {
 	// These functions can be overwritten for testing
-	MockPeerProbe          func(exec_host, newnode string) error
-	MockPeerDetach         func(exec_host, newnode string) error
-	MockDeviceSetup        func(host, device, vgid string) (*executors.DeviceInfo, error)
-	MockDeviceTeardown     func(host, device, vgid string) error
-	MockBrickCreate        func(host string, brick *executors.BrickRequest) (*executors.BrickInfo, error)
-	MockBrickDestroy       func(host string, brick *executors.BrickRequest) error
-	MockBrickDestroyCheck  func(host string, brick *executors.BrickRequest) error
-	MockVolumeCreate       func(host string, volume *executors.VolumeRequest) (*executors.VolumeInfo, error)
-	MockVolumeExpand       func(host string, volume *executors.VolumeRequest) (*executors.VolumeInfo, error)
-	MockVolumeDestroy      func(host string, volume string) error
-	MockVolumeDestroyCheck func(host, volume string) error
+	MockPeerProbe             func(exec_host, newnode string) error
+	MockPeerDetach            func(exec_host, newnode string) error
+	MockDeviceSetup           func(host, device, vgid string) (*executors.DeviceInfo, error)
+	MockDeviceTeardown        func(host, device, vgid string) error
+	MockDeviceResync          func(host, device, vgid string) (*executors.DeviceInfo, error)
+	MockDeviceHealth          func(host, device string) (*executors.DeviceHealthInfo, error)
+	MockDeviceUsage           func(host, vgid string) (*executors.DeviceUsageInfo, error)
+	MockBrickCreate           func(host string, brick *executors.BrickRequest) (*executors.BrickInfo, error)
+	MockBrickDestroy          func(host string, brick *executors.BrickRequest) error
+	MockBrickDestroyCheck     func(host string, brick *executors.BrickRequest) error
+	MockVolumeCreate          func(host string, volume *executors.VolumeRequest) (*executors.VolumeInfo, error)
+	MockVolumeExpand          func(host string, volume *executors.VolumeRequest) (*executors.VolumeInfo, error)
+	MockVolumeHealInfo        func(host string, volume string) (*executors.VolumeHealInfo, error)
+	MockVolumeHeal            func(host string, volume string) error
+	MockVolumeRebalanceStart  func(host string, volume string) error
+	MockVolumeRebalanceStop   func(host string, volume string) error
+	MockVolumeRebalanceStatus func(host string, volume string) (*executors.VolumeRebalanceInfo, error)
+	MockVolumeStatus          func(host string, volume string) (*executors.VolumeStatusInfo, error)
+	MockVolumeDestroy         func(host string, volume string) error
+	MockVolumeDestroyCheck    func(host, volume string) error
+	MockPing                  func(host string) error
+	MockSetHostConnection     func(host string, conn executors.HostConnection)
 }
 
 func NewMockExecutor() (*MockExecutor, error) {
@@ -57,6 +68,26 @@ func NewMockExecutor() (*MockExecutor, error) {
 		return nil
 	}
 
+	m.MockDeviceResync = func(host, device, vgid string) (*executors.DeviceInfo, error) {
+		d := &executors.DeviceInfo{}
+		d.Size = 500 * 1024 * 1024 // Size in KB
+		d.ExtentSize = 4096
+		return d, nil
+	}
+
+	m.MockDeviceHealth = func(host, device string) (*executors.DeviceHealthInfo, error) {
+		return &executors.DeviceHealthInfo{
+			Passed:      true,
+			Temperature: 30,
+		}, nil
+	}
+
+	m.MockDeviceUsage = func(host, vgid string) (*executors.DeviceUsageInfo, error) {
+		return &executors.DeviceUsageInfo{
+			Bricks: map[string]*executors.BrickUsageInfo{},
+		}, nil
+	}
+
 	m.MockBrickCreate = func(host string, brick *executors.BrickRequest) (*executors.BrickInfo, error) {
 		b := &executors.BrickInfo{
 			Path: "/mockpath",
@@ -80,6 +111,37 @@ func NewMockExecutor() (*MockExecutor, error) {
 		return &executors.VolumeInfo{}, nil
 	}
 
+	m.MockVolumeHealInfo = func(host string, volume string) (*executors.VolumeHealInfo, error) {
+		return &executors.VolumeHealInfo{
+			Bricks: []executors.BrickHealInfo{},
+		}, nil
+	}
+
+	m.MockVolumeHeal = func(host string, volume string) error {
+		return nil
+	}
+
+	m.MockVolumeRebalanceStart = func(host string, volume string) error {
+		return nil
+	}
+
+	m.MockVolumeRebalanceStop = func(host string, volume string) error {
+		return nil
+	}
+
+	m.MockVolumeRebalanceStatus = func(host string, volume string) (*executors.VolumeRebalanceInfo, error) {
+		return &executors.VolumeRebalanceInfo{
+			Status: "completed",
+			Nodes:  []executors.NodeRebalanceInfo{},
+		}, nil
+	}
+
+	m.MockVolumeStatus = func(host string, volume string) (*executors.VolumeStatusInfo, error) {
+		return &executors.VolumeStatusInfo{
+			Bricks: []executors.BrickStatusInfo{},
+		}, nil
+	}
+
 	m.MockVolumeDestroy = func(host string, volume string) error {
 		return nil
 	}
@@ -88,6 +150,13 @@ func NewMockExecutor() (*MockExecutor, error) {
 		return nil
 	}
 
+	m.MockPing = func(host string) error {
+		return nil
+	}
+
+	m.MockSetHostConnection = func(host string, conn executors.HostConnection) {
+	}
+
 	return m, nil
 }
 
@@ -111,6 +180,18 @@ func (m *MockExecutor) DeviceTeardown(host, device, vgid string) error {
 	return m.MockDeviceTeardown(host, device, vgid)
 }
 
+func (m *MockExecutor) DeviceResync(host, device, vgid string) (*executors.DeviceInfo, error) {
+	return m.MockDeviceResync(host, device, vgid)
+}
+
+func (m *MockExecutor) DeviceHealth(host, device string) (*executors.DeviceHealthInfo, error) {
+	return m.MockDeviceHealth(host, device)
+}
+
+func (m *MockExecutor) DeviceUsage(host, vgid string) (*executors.DeviceUsageInfo, error) {
+	return m.MockDeviceUsage(host, vgid)
+}
+
 func (m *MockExecutor) BrickCreate(host string, brick *executors.BrickRequest) (*executors.BrickInfo, error) {
 	return m.MockBrickCreate(host, brick)
 }
@@ -131,6 +212,30 @@ func (m *MockExecutor) VolumeExpand(host string, volume *executors.VolumeRequest
 	return m.MockVolumeExpand(host, volume)
 }
 
+func (m *MockExecutor) VolumeHealInfo(host string, volume string) (*executors.VolumeHealInfo, error) {
+	return m.MockVolumeHealInfo(host, volume)
+}
+
+func (m *MockExecutor) VolumeHeal(host string, volume string) error {
+	return m.MockVolumeHeal(host, volume)
+}
+
+func (m *MockExecutor) VolumeRebalanceStart(host string, volume string) error {
+	return m.MockVolumeRebalanceStart(host, volume)
+}
+
+func (m *MockExecutor) VolumeRebalanceStop(host string, volume string) error {
+	return m.MockVolumeRebalanceStop(host, volume)
+}
+
+func (m *MockExecutor) VolumeRebalanceStatus(host string, volume string) (*executors.VolumeRebalanceInfo, error) {
+	return m.MockVolumeRebalanceStatus(host, volume)
+}
+
+func (m *MockExecutor) VolumeStatus(host string, volume string) (*executors.VolumeStatusInfo, error) {
+	return m.MockVolumeStatus(host, volume)
+}
+
 func (m *MockExecutor) VolumeDestroy(host string, volume string) error {
 	return m.MockVolumeDestroy(host, volume)
 }
@@ -138,3 +243,11 @@ func (m *MockExecutor) VolumeDestroy(host string, volume string) error {
 func (m *MockExecutor) VolumeDestroyCheck(host string, volume string) error {
 	return m.MockVolumeDestroyCheck(host, volume)
 }
+
+func (m *MockExecutor) Ping(host string) error {
+	return m.MockPing(host)
+}
+
+func (m *MockExecutor) SetHostConnection(host string, conn executors.HostConnection) {
+	m.MockSetHostConnection(host, conn)
+}
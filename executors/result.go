@@ -0,0 +1,91 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package executors
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Result of a command run on a node
+type CommandResult struct {
+	Command string
+	Stdout  string
+	Stderr  string
+
+	// Exit status of the command, or -1 if it did not finish
+	ExitStatus int
+	Duration   time.Duration
+}
+
+func (r *CommandResult) Ok() bool {
+	return r.ExitStatus == 0
+}
+
+// Returned when the node could not be reached, or the connection
+// was lost before the command finished
+type HostUnreachableError struct {
+	Host string
+	Err  error
+}
+
+func (e *HostUnreachableError) Error() string {
+	return fmt.Sprintf("Unable to reach %v: %v", e.Host, e.Err)
+}
+
+// Returned when a command ran on the node and failed
+type CommandFailedError struct {
+	Host   string
+	Result CommandResult
+}
+
+func (e *CommandFailedError) Error() string {
+	output := strings.TrimSpace(e.Result.Stderr)
+	if output == "" {
+		output = strings.TrimSpace(e.Result.Stdout)
+	}
+	return fmt.Sprintf("Unable to execute command on %v: [%v] exited with status %v: %v",
+		e.Host, e.Result.Command, e.Result.ExitStatus, output)
+}
+
+// Returned when a command did not finish in time on the node
+type CommandTimeoutError struct {
+	Host    string
+	Command string
+	Timeout time.Duration
+}
+
+func (e *CommandTimeoutError) Error() string {
+	return fmt.Sprintf("Timeout after %v executing command [%v] on %v",
+		e.Timeout, e.Command, e.Host)
+}
+
+func IsHostUnreachable(err error) bool {
+	_, ok := err.(*HostUnreachableError)
+	return ok
+}
+
+func IsCommandFailed(err error) bool {
+	_, ok := err.(*CommandFailedError)
+	return ok
+}
+
+func IsTimeout(err error) bool {
+	_, ok := err.(*CommandTimeoutError)
+	return ok
+}
@@ -0,0 +1,108 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package executors
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/heketi/utils"
+)
+
+// Tries commands again when they fail for a reason which may go away,
+// like a dropped connection
+type RetryPolicy struct {
+	// Times the commands are tried.  Zero or one disables retries.
+	Attempts int
+
+	// Wait before the first retry, doubled for each retry after it
+	Backoff time.Duration
+}
+
+// Counts of retries made by all the executors
+type RetryMetrics struct {
+	// Commands tried again
+	Retries uint64
+
+	// Commands which succeeded after being tried again
+	Recovered uint64
+
+	// Commands which still failed after being tried again
+	Exhausted uint64
+}
+
+var (
+	logger       = utils.NewLogger("[executors]", utils.LEVEL_DEBUG)
+	retrySleep   = time.Sleep
+	retryMetrics RetryMetrics
+)
+
+// Runs the commands until they succeed, fail for a reason which will
+// not go away, or all the attempts are used.  Commands which only read
+// the state of the node are idempotent, and are tried again if the node
+// could not be reached or they timed out.  Other commands are only tried
+// again if the node could not be reached before any of them ran.
+func (p RetryPolicy) Run(host string,
+	idempotent bool,
+	run func() ([]CommandResult, error)) ([]CommandResult, error) {
+
+	backoff := p.Backoff
+	for attempt := 1; ; attempt++ {
+		results, err := run()
+		if err == nil {
+			if attempt > 1 {
+				atomic.AddUint64(&retryMetrics.Recovered, 1)
+			}
+			return results, nil
+		}
+
+		if attempt >= p.Attempts || !retryable(results, err, idempotent) {
+			if attempt > 1 {
+				atomic.AddUint64(&retryMetrics.Exhausted, 1)
+				logger.LogError("Commands on %v failed after %v attempts: %v",
+					host, attempt, err)
+			}
+			return results, err
+		}
+
+		logger.Warning("Retrying commands on %v in %v, attempt %v of %v: %v",
+			host, backoff, attempt+1, p.Attempts, err)
+		atomic.AddUint64(&retryMetrics.Retries, 1)
+		retrySleep(backoff)
+		backoff *= 2
+	}
+}
+
+func retryable(results []CommandResult, err error, idempotent bool) bool {
+	switch err.(type) {
+	case *HostUnreachableError:
+		return idempotent || len(results) == 0
+	case *CommandTimeoutError:
+		return idempotent
+	default:
+		return false
+	}
+}
+
+// Returns the retries made so far
+func GetRetryMetrics() RetryMetrics {
+	return RetryMetrics{
+		Retries:   atomic.LoadUint64(&retryMetrics.Retries),
+		Recovered: atomic.LoadUint64(&retryMetrics.Recovered),
+		Exhausted: atomic.LoadUint64(&retryMetrics.Exhausted),
+	}
+}
@@ -0,0 +1,110 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package executors
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/heketi/tests"
+)
+
+func TestRetryPolicyRun(t *testing.T) {
+	var waits []time.Duration
+	defer tests.Patch(&retrySleep, func(d time.Duration) {
+		waits = append(waits, d)
+	}).Restore()
+
+	p := RetryPolicy{
+		Attempts: 4,
+		Backoff:  time.Second,
+	}
+	unreachable := &HostUnreachableError{Host: "host", Err: errors.New("refused")}
+	timeout := &CommandTimeoutError{Host: "host", Command: "cmd"}
+	failed := &CommandFailedError{Host: "host"}
+	ran := []CommandResult{{Command: "cmd"}}
+
+	// Fails the given number of times with the error, then succeeds
+	calls := 0
+	failing := func(failures int, results []CommandResult, err error) func() ([]CommandResult, error) {
+		calls = 0
+		return func() ([]CommandResult, error) {
+			calls++
+			if calls <= failures {
+				return results, err
+			}
+			return ran, nil
+		}
+	}
+
+	before := GetRetryMetrics()
+
+	// Success is returned at once
+	results, err := p.Run("host", false, failing(0, nil, nil))
+	tests.Assert(t, err == nil)
+	tests.Assert(t, len(results) == 1)
+	tests.Assert(t, calls == 1)
+	tests.Assert(t, len(waits) == 0)
+
+	// Unreachable before anything ran is tried again, doubling the wait
+	results, err = p.Run("host", false, failing(2, nil, unreachable))
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(results) == 1)
+	tests.Assert(t, calls == 3)
+	tests.Assert(t, len(waits) == 2)
+	tests.Assert(t, waits[0] == time.Second && waits[1] == 2*time.Second, waits)
+
+	// Unreachable after some commands ran is only tried
+	// again when the commands are idempotent
+	_, err = p.Run("host", false, failing(1, ran, unreachable))
+	tests.Assert(t, err == unreachable)
+	tests.Assert(t, calls == 1)
+
+	_, err = p.Run("host", true, failing(1, ran, unreachable))
+	tests.Assert(t, err == nil)
+	tests.Assert(t, calls == 2)
+
+	// So are timeouts
+	_, err = p.Run("host", false, failing(1, nil, timeout))
+	tests.Assert(t, err == timeout)
+	tests.Assert(t, calls == 1)
+
+	_, err = p.Run("host", true, failing(1, nil, timeout))
+	tests.Assert(t, err == nil)
+	tests.Assert(t, calls == 2)
+
+	// Failed commands are never tried again
+	_, err = p.Run("host", true, failing(1, nil, failed))
+	tests.Assert(t, err == failed)
+	tests.Assert(t, calls == 1)
+
+	// The last error is returned once all attempts are used
+	_, err = p.Run("host", true, failing(10, nil, unreachable))
+	tests.Assert(t, err == unreachable)
+	tests.Assert(t, calls == 4)
+
+	// No retries without a policy
+	_, err = RetryPolicy{}.Run("host", true, failing(1, nil, unreachable))
+	tests.Assert(t, err == unreachable)
+	tests.Assert(t, calls == 1)
+
+	after := GetRetryMetrics()
+	tests.Assert(t, after.Retries-before.Retries == 7, after)
+	tests.Assert(t, after.Recovered-before.Recovered == 3, after)
+	tests.Assert(t, after.Exhausted-before.Exhausted == 1, after)
+}
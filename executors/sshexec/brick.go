@@ -18,23 +18,48 @@ package sshexec
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/heketi/heketi/executors"
 	"github.com/lpabon/godbc"
 )
 
-const (
-	rootMountPoint = "/var/lib/heketi/mounts"
-)
-
 // Return the mount point for the brick
 func (s *SshExecutor) brickMountPoint(brick *executors.BrickRequest) string {
-	return rootMountPoint + "/" +
+	// Existing bricks stay where they were mounted
+	if brick.Path != "" {
+		return strings.TrimSuffix(brick.Path, "/brick")
+	}
+
+	return s.brickFormat(brick).MountRoot + "/" +
 		s.vgName(brick.VgId) + "/" +
 		s.brickName(brick.Name)
 }
 
+// Runs a command which edits the fstab of the host.  Edits run one
+// at a time on each host, as sed -i rewrites the whole file and
+// would lose an entry added or removed at the same time.
+func (s *SshExecutor) runFstab(host, command string) error {
+	s.Lock.Lock()
+	if s.fstabLocks == nil {
+		s.fstabLocks = make(map[string]*sync.Mutex)
+	}
+	lock, ok := s.fstabLocks[host]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.fstabLocks[host] = lock
+	}
+	s.Lock.Unlock()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	_, err := s.run(host, []string{command}, 5)
+	return err
+}
+
 // Device node for the lvm volume
 func (s *SshExecutor) devnode(brick *executors.BrickRequest) string {
 	return "/dev/" + s.vgName(brick.VgId) +
@@ -53,6 +78,7 @@ func (s *SshExecutor) BrickCreate(host string,
 	godbc.Require(s.Fstab != "")
 
 	// Create mountpoint name
+	format := s.brickFormat(brick)
 	mountpoint := s.brickMountPoint(brick)
 
 	// Create command set to execute on the node
@@ -62,10 +88,13 @@ func (s *SshExecutor) BrickCreate(host string,
 		fmt.Sprintf("sudo mkdir -p %v", mountpoint),
 
 		// Setup the LV
-		fmt.Sprintf("sudo lvcreate --poolmetadatasize %vK -c 256K -L %vK -T %v/%v -V %vK -n %v",
+		fmt.Sprintf("sudo lvcreate --poolmetadatasize %vK -c %vK -L %vK -T %v/%v -V %vK -n %v",
 			// MetadataSize
 			brick.PoolMetadataSize,
 
+			// Chunk size
+			format.ChunkSize,
+
 			//Thin Pool Size
 			brick.TpSize,
 
@@ -82,23 +111,33 @@ func (s *SshExecutor) BrickCreate(host string,
 			s.brickName(brick.Name)),
 
 		// Format
-		fmt.Sprintf("sudo mkfs.xfs -i size=512 -n size=8192 %v", s.devnode(brick)),
+		fmt.Sprintf("sudo mkfs.xfs %v %v", format.MkfsOptions, s.devnode(brick)),
+	}
+
+	// Fstab, edited on its own
+	fstab := fmt.Sprintf("echo \"%v %v xfs %v 1 2\" | sudo tee -a %v > /dev/null ",
+		s.devnode(brick),
+		mountpoint,
+		format.MountOptions,
+		s.Fstab)
 
-		// Fstab
-		fmt.Sprintf("echo \"%v %v xfs rw,inode64,noatime,nouuid 1 2\" | sudo tee -a %v > /dev/null ",
-			s.devnode(brick),
-			mountpoint,
-			s.Fstab),
+	mount := []string{
 
 		// Mount
-		fmt.Sprintf("sudo mount -o rw,inode64,noatime,nouuid %v %v", s.devnode(brick), mountpoint),
+		fmt.Sprintf("sudo mount -o %v %v %v", format.MountOptions, s.devnode(brick), mountpoint),
 
 		// Create a directory inside the formated volume for GlusterFS
 		fmt.Sprintf("sudo mkdir %v/brick", mountpoint),
 	}
 
 	// Execute commands
-	_, err := s.RemoteExecutor.RemoteCommandExecute(host, commands, 10)
+	_, err := s.run(host, commands, s.brickCreateTimeout())
+	if err == nil {
+		err = s.runFstab(host, fstab)
+	}
+	if err == nil {
+		_, err = s.run(host, mount, s.brickCreateTimeout())
+	}
 	if err != nil {
 		// Cleanup
 		s.BrickDestroy(host, brick)
@@ -124,7 +163,7 @@ func (s *SshExecutor) BrickDestroy(host string,
 	commands := []string{
 		fmt.Sprintf("sudo umount %v", s.brickMountPoint(brick)),
 	}
-	_, err := s.RemoteExecutor.RemoteCommandExecute(host, commands, 5)
+	_, err := s.run(host, commands, 5)
 	if err != nil {
 		logger.Err(err)
 	}
@@ -133,7 +172,7 @@ func (s *SshExecutor) BrickDestroy(host string,
 	commands = []string{
 		fmt.Sprintf("sudo lvremove -f %v/%v", s.vgName(brick.VgId), s.tpName(brick.Name)),
 	}
-	_, err = s.RemoteExecutor.RemoteCommandExecute(host, commands, 5)
+	_, err = s.run(host, commands, 5)
 	if err != nil {
 		logger.Err(err)
 	}
@@ -142,18 +181,15 @@ func (s *SshExecutor) BrickDestroy(host string,
 	commands = []string{
 		fmt.Sprintf("sudo rmdir %v", s.brickMountPoint(brick)),
 	}
-	_, err = s.RemoteExecutor.RemoteCommandExecute(host, commands, 5)
+	_, err = s.run(host, commands, 5)
 	if err != nil {
 		logger.Err(err)
 	}
 
 	// Remove from fstab
-	commands = []string{
-		fmt.Sprintf("sudo sed -i.save '/%v/d' %v",
-			s.brickName(brick.Name),
-			s.Fstab),
-	}
-	_, err = s.RemoteExecutor.RemoteCommandExecute(host, commands, 5)
+	err = s.runFstab(host, fmt.Sprintf("sudo sed -i.save '/%v/d' %v",
+		s.brickName(brick.Name),
+		s.Fstab))
 	if err != nil {
 		logger.Err(err)
 	}
@@ -183,33 +219,36 @@ func (s *SshExecutor) checkThinPoolUsage(host string,
 	brick *executors.BrickRequest) error {
 
 	// Sample output:
-	// 		# lvs --options=lv_name,thin_count --separator=: | grep "tp_"
-	// 		tp_a17c621ade79017b48cc0042bea86510:2
-	// 		tp_8d4e0849a5c90608a543928961bd2387:1
-	//		tp_3b9b3e07f06b93d94006ef272d3c10eb:2
+	// 		# lvs --noheadings --options=thin_count vg_6e6d4d7ed4e4f3a6e1cd1f7a8d37d4a1/tp_a17c621ade79017b48cc0042bea86510
+	// 		  2
 
 	tp := s.tpName(brick.Name)
 	commands := []string{
-		fmt.Sprintf("sudo lvs --options=lv_name,thin_count --separator=:"),
+		fmt.Sprintf("sudo lvs --noheadings --options=thin_count %v/%v",
+			s.vgName(brick.VgId), tp),
 	}
 
 	// Send command
-	output, err := s.RemoteExecutor.RemoteCommandExecute(host, commands, 5)
+	results, err := s.query(host, commands, 5)
 	if err != nil {
 		logger.Err(err)
+		return err
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(results[0].Stdout))
+	if err != nil {
 		return fmt.Errorf("Unable to determine number of logical volumes in "+
-			"thin pool %v on host %v", tp, host)
+			"thin pool %v on host %v: %v", tp, host, err)
 	}
 
 	// Determine if do not have only one LV in the thin pool,
 	// we cannot delete the brick
-	lvs := strings.Index(output[0], tp+":1")
-	if lvs == -1 {
+	if count != 1 {
 		return fmt.Errorf("Cannot delete thin pool %v on %v because it "+
 			"is used by [%v] snapshot(s) or cloned volume(s)",
 			tp,
 			host,
-			lvs)
+			count-1)
 	}
 
 	return nil
@@ -17,8 +17,12 @@
 package sshexec
 
 import (
+	"fmt"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/heketi/heketi/executors"
 	"github.com/heketi/tests"
@@ -29,7 +33,7 @@ func TestSshExecBrickCreate(t *testing.T) {
 
 	f := NewFakeSsh()
 	defer tests.Patch(&sshNew,
-		func(logger *utils.Logger, user string, file string) (Ssher, error) {
+		func(logger *utils.Logger, user string, config *SshConfig) (Ssher, error) {
 			return f, nil
 		}).Restore()
 
@@ -53,65 +57,143 @@ func TestSshExecBrickCreate(t *testing.T) {
 		PoolMetadataSize: 5,
 	}
 
-	// Mock ssh function
+	// Mock ssh function.  The fstab is edited in a call of its own.
+	var commands []string
 	f.FakeConnectAndExec = func(host string,
-		commands []string,
+		c []string,
 		timeoutMinutes int) ([]string, error) {
 
 		tests.Assert(t, host == "myhost:100", host)
-		tests.Assert(t, len(commands) == 6)
+		commands = append(commands, c...)
+		return nil, nil
+	}
 
-		for i, cmd := range commands {
-			cmd = strings.Trim(cmd, " ")
-			switch i {
-			case 0:
-				tests.Assert(t,
-					cmd == "sudo mkdir -p /var/lib/heketi/mounts/vg_xvgid/brick_id", cmd)
+	// Create Brick
+	_, err = s.BrickCreate("myhost", b)
+	tests.Assert(t, err == nil, err)
 
-			case 1:
-				tests.Assert(t,
-					cmd == "sudo lvcreate --poolmetadatasize 5K "+
-						"-c 256K -L 100K -T vg_xvgid/tp_id -V 10K -n brick_id", cmd)
+	tests.Assert(t, len(commands) == 6)
+	for i, cmd := range commands {
+		cmd = strings.Trim(cmd, " ")
+		switch i {
+		case 0:
+			tests.Assert(t,
+				cmd == "sudo mkdir -p /var/lib/heketi/mounts/vg_xvgid/brick_id", cmd)
+
+		case 1:
+			tests.Assert(t,
+				cmd == "sudo lvcreate --poolmetadatasize 5K "+
+					"-c 256K -L 100K -T vg_xvgid/tp_id -V 10K -n brick_id", cmd)
+
+		case 2:
+			tests.Assert(t,
+				cmd == "sudo mkfs.xfs -i size=512 "+
+					"-n size=8192 /dev/vg_xvgid/brick_id", cmd)
+
+		case 3:
+			tests.Assert(t,
+				cmd == "echo \"/dev/vg_xvgid/brick_id "+
+					"/var/lib/heketi/mounts/vg_xvgid/brick_id "+
+					"xfs rw,inode64,noatime,nouuid 1 2\" | "+
+					"sudo tee -a /my/fstab > /dev/null", cmd)
+
+		case 4:
+			tests.Assert(t,
+				cmd == "sudo mount -o rw,inode64,noatime,nouuid "+
+					"/dev/vg_xvgid/brick_id "+
+					"/var/lib/heketi/mounts/vg_xvgid/brick_id", cmd)
+
+		case 5:
+			tests.Assert(t,
+				cmd == "sudo mkdir "+
+					"/var/lib/heketi/mounts/vg_xvgid/brick_id/brick", cmd)
+		}
+	}
+}
 
-			case 2:
-				tests.Assert(t,
-					cmd == "sudo mkfs.xfs -i size=512 "+
-						"-n size=8192 /dev/vg_xvgid/brick_id", cmd)
+func TestSshExecBrickCreateFormat(t *testing.T) {
 
-			case 3:
-				tests.Assert(t,
-					cmd == "echo \"/dev/vg_xvgid/brick_id "+
-						"/var/lib/heketi/mounts/vg_xvgid/brick_id "+
-						"xfs rw,inode64,noatime,nouuid 1 2\" | "+
-						"sudo tee -a /my/fstab > /dev/null", cmd)
+	f := NewFakeSsh()
+	defer tests.Patch(&sshNew,
+		func(logger *utils.Logger, user string, config *SshConfig) (Ssher, error) {
+			return f, nil
+		}).Restore()
 
-			case 4:
-				tests.Assert(t,
-					cmd == "sudo mount -o rw,inode64,noatime,nouuid "+
-						"/dev/vg_xvgid/brick_id "+
-						"/var/lib/heketi/mounts/vg_xvgid/brick_id", cmd)
+	config := &SshConfig{
+		PrivateKeyFile:    "xkeyfile",
+		User:              "xuser",
+		Port:              "100",
+		Fstab:             "/my/fstab",
+		BrickMkfsOptions:  "-i size=512 -n size=8192 -d su=128k,sw=10",
+		BrickMountOptions: "rw,inode64,noatime,nouuid,logbsize=256k",
+		BrickChunkSize:    1280,
+		BrickMountRoot:    "/srv/heketi",
+	}
 
-			case 5:
-				tests.Assert(t,
-					cmd == "sudo mkdir "+
-						"/var/lib/heketi/mounts/vg_xvgid/brick_id/brick", cmd)
-			}
-		}
+	s, err := NewSshExecutor(config)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, s != nil)
 
+	b := &executors.BrickRequest{
+		VgId:             "xvgid",
+		Name:             "id",
+		TpSize:           100,
+		Size:             10,
+		PoolMetadataSize: 5,
+	}
+
+	var commands []string
+	f.FakeConnectAndExec = func(host string,
+		c []string,
+		timeoutMinutes int) ([]string, error) {
+		commands = append(commands, c...)
 		return nil, nil
 	}
 
-	// Create Brick
-	_, err = s.BrickCreate("myhost", b)
+	// The configuration is used
+	info, err := s.BrickCreate("myhost", b)
 	tests.Assert(t, err == nil, err)
-
+	tests.Assert(t, info.Path == "/srv/heketi/vg_xvgid/brick_id/brick", info.Path)
+	tests.Assert(t, len(commands) == 6)
+	tests.Assert(t,
+		commands[1] == "sudo lvcreate --poolmetadatasize 5K "+
+			"-c 1280K -L 100K -T vg_xvgid/tp_id -V 10K -n brick_id", commands[1])
+	tests.Assert(t,
+		commands[2] == "sudo mkfs.xfs -i size=512 -n size=8192 -d su=128k,sw=10 "+
+			"/dev/vg_xvgid/brick_id", commands[2])
+	tests.Assert(t,
+		strings.Contains(commands[3], "/srv/heketi/vg_xvgid/brick_id "+
+			"xfs rw,inode64,noatime,nouuid,logbsize=256k 1 2"), commands[3])
+	tests.Assert(t,
+		commands[4] == "sudo mount -o rw,inode64,noatime,nouuid,logbsize=256k "+
+			"/dev/vg_xvgid/brick_id /srv/heketi/vg_xvgid/brick_id", commands[4])
+
+	// The settings of the cluster are used over it
+	commands = nil
+	b.Format = executors.BrickFormat{
+		MountOptions: "rw,noatime,nouuid",
+		ChunkSize:    512,
+		MountRoot:    "/bricks",
+	}
+	info, err = s.BrickCreate("myhost", b)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, info.Path == "/bricks/vg_xvgid/brick_id/brick", info.Path)
+	tests.Assert(t,
+		commands[1] == "sudo lvcreate --poolmetadatasize 5K "+
+			"-c 512K -L 100K -T vg_xvgid/tp_id -V 10K -n brick_id", commands[1])
+	tests.Assert(t,
+		commands[2] == "sudo mkfs.xfs -i size=512 -n size=8192 -d su=128k,sw=10 "+
+			"/dev/vg_xvgid/brick_id", commands[2])
+	tests.Assert(t,
+		commands[4] == "sudo mount -o rw,noatime,nouuid "+
+			"/dev/vg_xvgid/brick_id /bricks/vg_xvgid/brick_id", commands[4])
 }
 
 func TestSshExecBrickDestroy(t *testing.T) {
 
 	f := NewFakeSsh()
 	defer tests.Patch(&sshNew,
-		func(logger *utils.Logger, user string, file string) (Ssher, error) {
+		func(logger *utils.Logger, user string, config *SshConfig) (Ssher, error) {
 			return f, nil
 		}).Restore()
 
@@ -173,3 +255,226 @@ func TestSshExecBrickDestroy(t *testing.T) {
 	err = s.BrickDestroy("myhost", b)
 	tests.Assert(t, err == nil, err)
 }
+
+func TestSshExecBrickDestroyPath(t *testing.T) {
+
+	f := NewFakeSsh()
+	defer tests.Patch(&sshNew,
+		func(logger *utils.Logger, user string, config *SshConfig) (Ssher, error) {
+			return f, nil
+		}).Restore()
+
+	config := &SshConfig{
+		PrivateKeyFile: "xkeyfile",
+		User:           "xuser",
+		Port:           "100",
+		Fstab:          "/my/fstab",
+		BrickMountRoot: "/srv/heketi",
+	}
+
+	s, err := NewSshExecutor(config)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, s != nil)
+
+	// Brick created before the mount root changed
+	b := &executors.BrickRequest{
+		VgId:   "xvgid",
+		Name:   "id",
+		TpSize: 100,
+		Size:   10,
+		Path:   "/var/lib/heketi/mounts/vg_xvgid/brick_id/brick",
+	}
+
+	mountpoints := 0
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+
+		for _, cmd := range commands {
+			if strings.Contains(cmd, "umount") || strings.Contains(cmd, "rmdir") {
+				tests.Assert(t,
+					strings.HasSuffix(cmd, " /var/lib/heketi/mounts/vg_xvgid/brick_id"), cmd)
+				mountpoints++
+			}
+		}
+
+		return nil, nil
+	}
+
+	err = s.BrickDestroy("myhost", b)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, mountpoints == 2)
+}
+
+func TestSshExecBrickFstabConcurrent(t *testing.T) {
+
+	f := NewFakeSsh()
+	defer tests.Patch(&sshNew,
+		func(logger *utils.Logger, user string, config *SshConfig) (Ssher, error) {
+			return f, nil
+		}).Restore()
+
+	config := &SshConfig{
+		PrivateKeyFile:        "xkeyfile",
+		User:                  "xuser",
+		Port:                  "100",
+		Fstab:                 "/my/fstab",
+		MaxConnectionsPerHost: 20,
+	}
+
+	s, err := NewSshExecutor(config)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, s != nil)
+
+	// Fstab of the node, holding the bricks to destroy.  Each edit
+	// reads the file and writes it back later, like sed -i does.
+	var (
+		fstabLock sync.Mutex
+		fstab     []string
+	)
+	for i := 0; i < 10; i++ {
+		fstab = append(fstab, fmt.Sprintf("/dev/vg_xvgid/brick_d%v", i))
+	}
+	edit := func(change func([]string) []string) {
+		fstabLock.Lock()
+		lines := append([]string{}, fstab...)
+		fstabLock.Unlock()
+
+		time.Sleep(time.Millisecond)
+		lines = change(lines)
+
+		fstabLock.Lock()
+		fstab = lines
+		fstabLock.Unlock()
+	}
+
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+
+		for _, cmd := range commands {
+			var brick string
+			switch {
+			case strings.Contains(cmd, "tee -a /my/fstab"):
+				fmt.Sscanf(cmd, "echo \"%s", &brick)
+				edit(func(lines []string) []string {
+					return append(lines, brick)
+				})
+			case strings.Contains(cmd, "sed -i.save"):
+				fmt.Sscanf(cmd, "sudo sed -i.save '/%s", &brick)
+				brick = strings.TrimSuffix(brick, "/d'")
+				edit(func(lines []string) []string {
+					kept := []string{}
+					for _, line := range lines {
+						if !strings.Contains(line, brick) {
+							kept = append(kept, line)
+						}
+					}
+					return kept
+				})
+			}
+		}
+		return nil, nil
+	}
+
+	// Create and destroy bricks on the node at the same time
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			_, err := s.BrickCreate("myhost", &executors.BrickRequest{
+				VgId:   "xvgid",
+				Name:   fmt.Sprintf("c%v", i),
+				TpSize: 100,
+				Size:   10,
+			})
+			tests.Assert(t, err == nil, err)
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			err := s.BrickDestroy("myhost", &executors.BrickRequest{
+				VgId:   "xvgid",
+				Name:   fmt.Sprintf("d%v", i),
+				TpSize: 100,
+				Size:   10,
+			})
+			tests.Assert(t, err == nil, err)
+		}(i)
+	}
+	wg.Wait()
+
+	// Only the created bricks are left, none lost
+	sort.Strings(fstab)
+	tests.Assert(t, len(fstab) == 10, fstab)
+	for i, line := range fstab {
+		tests.Assert(t, line == fmt.Sprintf("/dev/vg_xvgid/brick_c%v", i), fstab)
+	}
+}
+
+func TestSshExecBrickDestroyCheck(t *testing.T) {
+
+	f := NewFakeSsh()
+	defer tests.Patch(&sshNew,
+		func(logger *utils.Logger, user string, config *SshConfig) (Ssher, error) {
+			return f, nil
+		}).Restore()
+
+	config := &SshConfig{
+		PrivateKeyFile: "xkeyfile",
+		User:           "xuser",
+		Port:           "100",
+	}
+
+	s, err := NewSshExecutor(config)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, s != nil)
+
+	b := &executors.BrickRequest{
+		VgId: "xvgid",
+		Name: "id",
+	}
+
+	// Only the brick uses the thin pool
+	count := "  1\n"
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+
+		tests.Assert(t, host == "myhost:100", host)
+		tests.Assert(t, len(commands) == 1)
+		tests.Assert(t, commands[0] == "sudo lvs --noheadings "+
+			"--options=thin_count vg_xvgid/tp_id", commands[0])
+
+		return []string{count}, nil
+	}
+
+	err = s.BrickDestroyCheck("myhost", b)
+	tests.Assert(t, err == nil, err)
+
+	// A snapshot uses the thin pool
+	count = "  2\n"
+	err = s.BrickDestroyCheck("myhost", b)
+	tests.Assert(t, err != nil)
+	tests.Assert(t, strings.Contains(err.Error(), "used by [1] snapshot(s)"), err)
+
+	// Counts which only start with 1 are not mistaken for it
+	count = "  12\n"
+	err = s.BrickDestroyCheck("myhost", b)
+	tests.Assert(t, err != nil)
+	tests.Assert(t, strings.Contains(err.Error(), "used by [11] snapshot(s)"), err)
+
+	// Errors from the node are returned unchanged
+	s.RetryPolicy = executors.RetryPolicy{}
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+		return nil, &executors.CommandTimeoutError{
+			Host:    host,
+			Command: commands[0],
+		}
+	}
+
+	err = s.BrickDestroyCheck("myhost", b)
+	tests.Assert(t, executors.IsTimeout(err), err)
+}
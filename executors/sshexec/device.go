@@ -17,6 +17,7 @@
 package sshexec
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/heketi/heketi/executors"
@@ -30,8 +31,40 @@ const (
 	VGDISPLAY_TOTAL_NUMBER_EXTENTS     = 13
 	VGDISPLAY_ALLOCATED_NUMBER_EXTENTS = 14
 	VGDISPLAY_FREE_NUMBER_EXTENTS      = 15
+
+	SMART_ATTRIBUTE_REALLOCATED_SECTORS = 5
+	SMART_ATTRIBUTE_PENDING_SECTORS     = 197
+
+	// smartctl exit status bits which mean the disk could not be read
+	SMARTCTL_EXIT_COMMAND_LINE_ERROR = 1 << 0
+	SMARTCTL_EXIT_DEVICE_OPEN_FAILED = 1 << 1
 )
 
+// Subset of the output of smartctl --json
+type smartctlOutput struct {
+	Smartctl struct {
+		ExitStatus int `json:"exit_status"`
+		Messages   []struct {
+			String string `json:"string"`
+		} `json:"messages"`
+	} `json:"smartctl"`
+	SmartStatus *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	AtaSmartAttributes struct {
+		Table []struct {
+			Id  int `json:"id"`
+			Raw struct {
+				Value uint64 `json:"value"`
+			} `json:"raw"`
+		} `json:"table"`
+	} `json:"ata_smart_attributes"`
+	ScsiGrownDefectList uint64 `json:"scsi_grown_defect_list"`
+	Temperature         struct {
+		Current int `json:"current"`
+	} `json:"temperature"`
+}
+
 // Read:
 // https://access.redhat.com/documentation/en-US/Red_Hat_Storage/3.1/html/Administration_Guide/Brick_Configuration.html
 //
@@ -45,7 +78,7 @@ func (s *SshExecutor) DeviceSetup(host, device, vgid string) (d *executors.Devic
 	}
 
 	// Execute command
-	_, err := s.RemoteExecutor.RemoteCommandExecute(host, commands, 5)
+	_, err := s.run(host, commands, s.deviceSetupTimeout())
 	if err != nil {
 		return nil, err
 	}
@@ -76,7 +109,7 @@ func (s *SshExecutor) DeviceTeardown(host, device, vgid string) error {
 	}
 
 	// Execute command
-	_, err := s.RemoteExecutor.RemoteCommandExecute(host, commands, 5)
+	_, err := s.run(host, commands, 5)
 	if err != nil {
 		logger.LogError("Error while deleting device %v on %v with id %v",
 			device, host, vgid)
@@ -85,9 +118,181 @@ func (s *SshExecutor) DeviceTeardown(host, device, vgid string) error {
 	return nil
 }
 
-func (s *SshExecutor) getVgSizeFromNode(
-	d *executors.DeviceInfo,
-	host, device, vgid string) error {
+// Resizes the physical volume to the current size of the device
+// and returns the total size of the volume group
+func (s *SshExecutor) DeviceResync(host, device, vgid string) (*executors.DeviceInfo, error) {
+
+	// Setup command
+	commands := []string{
+		fmt.Sprintf("sudo pvresize %v", device),
+	}
+
+	// Execute command
+	_, err := s.run(host, commands, 5)
+	if err != nil {
+		return nil, err
+	}
+
+	// Vg info
+	vginfo, err := s.vgDisplay(host, vgid)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &executors.DeviceInfo{}
+	d.Size = vginfo.totalExtents * vginfo.extentSize
+	d.ExtentSize = vginfo.extentSize
+	logger.Debug("Total size of %v in %v is %v", device, host, d.Size)
+	return d, nil
+}
+
+// Reads the SMART health of the disk
+func (s *SshExecutor) DeviceHealth(host, device string) (*executors.DeviceHealthInfo, error) {
+
+	// smartctl sets bits in the exit status when the disk has
+	// problems, but the output is still valid
+	commands := []string{
+		fmt.Sprintf("sudo smartctl --json -H -A %v || true", device),
+	}
+
+	// Execute command
+	b, err := s.query(host, commands, 5)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSmartctl(device, b[0].Stdout)
+}
+
+func (s *SshExecutor) DeviceUsage(host, vgid string) (*executors.DeviceUsageInfo, error) {
+
+	commands := []string{
+		fmt.Sprintf("sudo lvs --noheadings --units k --nosuffix --separator=: "+
+			"-o lv_name,lv_size,data_percent,metadata_percent %v", s.vgName(vgid)),
+		"df -k --output=target,size,used",
+	}
+
+	// Execute command
+	b, err := s.query(host, commands, 5)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.parseDeviceUsage(vgid, b[0].Stdout, b[1].Stdout)
+}
+
+// Parses the thin pools of the volume group from lvs and the
+// brick mounts from df
+func (s *SshExecutor) parseDeviceUsage(vgid, lvs, df string) (*executors.DeviceUsageInfo, error) {
+	usage := &executors.DeviceUsageInfo{
+		Bricks: make(map[string]*executors.BrickUsageInfo),
+	}
+
+	// Example:
+	//   tp_8d4e0849a5c90608a543928961bd2387:2097152.00:12.50:0.83
+	//   brick_8d4e0849a5c90608a543928961bd2387:2097152.00:12.50:
+	for _, line := range strings.Split(lvs, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), ":")
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], s.tpName("")) {
+			continue
+		}
+
+		size, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse size of %v: %v", fields[0], err)
+		}
+		data, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse data usage of %v: %v", fields[0], err)
+		}
+		metadata, err := strconv.ParseFloat(fields[3], 64)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse metadata usage of %v: %v", fields[0], err)
+		}
+
+		usage.Bricks[strings.TrimPrefix(fields[0], s.tpName(""))] = &executors.BrickUsageInfo{
+			PoolSize:            uint64(size),
+			PoolDataPercent:     data,
+			PoolMetadataPercent: metadata,
+		}
+	}
+
+	// Example:
+	//   Mounted on                                              1K-blocks  Used
+	//   /var/lib/heketi/mounts/vg_abc/brick_8d4e0849a5c906...    2086912 33184
+	// Bricks may be mounted under any mount root
+	infix := "/" + s.vgName(vgid) + "/" + s.brickName("")
+	for _, line := range strings.Split(df, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		i := strings.LastIndex(fields[0], infix)
+		if i < 0 {
+			continue
+		}
+
+		brick, ok := usage.Bricks[fields[0][i+len(infix):]]
+		if !ok {
+			continue
+		}
+
+		var err error
+		brick.Size, err = strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse size of %v: %v", fields[0], err)
+		}
+		brick.Used, err = strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse used space of %v: %v", fields[0], err)
+		}
+	}
+
+	return usage, nil
+}
+
+func parseSmartctl(device, output string) (*executors.DeviceHealthInfo, error) {
+	var smart smartctlOutput
+	err := json.Unmarshal([]byte(output), &smart)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse smartctl output for %v: %v", device, err)
+	}
+
+	if smart.Smartctl.ExitStatus&
+		(SMARTCTL_EXIT_COMMAND_LINE_ERROR|SMARTCTL_EXIT_DEVICE_OPEN_FAILED) != 0 ||
+		smart.SmartStatus == nil {
+		messages := make([]string, 0)
+		for _, message := range smart.Smartctl.Messages {
+			messages = append(messages, message.String)
+		}
+		return nil, fmt.Errorf("Unable to read SMART data from %v: %v",
+			device, strings.Join(messages, ", "))
+	}
+
+	d := &executors.DeviceHealthInfo{
+		Passed:             smart.SmartStatus.Passed,
+		ReallocatedSectors: smart.ScsiGrownDefectList,
+		Temperature:        smart.Temperature.Current,
+	}
+	for _, attribute := range smart.AtaSmartAttributes.Table {
+		switch attribute.Id {
+		case SMART_ATTRIBUTE_REALLOCATED_SECTORS:
+			d.ReallocatedSectors = attribute.Raw.Value
+		case SMART_ATTRIBUTE_PENDING_SECTORS:
+			d.PendingSectors = attribute.Raw.Value
+		}
+	}
+
+	return d, nil
+}
+
+type vgInfo struct {
+	extentSize   uint64
+	totalExtents uint64
+	freeExtents  uint64
+}
+
+func (s *SshExecutor) vgDisplay(host, vgid string) (*vgInfo, error) {
 
 	// Setup command
 	commands := []string{
@@ -95,34 +300,49 @@ func (s *SshExecutor) getVgSizeFromNode(
 	}
 
 	// Execute command
-	b, err := s.RemoteExecutor.RemoteCommandExecute(host, commands, 5)
+	b, err := s.query(host, commands, 5)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Example:
 	// sampleVg:r/w:772:-1:0:0:0:-1:0:4:4:2097135616:4096:511996:0:511996:rJ0bIG-3XNc-NoS0-fkKm-batK-dFyX-xbxHym
-	vginfo := strings.Split(b[0], ":")
+	vginfo := strings.Split(strings.TrimSpace(b[0].Stdout), ":")
 
 	// See vgdisplay manpage
 	if len(vginfo) < 17 {
-		return errors.New("vgdisplay returned an invalid string")
+		return nil, errors.New("vgdisplay returned an invalid string")
 	}
 
-	extent_size, err :=
-		strconv.ParseUint(vginfo[VGDISPLAY_PHYSICAL_EXTENT_SIZE], 10, 64)
-	if err != nil {
-		return err
+	info := &vgInfo{}
+	for _, field := range []struct {
+		index int
+		value *uint64
+	}{
+		{VGDISPLAY_PHYSICAL_EXTENT_SIZE, &info.extentSize},
+		{VGDISPLAY_TOTAL_NUMBER_EXTENTS, &info.totalExtents},
+		{VGDISPLAY_FREE_NUMBER_EXTENTS, &info.freeExtents},
+	} {
+		*field.value, err = strconv.ParseUint(vginfo[field.index], 10, 64)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	free_extents, err :=
-		strconv.ParseUint(vginfo[VGDISPLAY_FREE_NUMBER_EXTENTS], 10, 64)
+	return info, nil
+}
+
+func (s *SshExecutor) getVgSizeFromNode(
+	d *executors.DeviceInfo,
+	host, device, vgid string) error {
+
+	vginfo, err := s.vgDisplay(host, vgid)
 	if err != nil {
 		return err
 	}
 
-	d.Size = free_extents * extent_size
-	d.ExtentSize = extent_size
+	d.Size = vginfo.freeExtents * vginfo.extentSize
+	d.ExtentSize = vginfo.extentSize
 	logger.Debug("Size of %v in %v is %v", device, host, d.Size)
 	return nil
 }
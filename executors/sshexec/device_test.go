@@ -0,0 +1,259 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package sshexec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/heketi/tests"
+	"github.com/heketi/utils"
+)
+
+func TestSshExecDeviceResync(t *testing.T) {
+
+	f := NewFakeSsh()
+	defer tests.Patch(&sshNew,
+		func(logger *utils.Logger, user string, config *SshConfig) (Ssher, error) {
+			return f, nil
+		}).Restore()
+
+	config := &SshConfig{
+		PrivateKeyFile: "xkeyfile",
+		User:           "xuser",
+		Port:           "100",
+	}
+
+	s, err := NewSshExecutor(config)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, s != nil)
+
+	// Mock ssh function
+	calls := 0
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+
+		tests.Assert(t, host == "myhost:100", host)
+		tests.Assert(t, len(commands) == 1)
+
+		calls++
+		cmd := strings.Trim(commands[0], " ")
+		switch calls {
+		case 1:
+			tests.Assert(t, cmd == "sudo pvresize /dev/sdb", cmd)
+			return []string{""}, nil
+		case 2:
+			tests.Assert(t, cmd == "sudo vgdisplay -c vg_xvgid", cmd)
+		}
+
+		// 1000 extents of 4096KB, 200 of them free
+		return []string{"  vg_xvgid:r/w:772:-1:0:0:0:-1:0:4:4:4096000:4096:1000:800:200:rJ0bIG\n"}, nil
+	}
+
+	d, err := s.DeviceResync("myhost", "/dev/sdb", "xvgid")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, calls == 2)
+	tests.Assert(t, d.Size == 1000*4096, d.Size)
+	tests.Assert(t, d.ExtentSize == 4096)
+
+	// Invalid output from vgdisplay
+	calls = 0
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+		calls++
+		return []string{"vg_xvgid:r/w"}, nil
+	}
+
+	_, err = s.DeviceResync("myhost", "/dev/sdb", "xvgid")
+	tests.Assert(t, err != nil)
+	tests.Assert(t, calls == 2)
+}
+
+func TestSshExecDeviceHealth(t *testing.T) {
+
+	f := NewFakeSsh()
+	defer tests.Patch(&sshNew,
+		func(logger *utils.Logger, user string, config *SshConfig) (Ssher, error) {
+			return f, nil
+		}).Restore()
+
+	config := &SshConfig{
+		PrivateKeyFile: "xkeyfile",
+		User:           "xuser",
+		Port:           "100",
+	}
+
+	s, err := NewSshExecutor(config)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, s != nil)
+
+	// Failing ATA disk
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+
+		tests.Assert(t, host == "myhost:100", host)
+		tests.Assert(t, len(commands) == 1)
+		tests.Assert(t, commands[0] == "sudo smartctl --json -H -A /dev/sdb || true",
+			commands[0])
+
+		return []string{`{
+			"smartctl": {"exit_status": 8},
+			"smart_status": {"passed": false},
+			"ata_smart_attributes": {
+				"table": [
+					{"id": 1, "raw": {"value": 1234}},
+					{"id": 5, "raw": {"value": 12}},
+					{"id": 197, "raw": {"value": 3}}
+				]
+			},
+			"temperature": {"current": 41}
+		}`}, nil
+	}
+
+	d, err := s.DeviceHealth("myhost", "/dev/sdb")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, !d.Passed)
+	tests.Assert(t, d.ReallocatedSectors == 12)
+	tests.Assert(t, d.PendingSectors == 3)
+	tests.Assert(t, d.Temperature == 41)
+
+	// SCSI disk
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+		return []string{`{
+			"smartctl": {"exit_status": 0},
+			"smart_status": {"passed": true},
+			"scsi_grown_defect_list": 7
+		}`}, nil
+	}
+
+	d, err = s.DeviceHealth("myhost", "/dev/sdb")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, d.Passed)
+	tests.Assert(t, d.ReallocatedSectors == 7)
+	tests.Assert(t, d.PendingSectors == 0)
+
+	// Device cannot be opened
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+		return []string{`{
+			"smartctl": {
+				"exit_status": 2,
+				"messages": [{"string": "Smartctl open device: /dev/sdb failed"}]
+			}
+		}`}, nil
+	}
+
+	_, err = s.DeviceHealth("myhost", "/dev/sdb")
+	tests.Assert(t, err != nil)
+	tests.Assert(t, strings.Contains(err.Error(), "open device"), err)
+
+	// smartctl not installed
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+		return []string{""}, nil
+	}
+
+	_, err = s.DeviceHealth("myhost", "/dev/sdb")
+	tests.Assert(t, err != nil)
+}
+
+func TestSshExecDeviceUsage(t *testing.T) {
+
+	f := NewFakeSsh()
+	defer tests.Patch(&sshNew,
+		func(logger *utils.Logger, user string, config *SshConfig) (Ssher, error) {
+			return f, nil
+		}).Restore()
+
+	config := &SshConfig{
+		PrivateKeyFile: "xkeyfile",
+		User:           "xuser",
+		Port:           "100",
+	}
+
+	s, err := NewSshExecutor(config)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, s != nil)
+
+	// Mock ssh function
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+
+		tests.Assert(t, host == "myhost:100", host)
+		tests.Assert(t, len(commands) == 2)
+		tests.Assert(t, commands[0] == "sudo lvs --noheadings --units k --nosuffix "+
+			"--separator=: -o lv_name,lv_size,data_percent,metadata_percent vg_xvgid",
+			commands[0])
+		tests.Assert(t, commands[1] == "df -k --output=target,size,used", commands[1])
+
+		return []string{
+			"  brick_b1:2097152.00:12.50:\n" +
+				"  tp_b1:2097152.00:12.50:0.83\n" +
+				"  tp_b2:1048576.00:95.00:4.00\n" +
+				"  tp_b3:1048576.00:10.00:1.00\n",
+			"Mounted on                                      1K-blocks    Used\n" +
+				"/                                                41152736 9024512\n" +
+				"/var/lib/heketi/mounts/vg_xvgid/brick_b1          2086912  262144\n" +
+				"/var/lib/heketi/mounts/vg_other/brick_b2          1038336  100000\n" +
+				"/srv/heketi/vg_xvgid/brick_b3                     1038336   50000\n",
+		}, nil
+	}
+
+	usage, err := s.DeviceUsage("myhost", "xvgid")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(usage.Bricks) == 3)
+
+	b1 := usage.Bricks["b1"]
+	tests.Assert(t, b1 != nil)
+	tests.Assert(t, b1.PoolSize == 2097152)
+	tests.Assert(t, b1.PoolDataPercent == 12.5)
+	tests.Assert(t, b1.PoolMetadataPercent == 0.83)
+	tests.Assert(t, b1.Size == 2086912)
+	tests.Assert(t, b1.Used == 262144)
+
+	// Not mounted in this volume group
+	b2 := usage.Bricks["b2"]
+	tests.Assert(t, b2 != nil)
+	tests.Assert(t, b2.PoolDataPercent == 95)
+	tests.Assert(t, b2.Size == 0)
+	tests.Assert(t, b2.Used == 0)
+
+	// Mounted under another mount root
+	b3 := usage.Bricks["b3"]
+	tests.Assert(t, b3 != nil)
+	tests.Assert(t, b3.Size == 1038336)
+	tests.Assert(t, b3.Used == 50000)
+
+	// Bad lvs output
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+		return []string{"  tp_b1:2097152.00:bad:0.83\n", ""}, nil
+	}
+
+	_, err = s.DeviceUsage("myhost", "xvgid")
+	tests.Assert(t, err != nil)
+	tests.Assert(t, strings.Contains(err.Error(), "tp_b1"), err)
+}
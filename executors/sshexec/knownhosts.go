@@ -0,0 +1,178 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package sshexec
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Refuses nodes whose host key is not in the known hosts file.
+// The file is read for each connection, so that nodes can be
+// added to it without restarting the server.
+func knownHostsCallback(file string) func(string, net.Addr, ssh.PublicKey) error {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		return checkKnownHost(file, hostname, key)
+	}
+}
+
+func checkKnownHost(file, hostname string, key ssh.PublicKey) error {
+	fp, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("Unable to read known hosts file %v: %v", file, err)
+	}
+	defer fp.Close()
+
+	address := knownHostsAddress(hostname)
+	known, mismatch := false, false
+
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		marker := ""
+		if strings.HasPrefix(fields[0], "@") {
+			marker, fields = fields[0], fields[1:]
+		}
+
+		// Certificate authorities are not supported
+		if len(fields) < 3 || marker == "@cert-authority" {
+			continue
+		}
+		if !knownHostsMatch(fields[0], address) || fields[1] != key.Type() {
+			continue
+		}
+
+		blob, err := base64.StdEncoding.DecodeString(fields[2])
+		if err != nil {
+			continue
+		}
+
+		same := bytes.Equal(blob, key.Marshal())
+		switch {
+		case marker == "@revoked":
+			if same {
+				return fmt.Errorf("Host key of %v has been revoked", hostname)
+			}
+		case same:
+			known = true
+		default:
+			mismatch = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("Unable to read known hosts file %v: %v", file, err)
+	}
+
+	switch {
+	case known:
+		return nil
+	case mismatch:
+		return fmt.Errorf("Host key of %v does not match the one in %v", hostname, file)
+	default:
+		return fmt.Errorf("Host %v is not in known hosts file %v", hostname, file)
+	}
+}
+
+// Returns the address as written in known_hosts files, where
+// the port is only given when it is not the default
+func knownHostsAddress(hostname string) string {
+	host, port, err := net.SplitHostPort(hostname)
+	if err != nil {
+		return hostname
+	}
+	if port == "22" {
+		return host
+	}
+	return "[" + host + "]:" + port
+}
+
+// Checks the address against the comma separated patterns of a
+// known_hosts line, or against its hash when the line is hashed
+func knownHostsMatch(patterns, address string) bool {
+	if strings.HasPrefix(patterns, "|1|") {
+		parts := strings.Split(patterns[len("|1|"):], "|")
+		if len(parts) != 2 {
+			return false
+		}
+		salt, err := base64.StdEncoding.DecodeString(parts[0])
+		if err != nil {
+			return false
+		}
+		hash, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return false
+		}
+
+		mac := hmac.New(sha1.New, salt)
+		mac.Write([]byte(address))
+		return hmac.Equal(mac.Sum(nil), hash)
+	}
+
+	address = strings.ToLower(address)
+	matched := false
+	for _, pattern := range strings.Split(patterns, ",") {
+		pattern = strings.ToLower(pattern)
+		if strings.HasPrefix(pattern, "!") {
+			if wildcardMatch(pattern[1:], address) {
+				return false
+			}
+		} else if wildcardMatch(pattern, address) {
+			matched = true
+		}
+	}
+
+	return matched
+}
+
+// Matches with the '*' and '?' wildcards of OpenSSH patterns
+func wildcardMatch(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(s); i >= 0; i-- {
+				if wildcardMatch(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		default:
+			if len(s) == 0 || pattern[0] != s[0] {
+				return false
+			}
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+
+	return len(s) == 0
+}
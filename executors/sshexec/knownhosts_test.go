@@ -0,0 +1,130 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package sshexec
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/heketi/tests"
+)
+
+// Public key which only needs to be compared
+type fakeKey struct {
+	keyType string
+	blob    []byte
+}
+
+func (k *fakeKey) Type() string {
+	return k.keyType
+}
+
+func (k *fakeKey) Marshal() []byte {
+	return k.blob
+}
+
+func (k *fakeKey) Verify(data []byte, sig *ssh.Signature) error {
+	return nil
+}
+
+func hashHost(salt []byte, host string) string {
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(host))
+	return "|1|" + base64.StdEncoding.EncodeToString(salt) +
+		"|" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestKnownHosts(t *testing.T) {
+	key := &fakeKey{"ssh-ed25519", []byte("key of the nodes")}
+	other := &fakeKey{"ssh-ed25519", []byte("another key")}
+	rsa := &fakeKey{"ssh-rsa", []byte("rsa key")}
+	revoked := &fakeKey{"ssh-ed25519", []byte("stolen key")}
+
+	encoded := func(k *fakeKey) string {
+		return k.keyType + " " + base64.StdEncoding.EncodeToString(k.blob)
+	}
+
+	file := tests.Tempfile()
+	defer os.Remove(file)
+	err := ioutil.WriteFile(file, []byte(strings.Join([]string{
+		"# Storage nodes",
+		"node1,192.168.10.100 " + encoded(key),
+		"[node2]:2222 " + encoded(key) + " comment",
+		hashHost([]byte("01234567890123456789"), "node3") + " " + encoded(key),
+		"*.storage.lab,!bad.storage.lab " + encoded(key),
+		"node?.rack " + encoded(key),
+		"@cert-authority * " + encoded(other),
+		"@revoked * " + encoded(revoked),
+		"",
+	}, "\n")), 0600)
+	tests.Assert(t, err == nil)
+
+	check := knownHostsCallback(file)
+
+	// Known hosts, on the default port or not
+	for _, host := range []string{
+		"node1:22",
+		"NODE1:22",
+		"192.168.10.100:22",
+		"[node2]:2222",
+		"node3:22",
+		"a.storage.lab:22",
+		"node5.rack:22",
+	} {
+		err = check(host, nil, key)
+		tests.Assert(t, err == nil, host, err)
+	}
+
+	// Unknown hosts
+	for _, host := range []string{
+		"node2:22",
+		"node1:2222",
+		"node4:22",
+		"bad.storage.lab:22",
+		"node10.rack:22",
+	} {
+		err = check(host, nil, key)
+		tests.Assert(t, err != nil, host)
+		tests.Assert(t, strings.Contains(err.Error(), "is not in known hosts"), host, err)
+	}
+
+	// A different key
+	err = check("node1:22", nil, other)
+	tests.Assert(t, err != nil)
+	tests.Assert(t, strings.Contains(err.Error(), "does not match"), err)
+
+	// Only keys of another type are known
+	err = check("node1:22", nil, rsa)
+	tests.Assert(t, err != nil)
+	tests.Assert(t, strings.Contains(err.Error(), "is not in known hosts"), err)
+
+	// A revoked key
+	err = check("node1:22", nil, revoked)
+	tests.Assert(t, err != nil)
+	tests.Assert(t, strings.Contains(err.Error(), "revoked"), err)
+
+	// Missing file
+	err = knownHostsCallback("/this/does/not/exist")("node1:22", nil, key)
+	tests.Assert(t, err != nil)
+}
@@ -0,0 +1,161 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package sshexec
+
+import (
+	"sync"
+	"time"
+)
+
+// Connection which can be kept open between commands
+type poolConn interface {
+	// Checks that the other end still answers
+	Alive() bool
+	Close() error
+}
+
+type idleConn struct {
+	conn  poolConn
+	since time.Time
+}
+
+// Keeps the connections to each host open once their commands have
+// run, so that later commands do not need a new handshake.  Idle
+// connections are checked every keepalive interval, and closed when
+// they stop answering or have not been used for the idle timeout.
+type connectionPool struct {
+	dial        func(host string) (poolConn, error)
+	idleTimeout time.Duration
+	keepalive   time.Duration
+
+	lock     sync.Mutex
+	idle     map[string][]idleConn
+	checking bool
+}
+
+func newConnectionPool(dial func(host string) (poolConn, error),
+	idleTimeout, keepalive time.Duration) *connectionPool {
+
+	return &connectionPool{
+		dial:        dial,
+		idleTimeout: idleTimeout,
+		keepalive:   keepalive,
+		idle:        make(map[string][]idleConn),
+	}
+}
+
+// Returns an idle connection to the host, or a new one if there
+// are none.  Connections which have been idle longer than the
+// keepalive interval are checked before being reused.
+func (p *connectionPool) Get(host string) (poolConn, error) {
+	for {
+		c, ok := p.takeIdle(host)
+		if !ok {
+			break
+		}
+
+		idle := time.Since(c.since)
+		if idle < p.idleTimeout && (idle < p.keepalive || c.conn.Alive()) {
+			return c.conn, nil
+		}
+		c.conn.Close()
+	}
+
+	return p.dial(host)
+}
+
+// Returns the connection to the pool once its commands have run
+func (p *connectionPool) Put(host string, conn poolConn) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.idle[host] = append(p.idle[host], idleConn{
+		conn:  conn,
+		since: time.Now(),
+	})
+
+	if !p.checking {
+		p.checking = true
+		go p.checkIdle()
+	}
+}
+
+// Returns the number of idle connections to the host
+func (p *connectionPool) Idle(host string) int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return len(p.idle[host])
+}
+
+// Takes the most recently used idle connection to the host
+func (p *connectionPool) takeIdle(host string) (idleConn, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	conns := p.idle[host]
+	if len(conns) == 0 {
+		return idleConn{}, false
+	}
+
+	c := conns[len(conns)-1]
+	if len(conns) == 1 {
+		delete(p.idle, host)
+	} else {
+		p.idle[host] = conns[:len(conns)-1]
+	}
+
+	return c, true
+}
+
+// Checks the idle connections every keepalive interval until
+// there are none left
+func (p *connectionPool) checkIdle() {
+	ticker := time.NewTicker(p.keepalive)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		// Connections being checked are not handed out
+		p.lock.Lock()
+		idle := p.idle
+		p.idle = make(map[string][]idleConn)
+		p.lock.Unlock()
+
+		alive := make(map[string][]idleConn)
+		for host, conns := range idle {
+			for _, c := range conns {
+				if time.Since(c.since) >= p.idleTimeout || !c.conn.Alive() {
+					logger.Debug("Closing idle connection to %v", host)
+					c.conn.Close()
+					continue
+				}
+				alive[host] = append(alive[host], c)
+			}
+		}
+
+		p.lock.Lock()
+		for host, conns := range alive {
+			p.idle[host] = append(conns, p.idle[host]...)
+		}
+		if len(p.idle) == 0 {
+			p.checking = false
+			p.lock.Unlock()
+			return
+		}
+		p.lock.Unlock()
+	}
+}
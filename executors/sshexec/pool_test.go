@@ -0,0 +1,527 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package sshexec
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/tests"
+	"github.com/heketi/utils"
+)
+
+type fakeConn struct {
+	lock   sync.Mutex
+	host   string
+	alive  bool
+	closed bool
+	checks int
+}
+
+func (c *fakeConn) Alive() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.checks++
+	return c.alive
+}
+
+func (c *fakeConn) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *fakeConn) setAlive(alive bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.alive = alive
+}
+
+func (c *fakeConn) isClosed() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.closed
+}
+
+// Returns a pool of fake connections and the connections dialed
+func newFakePool(idleTimeout, keepalive time.Duration) (*connectionPool, *[]*fakeConn) {
+	var lock sync.Mutex
+	dialed := make([]*fakeConn, 0)
+	pool := newConnectionPool(func(host string) (poolConn, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		if host == "unreachable" {
+			return nil, errors.New("connection refused")
+		}
+		c := &fakeConn{host: host, alive: true}
+		dialed = append(dialed, c)
+		return c, nil
+	}, idleTimeout, keepalive)
+
+	return pool, &dialed
+}
+
+func TestConnectionPoolReuse(t *testing.T) {
+	pool, dialed := newFakePool(time.Hour, time.Hour)
+
+	// New connection
+	conn, err := pool.Get("host1")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, len(*dialed) == 1)
+
+	// A connection in use is not handed out again
+	conn2, err := pool.Get("host1")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, conn2 != conn)
+	tests.Assert(t, len(*dialed) == 2)
+
+	// Connections are reused once returned
+	pool.Put("host1", conn)
+	pool.Put("host1", conn2)
+	tests.Assert(t, pool.Idle("host1") == 2)
+
+	for i := 0; i < 10; i++ {
+		c, err := pool.Get("host1")
+		tests.Assert(t, err == nil)
+		tests.Assert(t, c == conn2)
+		pool.Put("host1", c)
+	}
+	tests.Assert(t, len(*dialed) == 2)
+
+	// But only for the same host
+	conn3, err := pool.Get("host2")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, conn3.(*fakeConn).host == "host2")
+	tests.Assert(t, len(*dialed) == 3)
+
+	// Dial errors are returned
+	_, err = pool.Get("unreachable")
+	tests.Assert(t, err != nil)
+
+	// Recently used connections are not checked
+	tests.Assert(t, conn2.(*fakeConn).checks == 0)
+}
+
+func TestConnectionPoolStaleConnections(t *testing.T) {
+	// Connections idle too long are closed
+	pool, dialed := newFakePool(time.Millisecond, time.Hour)
+
+	conn, err := pool.Get("host")
+	tests.Assert(t, err == nil)
+	pool.Put("host", conn)
+	time.Sleep(5 * time.Millisecond)
+
+	conn2, err := pool.Get("host")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, conn2 != conn)
+	tests.Assert(t, conn.(*fakeConn).isClosed())
+	tests.Assert(t, len(*dialed) == 2)
+
+	// Connections not used for the keepalive interval
+	// are checked, and closed if they do not answer
+	pool, dialed = newFakePool(time.Hour, time.Millisecond)
+
+	conn, err = pool.Get("host")
+	tests.Assert(t, err == nil)
+	conn.(*fakeConn).setAlive(false)
+	pool.Put("host", conn)
+	time.Sleep(5 * time.Millisecond)
+
+	conn2, err = pool.Get("host")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, conn2 != conn)
+	tests.Assert(t, conn.(*fakeConn).isClosed())
+	tests.Assert(t, len(*dialed) == 2)
+}
+
+func TestConnectionPoolKeepalive(t *testing.T) {
+	pool, _ := newFakePool(time.Hour, 5*time.Millisecond)
+
+	good, err := pool.Get("host")
+	tests.Assert(t, err == nil)
+	bad, err := pool.Get("host")
+	tests.Assert(t, err == nil)
+	bad.(*fakeConn).setAlive(false)
+
+	pool.Put("host", good)
+	pool.Put("host", bad)
+	time.Sleep(50 * time.Millisecond)
+
+	// Only the connection which answers is kept
+	tests.Assert(t, pool.Idle("host") == 1, pool.Idle("host"))
+	tests.Assert(t, bad.(*fakeConn).isClosed())
+	tests.Assert(t, !good.(*fakeConn).isClosed())
+
+	// The check stops once there are no idle connections
+	good.(*fakeConn).setAlive(false)
+	time.Sleep(50 * time.Millisecond)
+
+	tests.Assert(t, pool.Idle("host") == 0)
+	tests.Assert(t, good.(*fakeConn).isClosed())
+	pool.lock.Lock()
+	tests.Assert(t, !pool.checking)
+	pool.lock.Unlock()
+}
+
+// Key used by the test server as host key and accepted from
+// the clients, written to a key file for them
+var (
+	testKeyOnce sync.Once
+	testKeyFile string
+	testSigner  ssh.Signer
+)
+
+func testKey(t testing.TB) (string, ssh.Signer) {
+	testKeyOnce.Do(func() {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testSigner, err = ssh.NewSignerFromKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		file, err := ioutil.TempFile("", "heketi-test-key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		err = pem.Encode(file, &pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(key),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		testKeyFile = file.Name()
+	})
+	return testKeyFile, testSigner
+}
+
+// Ssh server in the same process.  Commands print their name,
+// except "fail", which exits with status 1, and "hang", which
+// never finishes.  Keepalives are not answered while hangKeepalive
+// is set, like on a connection whose other end is gone.
+type testSshServer struct {
+	listener net.Listener
+	config   *ssh.ServerConfig
+
+	lock          sync.Mutex
+	connections   int
+	hangKeepalive bool
+}
+
+func newTestSshServer(t testing.TB) *testSshServer {
+	_, signer := testKey(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testSshServer{
+		listener: listener,
+		config: &ssh.ServerConfig{
+			PublicKeyCallback: func(conn ssh.ConnMetadata,
+				key ssh.PublicKey) (*ssh.Permissions, error) {
+				if !bytes.Equal(key.Marshal(), signer.PublicKey().Marshal()) {
+					return nil, errors.New("unknown key")
+				}
+				return nil, nil
+			},
+		},
+	}
+	s.config.AddHostKey(signer)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *testSshServer) Host() string {
+	return s.listener.Addr().String()
+}
+
+func (s *testSshServer) Close() {
+	s.listener.Close()
+}
+
+func (s *testSshServer) Connections() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.connections
+}
+
+func (s *testSshServer) setHangKeepalive(hang bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.hangKeepalive = hang
+}
+
+func (s *testSshServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	_, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	s.lock.Lock()
+	s.connections++
+	s.lock.Unlock()
+
+	go func() {
+		for req := range reqs {
+			s.lock.Lock()
+			hang := s.hangKeepalive
+			s.lock.Unlock()
+			if req.WantReply && !hang {
+				req.Reply(false, nil)
+			}
+		}
+	}()
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go s.session(channel, requests)
+	}
+}
+
+func (s *testSshServer) session(channel ssh.Channel, requests <-chan *ssh.Request) {
+	for req := range requests {
+		if req.Type != "exec" {
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+			continue
+		}
+
+		var exec struct {
+			Command string
+		}
+		ssh.Unmarshal(req.Payload, &exec)
+		req.Reply(true, nil)
+
+		// Commands are run as /bin/bash -c 'command'
+		command := strings.TrimSuffix(strings.TrimPrefix(exec.Command,
+			"/bin/bash -c '"), "'")
+		status := uint32(0)
+		switch command {
+		case "hang":
+			continue
+		case "fail":
+			channel.Stderr().Write([]byte("failed\n"))
+			status = 1
+		default:
+			channel.Write([]byte(command + "\n"))
+		}
+		channel.SendRequest("exit-status", false, ssh.Marshal(&struct {
+			Status uint32
+		}{status}))
+		channel.Close()
+	}
+}
+
+func newTestSshClient(t testing.TB, config *SshConfig) *sshClient {
+	config.PrivateKeyFile, _ = testKey(t)
+	c, err := newSshClient(utils.NewLogger("[test]", utils.LEVEL_NOLOG), "heketi", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestSshClientPool(t *testing.T) {
+	server := newTestSshServer(t)
+	defer server.Close()
+	host := server.Host()
+
+	c := newTestSshClient(t, &SshConfig{})
+
+	// Commands reuse the same connection
+	for i := 0; i < 3; i++ {
+		results, err := c.ConnectAndExec(host, []string{"one", "two"}, 1)
+		tests.Assert(t, err == nil, err)
+		tests.Assert(t, len(results) == 2)
+		tests.Assert(t, results[0].Stdout == "one\n", results[0].Stdout)
+		tests.Assert(t, results[1].Stdout == "two\n", results[1].Stdout)
+		tests.Assert(t, results[1].ExitStatus == 0)
+	}
+	tests.Assert(t, server.Connections() == 1, server.Connections())
+	tests.Assert(t, c.pool.Idle(host) == 1)
+
+	// Failed commands keep the connection
+	results, err := c.ConnectAndExec(host, []string{"one", "fail", "two"}, 1)
+	tests.Assert(t, executors.IsCommandFailed(err), err)
+	tests.Assert(t, len(results) == 2)
+	tests.Assert(t, results[1].ExitStatus == 1)
+	tests.Assert(t, results[1].Stderr == "failed\n", results[1].Stderr)
+	tests.Assert(t, server.Connections() == 1)
+	tests.Assert(t, c.pool.Idle(host) == 1)
+
+	// Commands which time out close the connection
+	_, err = c.ConnectAndExec(host, []string{"hang"}, 0)
+	tests.Assert(t, executors.IsTimeout(err), err)
+	tests.Assert(t, c.pool.Idle(host) == 0)
+
+	_, err = c.ConnectAndExec(host, []string{"one"}, 1)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, server.Connections() == 2, server.Connections())
+
+	// Without the pool each command connects
+	c = newTestSshClient(t, &SshConfig{DisableConnectionPool: true})
+	tests.Assert(t, c.pool == nil)
+	for i := 0; i < 2; i++ {
+		_, err = c.ConnectAndExec(host, []string{"one"}, 1)
+		tests.Assert(t, err == nil, err)
+	}
+	tests.Assert(t, server.Connections() == 4, server.Connections())
+}
+
+func TestSshConnectionAlive(t *testing.T) {
+	defer tests.Patch(&keepaliveTimeout, 50*time.Millisecond).Restore()
+
+	server := newTestSshServer(t)
+	defer server.Close()
+	host := server.Host()
+
+	c := newTestSshClient(t, &SshConfig{})
+	conn, err := c.dial(host)
+	tests.Assert(t, err == nil, err)
+
+	// Answered keepalives, even when refused
+	tests.Assert(t, conn.Alive())
+
+	// Unanswered keepalives time out and close the connection
+	server.setHangKeepalive(true)
+	start := time.Now()
+	tests.Assert(t, !conn.Alive())
+	tests.Assert(t, time.Since(start) < time.Second)
+	_, err = conn.(*sshConnection).NewSession()
+	tests.Assert(t, err != nil)
+
+	// A pooled connection which stopped answering is replaced
+	// without blocking the command
+	server.setHangKeepalive(false)
+	c = newTestSshClient(t, &SshConfig{KeepaliveInterval: 3600})
+	c.pool.keepalive = time.Millisecond
+
+	_, err = c.ConnectAndExec(host, []string{"one"}, 1)
+	tests.Assert(t, err == nil, err)
+	connections := server.Connections()
+
+	server.setHangKeepalive(true)
+	time.Sleep(5 * time.Millisecond)
+	start = time.Now()
+	results, err := c.ConnectAndExec(host, []string{"two"}, 1)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, results[0].Stdout == "two\n")
+	tests.Assert(t, time.Since(start) < time.Second)
+	tests.Assert(t, server.Connections() == connections+1)
+
+	// Wait for the idle check to stop before restoring the timeout
+	for conn, ok := c.pool.takeIdle(host); ok; conn, ok = c.pool.takeIdle(host) {
+		conn.conn.Close()
+	}
+	for {
+		c.pool.lock.Lock()
+		checking := c.pool.checking
+		c.pool.lock.Unlock()
+		if !checking {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func BenchmarkSshConnectEachCommand(b *testing.B) {
+	server := newTestSshServer(b)
+	defer server.Close()
+	host := server.Host()
+	c := newTestSshClient(b, &SshConfig{DisableConnectionPool: true})
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := c.ConnectAndExec(host, []string{"command"}, 1)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSshConnectionPool(b *testing.B) {
+	server := newTestSshServer(b)
+	defer server.Close()
+	host := server.Host()
+	c := newTestSshClient(b, &SshConfig{})
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := c.ConnectAndExec(host, []string{"command"}, 1)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSshConnectionPoolParallel(b *testing.B) {
+	server := newTestSshServer(b)
+	defer server.Close()
+	host := server.Host()
+	c := newTestSshClient(b, &SshConfig{})
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			// Fatal must not be called from these goroutines
+			_, err := c.ConnectAndExec(host, []string{"command"}, 1)
+			if err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
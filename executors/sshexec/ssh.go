@@ -0,0 +1,272 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package sshexec
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/utils"
+)
+
+// Runs commands on nodes over ssh, keeping the output, exit
+// status and duration of each command
+type sshClient struct {
+	logger       *utils.Logger
+	clientConfig *ssh.ClientConfig
+
+	// Open connections to the nodes, unless disabled
+	pool *connectionPool
+}
+
+// Connection to a node which can be kept in the pool
+type sshConnection struct {
+	*ssh.Client
+}
+
+var (
+	// Time to wait for the node to answer a keepalive
+	keepaliveTimeout = 15 * time.Second
+)
+
+// Sends a keepalive.  A connection which does not answer in time,
+// like one whose other end is gone without closing it, is closed.
+func (c *sshConnection) Alive() bool {
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := c.SendRequest("keepalive@openssh.com", true, nil)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err == nil
+	case <-time.After(keepaliveTimeout):
+		c.Close()
+		return false
+	}
+}
+
+// Creates a client authenticating as the user with the key file
+// and the ssh-agent set in the configuration
+func newSshClient(logger *utils.Logger, user string, config *SshConfig) (*sshClient, error) {
+	auth := make([]ssh.AuthMethod, 0, 2)
+
+	if config.PrivateKeyFile != "" {
+		key, err := ioutil.ReadFile(config.PrivateKeyFile)
+		if err != nil {
+			logger.LogError("Unable to read private key file %v: %v",
+				config.PrivateKeyFile, err)
+			return nil, ErrSshPrivateKey
+		}
+
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			logger.LogError("Unable to parse private key file %v: %v",
+				config.PrivateKeyFile, err)
+			return nil, ErrSshPrivateKey
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+
+	if config.Agent {
+		socket := os.Getenv("SSH_AUTH_SOCK")
+		if socket == "" {
+			logger.LogError("SSH_AUTH_SOCK is not set")
+			return nil, ErrSshAgent
+		}
+
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			logger.LogError("Unable to connect to ssh-agent at %v: %v", socket, err)
+			return nil, ErrSshAgent
+		}
+		auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+
+	clientConfig := &ssh.ClientConfig{
+		User: user,
+		Auth: auth,
+	}
+	if config.KnownHostsFile != "" {
+		clientConfig.HostKeyCallback = knownHostsCallback(config.KnownHostsFile)
+	}
+
+	c := &sshClient{
+		logger:       logger,
+		clientConfig: clientConfig,
+	}
+	if !config.DisableConnectionPool {
+		c.pool = newConnectionPool(c.dial, config.idleTimeout(), config.keepaliveInterval())
+	}
+
+	return c, nil
+}
+
+func (c *sshClient) dial(host string) (poolConn, error) {
+	client, err := ssh.Dial("tcp", host, c.clientConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &sshConnection{client}, nil
+}
+
+// Returns a connection to the host from the pool, or a new one
+func (c *sshClient) connect(host string) (poolConn, error) {
+	if c.pool != nil {
+		return c.pool.Get(host)
+	}
+	return c.dial(host)
+}
+
+// Keeps the connection for the next commands, unless it was lost
+// or a command on it timed out and may still be running
+func (c *sshClient) release(host string, conn poolConn, err error) {
+	if c.pool == nil || executors.IsHostUnreachable(err) || executors.IsTimeout(err) {
+		conn.Close()
+		return
+	}
+	c.pool.Put(host, conn)
+}
+
+func (c *sshClient) ConnectAndExec(host string,
+	commands []string,
+	timeoutMinutes int) ([]executors.CommandResult, error) {
+
+	conn, err := c.connect(host)
+	if err != nil {
+		c.logger.Warning("Failed to create SSH connection to %v: %v", host, err)
+		return nil, &executors.HostUnreachableError{Host: host, Err: err}
+	}
+	client := conn.(*sshConnection).Client
+
+	timeout := time.Duration(timeoutMinutes) * time.Minute
+	results := make([]executors.CommandResult, 0, len(commands))
+	for _, command := range commands {
+		result, err := c.exec(client, host, command, timeout)
+		if result != nil {
+			results = append(results, *result)
+		}
+		if err != nil {
+			c.release(host, conn, err)
+			return results, err
+		}
+	}
+
+	c.release(host, conn, nil)
+	return results, nil
+}
+
+// Output of a command, which can be read on timeout while the
+// session may still be writing to it
+type lockedBuffer struct {
+	lock sync.Mutex
+	b    bytes.Buffer
+}
+
+func (l *lockedBuffer) Write(p []byte) (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.b.Write(p)
+}
+
+func (l *lockedBuffer) String() string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.b.String()
+}
+
+// Runs the command, returning no result if it could not be started
+func (c *sshClient) exec(client *ssh.Client,
+	host, command string,
+	timeout time.Duration) (*executors.CommandResult, error) {
+
+	result := &executors.CommandResult{
+		Command:    command,
+		ExitStatus: -1,
+	}
+
+	session, err := client.NewSession()
+	if err != nil {
+		c.logger.LogError("Unable to create SSH session on %v: %v", host, err)
+		return nil, &executors.HostUnreachableError{Host: host, Err: err}
+	}
+	defer session.Close()
+
+	// Create a buffer to trap session output
+	var b lockedBuffer
+	var berr lockedBuffer
+	session.Stdout = &b
+	session.Stderr = &berr
+
+	// Execute command in a shell
+	start := time.Now()
+	err = session.Start("/bin/bash -c '" + command + "'")
+	if err != nil {
+		c.logger.LogError("Unable to start command [%v] on %v: %v", command, host, err)
+		return nil, &executors.HostUnreachableError{Host: host, Err: err}
+	}
+
+	// Wait for either the command to finish or the timeout
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+	select {
+	case err = <-done:
+	case <-time.After(timeout):
+		session.Signal(ssh.SIGKILL)
+		result.Duration = time.Since(start)
+		result.Stdout = b.String()
+		result.Stderr = berr.String()
+		c.logger.LogError("Timeout on command [%v] on %v: Stdout [%v]: Stderr [%v]",
+			command, host, result.Stdout, result.Stderr)
+		return result, &executors.CommandTimeoutError{
+			Host:    host,
+			Command: command,
+			Timeout: timeout,
+		}
+	}
+	result.Duration = time.Since(start)
+	result.Stdout = b.String()
+	result.Stderr = berr.String()
+
+	if err != nil {
+		c.logger.LogError("Failed to run command [%v] on %v: Err[%v]: Stdout [%v]: Stderr [%v]",
+			command, host, err, result.Stdout, result.Stderr)
+
+		// Without an exit status the connection was lost
+		exitErr, ok := err.(*ssh.ExitError)
+		if !ok {
+			return result, &executors.HostUnreachableError{Host: host, Err: err}
+		}
+		result.ExitStatus = exitErr.ExitStatus()
+		return result, &executors.CommandFailedError{Host: host, Result: *result}
+	}
+
+	result.ExitStatus = 0
+	c.logger.Debug("Host: %v Command: %v\nResult: %v", host, command, result.Stdout)
+	return result, nil
+}
@@ -20,18 +20,23 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"time"
 
+	"github.com/heketi/heketi/executors"
 	"github.com/heketi/utils"
-	"github.com/heketi/utils/ssh"
 	"github.com/lpabon/godbc"
 )
 
+// Runs the commands in order on the host, stopping at the first
+// one which fails.  The results of the commands which ran are
+// returned, along with a HostUnreachableError, CommandFailedError
+// or CommandTimeoutError if they did not all succeed.
 type RemoteCommandTransport interface {
-	RemoteCommandExecute(host string, commands []string, timeoutMinutes int) ([]string, error)
+	RemoteCommandExecute(host string, commands []string, timeoutMinutes int) ([]executors.CommandResult, error)
 }
 
 type Ssher interface {
-	ConnectAndExec(host string, commands []string, timeoutMinutes int) ([]string, error)
+	ConnectAndExec(host string, commands []string, timeoutMinutes int) ([]executors.CommandResult, error)
 }
 
 type SshExecutor struct {
@@ -40,6 +45,7 @@ type SshExecutor struct {
 	Lock           sync.Mutex
 	RemoteExecutor RemoteCommandTransport
 	Fstab          string
+	RetryPolicy    executors.RetryPolicy
 
 	// Private
 	private_keyfile string
@@ -47,6 +53,16 @@ type SshExecutor struct {
 	exec            Ssher
 	config          *SshConfig
 	port            string
+	globalThrottle  chan bool
+
+	// Connections to the nodes which do not use the default user
+	// or port, and the clients for the users of those nodes
+	hostsLock sync.RWMutex
+	hosts     map[string]executors.HostConnection
+	execs     map[string]Ssher
+
+	// Serializes the edits of the fstab of each node, guarded by Lock
+	fstabLocks map[string]*sync.Mutex
 }
 
 type SshConfig struct {
@@ -55,19 +71,83 @@ type SshConfig struct {
 	Port           string `json:"port"`
 	Fstab          string `json:"fstab"`
 
+	// Authenticate with the keys held by the ssh-agent listening
+	// on SSH_AUTH_SOCK, along with or instead of the key file
+	Agent bool `json:"agent"`
+
+	// When set, the host key of each node must be found in this
+	// file, in the OpenSSH known_hosts format
+	KnownHostsFile string `json:"known_hosts"`
+
+	// Number of commands which can run at the same time on each
+	// node, and on all the nodes together.  By default one command
+	// runs at a time on each node, with no limit across nodes.
+	MaxConnectionsPerHost int `json:"max_connections_per_host"`
+	MaxConnections        int `json:"max_connections"`
+
+	// Timeouts in minutes for the longer operations.
+	// Zero uses the default.
+	DeviceSetupTimeout  int `json:"device_setup_timeout"`
+	BrickCreateTimeout  int `json:"brick_create_timeout"`
+	VolumeCreateTimeout int `json:"volume_create_timeout"`
+
+	// Times commands are tried when the node cannot be reached, and
+	// seconds to wait before the first retry.  Zero uses the default.
+	RetryAttempts int `json:"retry_attempts"`
+	RetryBackoff  int `json:"retry_backoff"`
+
+	// Connections to the nodes are kept open between commands and
+	// closed once idle for the timeout in seconds.  Idle connections
+	// are checked every keepalive interval in seconds.  Zero uses
+	// the default.
+	DisableConnectionPool bool `json:"disable_connection_pool"`
+	IdleTimeout           int  `json:"idle_timeout"`
+	KeepaliveInterval     int  `json:"keepalive_interval"`
+
+	// Formatting and mounting of bricks: options given to mkfs.xfs
+	// and mount, chunk size of the thin pools in KB, and directory
+	// the bricks are mounted under.  Unset values use the defaults,
+	// and each cluster may override them.
+	BrickMkfsOptions  string `json:"brick_mkfs_options"`
+	BrickMountOptions string `json:"brick_mount_options"`
+	BrickChunkSize    int    `json:"brick_chunk_size"`
+	BrickMountRoot    string `json:"brick_mount_root"`
+
 	// Experimental Settings
 	RebalanceOnExpansion bool `json:"rebalance_on_expansion"`
 }
 
+// Default timeouts in minutes
+const (
+	defaultDeviceSetupTimeout  = 5
+	defaultBrickCreateTimeout  = 10
+	defaultVolumeCreateTimeout = 10
+)
+
+const (
+	DefaultRetryAttempts = 3
+	DefaultRetryBackoff  = 1
+)
+
+const (
+	defaultBrickMkfsOptions  = "-i size=512 -n size=8192"
+	defaultBrickMountOptions = "rw,inode64,noatime,nouuid"
+	defaultBrickChunkSize    = 256
+	defaultBrickMountRoot    = "/var/lib/heketi/mounts"
+)
+
+// Seconds
+const (
+	defaultIdleTimeout       = 120
+	defaultKeepaliveInterval = 30
+)
+
 var (
 	logger           = utils.NewLogger("[sshexec]", utils.LEVEL_DEBUG)
 	ErrSshPrivateKey = errors.New("Unable to read private key file")
-	sshNew           = func(logger *utils.Logger, user string, file string) (Ssher, error) {
-		s := ssh.NewSshExecWithKeyFile(logger, user, file)
-		if s == nil {
-			return nil, ErrSshPrivateKey
-		}
-		return s, nil
+	ErrSshAgent      = errors.New("Unable to connect to ssh-agent")
+	sshNew           = func(logger *utils.Logger, user string, config *SshConfig) (Ssher, error) {
+		return newSshClient(logger, user, config)
 	}
 )
 
@@ -77,9 +157,10 @@ func NewSshExecutor(config *SshConfig) (*SshExecutor, error) {
 	s := &SshExecutor{}
 	s.RemoteExecutor = s
 	s.Throttlemap = make(map[string]chan bool)
+	s.hosts = make(map[string]executors.HostConnection)
 
 	// Set configuration
-	if config.PrivateKeyFile == "" {
+	if config.PrivateKeyFile == "" && !config.Agent {
 		return nil, fmt.Errorf("Missing ssh private key file in configuration")
 	}
 	s.private_keyfile = config.PrivateKeyFile
@@ -105,14 +186,24 @@ func NewSshExecutor(config *SshConfig) (*SshExecutor, error) {
 	// Save the configuration
 	s.config = config
 
+	if config.MaxConnections > 0 {
+		s.globalThrottle = make(chan bool, config.MaxConnections)
+	}
+	s.RetryPolicy = NewRetryPolicy(config.RetryAttempts, config.RetryBackoff)
+
 	// Show experimental settings
 	if s.config.RebalanceOnExpansion {
 		logger.Warning("Rebalance on volume expansion has been enabled.  This is an EXPERIMENTAL feature")
 	}
 
+	if config.KnownHostsFile == "" {
+		logger.Warning("Host keys of the nodes are not checked.  " +
+			"Set known_hosts in the configuration to check them")
+	}
+
 	// Setup key
 	var err error
-	s.exec, err = sshNew(logger, s.user, s.private_keyfile)
+	s.exec, err = sshNew(logger, s.user, s.config)
 	if err != nil {
 		logger.Err(err)
 		return nil, err
@@ -121,13 +212,18 @@ func NewSshExecutor(config *SshConfig) (*SshExecutor, error) {
 	godbc.Ensure(s != nil)
 	godbc.Ensure(s.config == config)
 	godbc.Ensure(s.user != "")
-	godbc.Ensure(s.private_keyfile != "")
 	godbc.Ensure(s.port != "")
 	godbc.Ensure(s.Fstab != "")
 
 	return s, nil
 }
 
+// Sets the configuration used to generate the commands, for executors
+// which embed this one but do not connect with ssh
+func (s *SshExecutor) SetConfig(config *SshConfig) {
+	s.config = config
+}
+
 func (s *SshExecutor) SetLogLevel(level string) {
 	switch level {
 	case "none":
@@ -154,12 +250,17 @@ func (s *SshExecutor) AccessConnection(host string) {
 
 	s.Lock.Lock()
 	if c, ok = s.Throttlemap[host]; !ok {
-		c = make(chan bool, 1)
+		c = make(chan bool, s.maxConnectionsPerHost())
 		s.Throttlemap[host] = c
 	}
 	s.Lock.Unlock()
 
+	// Wait for the node first, so that a slow node does
+	// not hold a connection the other nodes could use
 	c <- true
+	if s.globalThrottle != nil {
+		s.globalThrottle <- true
+	}
 }
 
 func (s *SshExecutor) FreeConnection(host string) {
@@ -167,19 +268,215 @@ func (s *SshExecutor) FreeConnection(host string) {
 	c := s.Throttlemap[host]
 	s.Lock.Unlock()
 
+	if s.globalThrottle != nil {
+		<-s.globalThrottle
+	}
 	<-c
 }
 
+func (s *SshExecutor) maxConnectionsPerHost() int {
+	if s.config != nil && s.config.MaxConnectionsPerHost > 0 {
+		return s.config.MaxConnectionsPerHost
+	}
+	return 1
+}
+
+func (s *SshExecutor) deviceSetupTimeout() int {
+	if s.config != nil && s.config.DeviceSetupTimeout > 0 {
+		return s.config.DeviceSetupTimeout
+	}
+	return defaultDeviceSetupTimeout
+}
+
+func (s *SshExecutor) brickCreateTimeout() int {
+	if s.config != nil && s.config.BrickCreateTimeout > 0 {
+		return s.config.BrickCreateTimeout
+	}
+	return defaultBrickCreateTimeout
+}
+
+func (s *SshExecutor) volumeCreateTimeout() int {
+	if s.config != nil && s.config.VolumeCreateTimeout > 0 {
+		return s.config.VolumeCreateTimeout
+	}
+	return defaultVolumeCreateTimeout
+}
+
+// Returns the settings used to format and mount the brick, those
+// of the request first, then those of the configuration
+func (s *SshExecutor) brickFormat(brick *executors.BrickRequest) executors.BrickFormat {
+	format := brick.Format
+	if format.MkfsOptions == "" {
+		format.MkfsOptions = defaultBrickMkfsOptions
+		if s.config != nil && s.config.BrickMkfsOptions != "" {
+			format.MkfsOptions = s.config.BrickMkfsOptions
+		}
+	}
+	if format.MountOptions == "" {
+		format.MountOptions = defaultBrickMountOptions
+		if s.config != nil && s.config.BrickMountOptions != "" {
+			format.MountOptions = s.config.BrickMountOptions
+		}
+	}
+	if format.ChunkSize == 0 {
+		format.ChunkSize = defaultBrickChunkSize
+		if s.config != nil && s.config.BrickChunkSize > 0 {
+			format.ChunkSize = s.config.BrickChunkSize
+		}
+	}
+	if format.MountRoot == "" {
+		format.MountRoot = defaultBrickMountRoot
+		if s.config != nil && s.config.BrickMountRoot != "" {
+			format.MountRoot = s.config.BrickMountRoot
+		}
+	}
+	return format
+}
+
+func (c *SshConfig) idleTimeout() time.Duration {
+	if c.IdleTimeout > 0 {
+		return time.Duration(c.IdleTimeout) * time.Second
+	}
+	return defaultIdleTimeout * time.Second
+}
+
+func (c *SshConfig) keepaliveInterval() time.Duration {
+	if c.KeepaliveInterval > 0 {
+		return time.Duration(c.KeepaliveInterval) * time.Second
+	}
+	return defaultKeepaliveInterval * time.Second
+}
+
 func (s *SshExecutor) RemoteCommandExecute(host string,
 	commands []string,
-	timeoutMinutes int) ([]string, error) {
+	timeoutMinutes int) ([]executors.CommandResult, error) {
+
+	// Use the settings of the node, if any
+	user, port := s.user, s.port
+	s.hostsLock.RLock()
+	conn, ok := s.hosts[host]
+	s.hostsLock.RUnlock()
+	if ok {
+		if conn.User != "" {
+			user = conn.User
+		}
+		if conn.Port != "" {
+			port = conn.Port
+		}
+	}
+
+	exec, err := s.sshClient(user)
+	if err != nil {
+		return nil, err
+	}
 
 	// Throttle
 	s.AccessConnection(host)
 	defer s.FreeConnection(host)
 
 	// Execute
-	return s.exec.ConnectAndExec(host+":"+s.port, commands, timeoutMinutes)
+	return exec.ConnectAndExec(host+":"+port, commands, timeoutMinutes)
+}
+
+// Sets the user and port used to connect to the node.  Empty
+// settings use those of the configuration again.
+func (s *SshExecutor) SetHostConnection(host string, conn executors.HostConnection) {
+	s.hostsLock.Lock()
+	defer s.hostsLock.Unlock()
+
+	// Executors which embed this one do not need the settings
+	if s.hosts == nil {
+		return
+	}
+
+	if conn.User == "" && conn.Port == "" {
+		delete(s.hosts, host)
+	} else {
+		s.hosts[host] = conn
+	}
+}
+
+// Returns the client connecting as the user, creating
+// it the first time the user is needed
+func (s *SshExecutor) sshClient(user string) (Ssher, error) {
+	if user == s.user {
+		return s.exec, nil
+	}
+
+	s.hostsLock.Lock()
+	defer s.hostsLock.Unlock()
+
+	if exec, ok := s.execs[user]; ok {
+		return exec, nil
+	}
+	if s.execs == nil {
+		s.execs = make(map[string]Ssher)
+	}
+
+	exec, err := sshNew(logger, user, s.config)
+	if err != nil {
+		logger.Err(err)
+		return nil, err
+	}
+	s.execs[user] = exec
+
+	return exec, nil
+}
+
+// Returns the retry policy for the configured attempts and
+// backoff in seconds, using the defaults for values not set
+func NewRetryPolicy(attempts, backoff int) executors.RetryPolicy {
+	if attempts == 0 {
+		attempts = DefaultRetryAttempts
+	}
+	if backoff == 0 {
+		backoff = DefaultRetryBackoff
+	}
+
+	return executors.RetryPolicy{
+		Attempts: attempts,
+		Backoff:  time.Duration(backoff) * time.Second,
+	}
+}
+
+// Runs commands which change the node.  They are only tried again
+// if the node could not be reached before any of them ran.
+func (s *SshExecutor) run(host string,
+	commands []string,
+	timeoutMinutes int) ([]executors.CommandResult, error) {
+
+	return s.RetryPolicy.Run(host, false, func() ([]executors.CommandResult, error) {
+		return s.RemoteExecutor.RemoteCommandExecute(host, commands, timeoutMinutes)
+	})
+}
+
+// Runs commands which only read the state of the node, so they
+// can be tried again whenever the node could not be reached or
+// did not answer in time.
+func (s *SshExecutor) query(host string,
+	commands []string,
+	timeoutMinutes int) ([]executors.CommandResult, error) {
+
+	return s.RetryPolicy.Run(host, true, func() ([]executors.CommandResult, error) {
+		return s.RemoteExecutor.RemoteCommandExecute(host, commands, timeoutMinutes)
+	})
+}
+
+// Checks that the node can be reached and is able to run
+// commands with sudo.  Not retried, so that the answer is quick.
+func (s *SshExecutor) Ping(host string) error {
+	godbc.Require(host != "")
+
+	commands := []string{
+		"sudo true",
+	}
+
+	_, err := s.RemoteExecutor.RemoteCommandExecute(host, commands, 1)
+	if err != nil {
+		return err
+	}
+
+	return nil
 }
 
 func (s *SshExecutor) vgName(vgId string) string {
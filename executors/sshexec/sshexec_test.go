@@ -17,8 +17,14 @@
 package sshexec
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/heketi/heketi/executors"
 	"github.com/heketi/tests"
 	"github.com/heketi/utils"
 )
@@ -42,16 +48,31 @@ func NewFakeSsh() *FakeSsh {
 
 func (f *FakeSsh) ConnectAndExec(host string,
 	commands []string,
-	timeoutMinutes int) ([]string, error) {
-	return f.FakeConnectAndExec(host, commands, timeoutMinutes)
+	timeoutMinutes int) ([]executors.CommandResult, error) {
 
+	output, err := f.FakeConnectAndExec(host, commands, timeoutMinutes)
+
+	// On failure the output given is that of the commands which ran
+	count := len(commands)
+	if err != nil && len(output) < count {
+		count = len(output)
+	}
+
+	results := make([]executors.CommandResult, count)
+	for index, command := range commands[:count] {
+		results[index].Command = command
+		if index < len(output) {
+			results[index].Stdout = output[index]
+		}
+	}
+	return results, err
 }
 
 func TestNewSshExec(t *testing.T) {
 
 	f := NewFakeSsh()
 	defer tests.Patch(&sshNew,
-		func(logger *utils.Logger, user string, file string) (Ssher, error) {
+		func(logger *utils.Logger, user string, config *SshConfig) (Ssher, error) {
 			return f, nil
 		}).Restore()
 
@@ -75,7 +96,7 @@ func TestNewSshExec(t *testing.T) {
 func TestNewSshExecDefaults(t *testing.T) {
 	f := NewFakeSsh()
 	defer tests.Patch(&sshNew,
-		func(logger *utils.Logger, user string, file string) (Ssher, error) {
+		func(logger *utils.Logger, user string, config *SshConfig) (Ssher, error) {
 			return f, nil
 		}).Restore()
 
@@ -91,6 +112,8 @@ func TestNewSshExecDefaults(t *testing.T) {
 	tests.Assert(t, s.port == "22")
 	tests.Assert(t, s.Fstab == "/etc/fstab")
 	tests.Assert(t, s.exec != nil)
+	tests.Assert(t, s.RetryPolicy.Attempts == DefaultRetryAttempts)
+	tests.Assert(t, s.RetryPolicy.Backoff == DefaultRetryBackoff*time.Second)
 
 }
 
@@ -101,3 +124,452 @@ func TestNewSshExecBadPrivateKeyLocation(t *testing.T) {
 	tests.Assert(t, s == nil)
 	tests.Assert(t, err != nil)
 }
+
+func TestSshExecPing(t *testing.T) {
+	f := NewFakeSsh()
+	defer tests.Patch(&sshNew,
+		func(logger *utils.Logger, user string, config *SshConfig) (Ssher, error) {
+			return f, nil
+		}).Restore()
+
+	config := &SshConfig{
+		PrivateKeyFile: "xkeyfile",
+		User:           "xuser",
+		Port:           "100",
+	}
+
+	s, err := NewSshExecutor(config)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, s != nil)
+
+	// Mock ssh function
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+
+		tests.Assert(t, host == "myhost:100", host)
+		tests.Assert(t, len(commands) == 1)
+		tests.Assert(t, commands[0] == "sudo true", commands)
+
+		return []string{""}, nil
+	}
+
+	err = s.Ping("myhost")
+	tests.Assert(t, err == nil, err)
+
+	// Unreachable host
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+		return nil, &executors.HostUnreachableError{
+			Host: host,
+			Err:  errors.New("connection refused"),
+		}
+	}
+
+	err = s.Ping("myhost")
+	tests.Assert(t, err != nil)
+	tests.Assert(t, executors.IsHostUnreachable(err), err)
+	tests.Assert(t, err.Error() == "Unable to reach myhost:100: connection refused", err)
+
+	// Reachable, but sudo is not allowed
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+		return nil, &executors.CommandFailedError{
+			Host: host,
+			Result: executors.CommandResult{
+				Command:    commands[0],
+				Stderr:     "sudo: a password is required\n",
+				ExitStatus: 1,
+			},
+		}
+	}
+
+	err = s.Ping("myhost")
+	tests.Assert(t, err != nil)
+	tests.Assert(t, executors.IsCommandFailed(err), err)
+	tests.Assert(t, !executors.IsHostUnreachable(err))
+	tests.Assert(t, err.Error() == "Unable to execute command on myhost:100: "+
+		"[sudo true] exited with status 1: sudo: a password is required", err)
+}
+
+// Runs pings on the hosts in parallel and returns the most
+// commands which ran at the same time on one host, and in total
+func measureParallelism(t *testing.T, s *SshExecutor, f *FakeSsh,
+	hosts []string, pingsPerHost int) (int, int) {
+
+	var (
+		lock     sync.Mutex
+		running  = make(map[string]int)
+		total    int
+		maxHost  int
+		maxTotal int
+	)
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+
+		lock.Lock()
+		running[host]++
+		total++
+		if running[host] > maxHost {
+			maxHost = running[host]
+		}
+		if total > maxTotal {
+			maxTotal = total
+		}
+		lock.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		lock.Lock()
+		running[host]--
+		total--
+		lock.Unlock()
+
+		return []string{""}, nil
+	}
+
+	var wg sync.WaitGroup
+	for _, host := range hosts {
+		for i := 0; i < pingsPerHost; i++ {
+			wg.Add(1)
+			go func(host string) {
+				defer wg.Done()
+				err := s.Ping(host)
+				tests.Assert(t, err == nil, err)
+			}(host)
+		}
+	}
+	wg.Wait()
+
+	return maxHost, maxTotal
+}
+
+func TestSshExecConcurrency(t *testing.T) {
+	f := NewFakeSsh()
+	defer tests.Patch(&sshNew,
+		func(logger *utils.Logger, user string, config *SshConfig) (Ssher, error) {
+			return f, nil
+		}).Restore()
+
+	hosts := []string{"host1", "host2", "host3"}
+
+	// By default one command runs at a time on each node
+	s, err := NewSshExecutor(&SshConfig{
+		PrivateKeyFile: "xkeyfile",
+	})
+	tests.Assert(t, err == nil)
+
+	maxHost, maxTotal := measureParallelism(t, s, f, hosts, 6)
+	tests.Assert(t, maxHost == 1, maxHost)
+	tests.Assert(t, maxTotal == len(hosts), maxTotal)
+
+	// More commands on each node
+	s, err = NewSshExecutor(&SshConfig{
+		PrivateKeyFile:        "xkeyfile",
+		MaxConnectionsPerHost: 3,
+	})
+	tests.Assert(t, err == nil)
+
+	maxHost, maxTotal = measureParallelism(t, s, f, hosts, 6)
+	tests.Assert(t, maxHost == 3, maxHost)
+	tests.Assert(t, maxTotal == 3*len(hosts), maxTotal)
+
+	// Limited across all the nodes
+	s, err = NewSshExecutor(&SshConfig{
+		PrivateKeyFile:        "xkeyfile",
+		MaxConnectionsPerHost: 3,
+		MaxConnections:        4,
+	})
+	tests.Assert(t, err == nil)
+
+	maxHost, maxTotal = measureParallelism(t, s, f, hosts, 6)
+	tests.Assert(t, maxHost <= 3, maxHost)
+	tests.Assert(t, maxTotal == 4, maxTotal)
+
+	// Executors which embed this one have no configuration
+	e := &SshExecutor{
+		Throttlemap: make(map[string]chan bool),
+		exec:        f,
+		port:        "22",
+	}
+	e.RemoteExecutor = e
+
+	maxHost, maxTotal = measureParallelism(t, e, f, hosts, 3)
+	tests.Assert(t, maxHost == 1, maxHost)
+	tests.Assert(t, maxTotal == len(hosts), maxTotal)
+}
+
+func TestSshExecTimeouts(t *testing.T) {
+	f := NewFakeSsh()
+	defer tests.Patch(&sshNew,
+		func(logger *utils.Logger, user string, config *SshConfig) (Ssher, error) {
+			return f, nil
+		}).Restore()
+
+	// Timeout used for the commands of each operation
+	timeouts := make(map[string]int)
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+
+		for _, name := range []string{"pvcreate", "lvcreate", "volume create", "add-brick"} {
+			if strings.Contains(strings.Join(commands, "\n"), name) {
+				timeouts[name] = timeoutMinutes
+			}
+		}
+
+		return []string{"vg:r/w:772:-1:0:0:0:-1:0:1:1:1073741824:4096:262143:0:262143:id"}, nil
+	}
+
+	run := func(s *SshExecutor) {
+		_, err := s.DeviceSetup("myhost", "/dev/sdb", "vgid")
+		tests.Assert(t, err == nil, err)
+
+		_, err = s.BrickCreate("myhost", &executors.BrickRequest{
+			VgId:   "vgid",
+			Name:   "id",
+			TpSize: 100,
+			Size:   10,
+		})
+		tests.Assert(t, err == nil, err)
+
+		volume := &executors.VolumeRequest{
+			Name:    "vol",
+			Type:    executors.DurabilityReplica,
+			Replica: 2,
+		}
+		for i := 0; i < 4; i++ {
+			volume.Bricks = append(volume.Bricks, executors.BrickInfo{
+				Host: "myhost",
+				Path: fmt.Sprintf("/brick%v", i),
+			})
+		}
+		_, err = s.VolumeCreate("myhost", volume)
+		tests.Assert(t, err == nil, err)
+		_, err = s.VolumeExpand("myhost", volume)
+		tests.Assert(t, err == nil, err)
+	}
+
+	// Defaults
+	s, err := NewSshExecutor(&SshConfig{
+		PrivateKeyFile: "xkeyfile",
+	})
+	tests.Assert(t, err == nil)
+
+	run(s)
+	tests.Assert(t, timeouts["pvcreate"] == 5, timeouts)
+	tests.Assert(t, timeouts["lvcreate"] == 10, timeouts)
+	tests.Assert(t, timeouts["volume create"] == 10, timeouts)
+	tests.Assert(t, timeouts["add-brick"] == 10, timeouts)
+
+	// Configured
+	s, err = NewSshExecutor(&SshConfig{
+		PrivateKeyFile:      "xkeyfile",
+		DeviceSetupTimeout:  7,
+		BrickCreateTimeout:  20,
+		VolumeCreateTimeout: 30,
+	})
+	tests.Assert(t, err == nil)
+
+	run(s)
+	tests.Assert(t, timeouts["pvcreate"] == 7, timeouts)
+	tests.Assert(t, timeouts["lvcreate"] == 20, timeouts)
+	tests.Assert(t, timeouts["volume create"] == 30, timeouts)
+	tests.Assert(t, timeouts["add-brick"] == 30, timeouts)
+}
+
+func TestSshExecRetry(t *testing.T) {
+	f := NewFakeSsh()
+	defer tests.Patch(&sshNew,
+		func(logger *utils.Logger, user string, config *SshConfig) (Ssher, error) {
+			return f, nil
+		}).Restore()
+
+	s, err := NewSshExecutor(&SshConfig{
+		PrivateKeyFile: "xkeyfile",
+		RetryAttempts:  3,
+	})
+	tests.Assert(t, err == nil)
+
+	// Do not wait between attempts
+	s.RetryPolicy.Backoff = 0
+
+	volume := &executors.VolumeRequest{
+		Name:    "vol",
+		Type:    executors.DurabilityReplica,
+		Replica: 2,
+		Bricks: []executors.BrickInfo{
+			{Host: "myhost", Path: "/brick0"},
+			{Host: "myhost", Path: "/brick1"},
+		},
+	}
+
+	// Volume create is tried again when the node cannot be reached
+	calls := 0
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+
+		calls++
+		if calls < 3 {
+			return nil, &executors.HostUnreachableError{
+				Host: host,
+				Err:  errors.New("connection refused"),
+			}
+		}
+		return []string{""}, nil
+	}
+
+	_, err = s.VolumeCreate("myhost", volume)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, calls == 3, calls)
+
+	// It is not tried again once some of the commands have run.
+	// Only the create is counted, as the volume is then cleaned up.
+	calls = 0
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+
+		if strings.Contains(commands[0], "volume create") {
+			calls++
+		}
+		return []string{""}, &executors.HostUnreachableError{
+			Host: host,
+			Err:  errors.New("connection reset"),
+		}
+	}
+
+	_, err = s.VolumeCreate("myhost", volume)
+	tests.Assert(t, executors.IsHostUnreachable(err), err)
+	tests.Assert(t, calls == 1, calls)
+
+	// Nor when a command fails
+	calls = 0
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+
+		if strings.Contains(commands[0], "volume create") {
+			calls++
+		}
+		return nil, &executors.CommandFailedError{
+			Host: host,
+			Result: executors.CommandResult{
+				Command:    commands[0],
+				ExitStatus: 1,
+			},
+		}
+	}
+
+	_, err = s.VolumeCreate("myhost", volume)
+	tests.Assert(t, executors.IsCommandFailed(err), err)
+	tests.Assert(t, calls == 1, calls)
+
+	// Queries are tried again when they time out, until
+	// all the attempts are used
+	calls = 0
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+
+		calls++
+		return nil, &executors.CommandTimeoutError{
+			Host:    host,
+			Command: commands[0],
+		}
+	}
+
+	_, err = s.DeviceUsage("myhost", "vgid")
+	tests.Assert(t, executors.IsTimeout(err), err)
+	tests.Assert(t, calls == 3, calls)
+
+	// Ping is not tried again
+	calls = 0
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+
+		calls++
+		return nil, &executors.HostUnreachableError{
+			Host: host,
+			Err:  errors.New("no route to host"),
+		}
+	}
+
+	err = s.Ping("myhost")
+	tests.Assert(t, executors.IsHostUnreachable(err), err)
+	tests.Assert(t, calls == 1, calls)
+}
+
+func TestSshExecHostConnection(t *testing.T) {
+	f := NewFakeSsh()
+	users := make([]string, 0)
+	defer tests.Patch(&sshNew,
+		func(logger *utils.Logger, user string, config *SshConfig) (Ssher, error) {
+			users = append(users, user)
+			return f, nil
+		}).Restore()
+
+	var address string
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+		address = host
+		return []string{""}, nil
+	}
+
+	// The ssh-agent can be used instead of a key file
+	s, err := NewSshExecutor(&SshConfig{
+		Agent: true,
+		User:  "xuser",
+		Port:  "100",
+	})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(users) == 1 && users[0] == "xuser", users)
+
+	// Nodes use the configuration by default
+	err = s.Ping("node1")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, address == "node1:100", address)
+
+	// A node with its own user and port
+	s.SetHostConnection("node2", executors.HostConnection{
+		User: "admin",
+		Port: "2222",
+	})
+	for i := 0; i < 2; i++ {
+		err = s.Ping("node2")
+		tests.Assert(t, err == nil, err)
+		tests.Assert(t, address == "node2:2222", address)
+	}
+	tests.Assert(t, len(users) == 2 && users[1] == "admin", users)
+
+	// Only the port
+	s.SetHostConnection("node3", executors.HostConnection{
+		Port: "2200",
+	})
+	err = s.Ping("node3")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, address == "node3:2200", address)
+	tests.Assert(t, len(users) == 2, users)
+
+	// Empty settings use the configuration again
+	s.SetHostConnection("node2", executors.HostConnection{})
+	err = s.Ping("node2")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, address == "node2:100", address)
+
+	// Errors creating the client for the user are returned
+	sshNew = func(logger *utils.Logger, user string, config *SshConfig) (Ssher, error) {
+		return nil, ErrSshAgent
+	}
+	s.SetHostConnection("node4", executors.HostConnection{
+		User: "other",
+	})
+	err = s.Ping("node4")
+	tests.Assert(t, err == ErrSshAgent, err)
+}
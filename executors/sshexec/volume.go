@@ -19,6 +19,8 @@ package sshexec
 import (
 	"encoding/xml"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/heketi/heketi/executors"
 	"github.com/lpabon/godbc"
@@ -75,7 +77,7 @@ func (s *SshExecutor) VolumeCreate(host string,
 	commands = append(commands, fmt.Sprintf("sudo gluster volume start %v", volume.Name))
 
 	// Execute command
-	_, err := s.RemoteExecutor.RemoteCommandExecute(host, commands, 10)
+	_, err := s.run(host, commands, s.volumeCreateTimeout())
 	if err != nil {
 		s.VolumeDestroy(host, volume.Name)
 		return nil, err
@@ -115,14 +117,15 @@ func (s *SshExecutor) VolumeExpand(host string,
 		inSet,
 		maxPerSet)
 
-	// Rebalance if configured
-	if s.config.RebalanceOnExpansion {
+	// Rebalance if configured.  Executors which embed this one
+	// do not have an ssh configuration.
+	if s.config != nil && s.config.RebalanceOnExpansion {
 		commands = append(commands,
 			fmt.Sprintf("sudo gluster --mode=script volume rebalance %v start", volume.Name))
 	}
 
 	// Execute command
-	_, err := s.RemoteExecutor.RemoteCommandExecute(host, commands, 10)
+	_, err := s.run(host, commands, s.volumeCreateTimeout())
 	if err != nil {
 		return nil, err
 	}
@@ -130,6 +133,255 @@ func (s *SshExecutor) VolumeExpand(host string,
 	return &executors.VolumeInfo{}, nil
 }
 
+func (s *SshExecutor) VolumeHealInfo(host string, volume string) (*executors.VolumeHealInfo, error) {
+	godbc.Require(host != "")
+	godbc.Require(volume != "")
+
+	// Stucture used to unmarshal XML from heal info gluster cli
+	type CliOutput struct {
+		HealInfo struct {
+			Bricks []struct {
+				Name            string `xml:"name"`
+				Status          string `xml:"status"`
+				NumberOfEntries string `xml:"numberOfEntries"`
+			} `xml:"bricks>brick"`
+		} `xml:"healInfo"`
+	}
+
+	commands := []string{
+		fmt.Sprintf("sudo gluster --mode=script volume heal %v info --xml", volume),
+	}
+
+	// Execute command
+	output, err := s.query(host, commands, 10)
+	if err != nil {
+		logger.LogError("Unable to get heal information from volume %v: %v", volume, err)
+		return nil, err
+	}
+
+	var cliOutput CliOutput
+	err = xml.Unmarshal([]byte(output[0].Stdout), &cliOutput)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to determine heal information from volume %v: %v", volume, err)
+	}
+
+	info := &executors.VolumeHealInfo{
+		Bricks: make([]executors.BrickHealInfo, 0),
+	}
+	for _, brick := range cliOutput.HealInfo.Bricks {
+		entries, err := strconv.Atoi(strings.TrimSpace(brick.NumberOfEntries))
+		if err != nil {
+			// Shown as '-' when the brick cannot be read
+			entries = -1
+		}
+		info.Bricks = append(info.Bricks, executors.BrickHealInfo{
+			Name:      brick.Name,
+			Connected: brick.Status == "Connected",
+			Entries:   entries,
+		})
+	}
+
+	return info, nil
+}
+
+func (s *SshExecutor) VolumeHeal(host string, volume string) error {
+	godbc.Require(host != "")
+	godbc.Require(volume != "")
+
+	// Start a full self-heal of the volume
+	commands := []string{
+		fmt.Sprintf("sudo gluster --mode=script volume heal %v full", volume),
+	}
+
+	// Execute command
+	_, err := s.run(host, commands, 10)
+	if err != nil {
+		logger.LogError("Unable to start heal on volume %v: %v", volume, err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *SshExecutor) VolumeRebalanceStart(host string, volume string) error {
+	godbc.Require(host != "")
+	godbc.Require(volume != "")
+
+	commands := []string{
+		fmt.Sprintf("sudo gluster --mode=script volume rebalance %v start", volume),
+	}
+
+	// Execute command
+	_, err := s.run(host, commands, 10)
+	if err != nil {
+		logger.LogError("Unable to start rebalance on volume %v: %v", volume, err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *SshExecutor) VolumeRebalanceStop(host string, volume string) error {
+	godbc.Require(host != "")
+	godbc.Require(volume != "")
+
+	commands := []string{
+		fmt.Sprintf("sudo gluster --mode=script volume rebalance %v stop", volume),
+	}
+
+	// Execute command
+	_, err := s.run(host, commands, 10)
+	if err != nil {
+		logger.LogError("Unable to stop rebalance on volume %v: %v", volume, err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *SshExecutor) VolumeRebalanceStatus(host string, volume string) (*executors.VolumeRebalanceInfo, error) {
+	godbc.Require(host != "")
+	godbc.Require(volume != "")
+
+	// Stucture used to unmarshal XML from rebalance status gluster cli
+	type RebalanceNode struct {
+		NodeName  string  `xml:"nodeName"`
+		Files     uint64  `xml:"files"`
+		Size      uint64  `xml:"size"`
+		Lookups   uint64  `xml:"lookups"`
+		Failures  uint64  `xml:"failures"`
+		Skipped   uint64  `xml:"skipped"`
+		StatusStr string  `xml:"statusStr"`
+		Runtime   float64 `xml:"runtime"`
+	}
+	type CliOutput struct {
+		OpRet        int    `xml:"opRet"`
+		OpErrStr     string `xml:"opErrstr"`
+		VolRebalance struct {
+			Nodes     []RebalanceNode `xml:"node"`
+			Aggregate RebalanceNode   `xml:"aggregate"`
+		} `xml:"volRebalance"`
+	}
+
+	commands := []string{
+		fmt.Sprintf("sudo gluster --mode=script volume rebalance %v status --xml", volume),
+	}
+
+	// Execute command
+	output, err := s.query(host, commands, 10)
+	if err != nil {
+		logger.LogError("Unable to get rebalance status of volume %v: %v", volume, err)
+		return nil, err
+	}
+
+	var cliOutput CliOutput
+	err = xml.Unmarshal([]byte(output[0].Stdout), &cliOutput)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to determine rebalance status of volume %v: %v", volume, err)
+	}
+	if cliOutput.OpRet != 0 {
+		return nil, fmt.Errorf("Unable to get rebalance status of volume %v: %v",
+			volume, cliOutput.OpErrStr)
+	}
+
+	info := &executors.VolumeRebalanceInfo{
+		Status: cliOutput.VolRebalance.Aggregate.StatusStr,
+		Nodes:  make([]executors.NodeRebalanceInfo, 0),
+	}
+	for _, node := range cliOutput.VolRebalance.Nodes {
+		info.Nodes = append(info.Nodes, executors.NodeRebalanceInfo{
+			Name:     node.NodeName,
+			Status:   node.StatusStr,
+			Files:    node.Files,
+			Size:     node.Size,
+			Lookups:  node.Lookups,
+			Failures: node.Failures,
+			Skipped:  node.Skipped,
+			RunTime:  node.Runtime,
+		})
+	}
+
+	return info, nil
+}
+
+func (s *SshExecutor) VolumeStatus(host string, volume string) (*executors.VolumeStatusInfo, error) {
+	godbc.Require(host != "")
+	godbc.Require(volume != "")
+
+	// Stucture used to unmarshal XML from volume status gluster cli
+	type CliOutput struct {
+		OpRet     int    `xml:"opRet"`
+		OpErrStr  string `xml:"opErrstr"`
+		VolStatus struct {
+			Volumes []struct {
+				Nodes []struct {
+					Hostname    string `xml:"hostname"`
+					Path        string `xml:"path"`
+					Status      int    `xml:"status"`
+					Port        string `xml:"ports>tcp"`
+					Pid         int    `xml:"pid"`
+					SizeTotal   uint64 `xml:"sizeTotal"`
+					SizeFree    uint64 `xml:"sizeFree"`
+					InodesTotal uint64 `xml:"inodesTotal"`
+					InodesFree  uint64 `xml:"inodesFree"`
+				} `xml:"node"`
+			} `xml:"volumes>volume"`
+		} `xml:"volStatus"`
+	}
+
+	commands := []string{
+		fmt.Sprintf("sudo gluster --mode=script volume status %v detail --xml", volume),
+	}
+
+	// Execute command
+	output, err := s.query(host, commands, 10)
+	if err != nil {
+		logger.LogError("Unable to get status of volume %v: %v", volume, err)
+		return nil, err
+	}
+
+	var cliOutput CliOutput
+	err = xml.Unmarshal([]byte(output[0].Stdout), &cliOutput)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to determine status of volume %v: %v", volume, err)
+	}
+	if cliOutput.OpRet != 0 {
+		return nil, fmt.Errorf("Unable to get status of volume %v: %v",
+			volume, cliOutput.OpErrStr)
+	}
+
+	info := &executors.VolumeStatusInfo{
+		Bricks: make([]executors.BrickStatusInfo, 0),
+	}
+	for _, vol := range cliOutput.VolStatus.Volumes {
+		for _, node := range vol.Nodes {
+			// Daemons such as self-heal are not bricks
+			if !strings.HasPrefix(node.Path, "/") {
+				continue
+			}
+
+			// Shown as 'N/A' when the brick is offline
+			port, err := strconv.Atoi(strings.TrimSpace(node.Port))
+			if err != nil {
+				port = 0
+			}
+
+			info.Bricks = append(info.Bricks, executors.BrickStatusInfo{
+				Name:        node.Hostname + ":" + node.Path,
+				Online:      node.Status == 1,
+				Port:        port,
+				Pid:         node.Pid,
+				SizeTotal:   node.SizeTotal,
+				SizeFree:    node.SizeFree,
+				InodesTotal: node.InodesTotal,
+				InodesFree:  node.InodesFree,
+			})
+		}
+	}
+
+	return info, nil
+}
+
 func (s *SshExecutor) VolumeDestroy(host string, volume string) error {
 	godbc.Require(host != "")
 	godbc.Require(volume != "")
@@ -141,7 +393,7 @@ func (s *SshExecutor) VolumeDestroy(host string, volume string) error {
 	}
 
 	// Execute command
-	_, err := s.RemoteExecutor.RemoteCommandExecute(host, commands, 10)
+	_, err := s.run(host, commands, 10)
 	if err != nil {
 		logger.LogError("Unable to stop volume %v: %v", volume, err)
 	}
@@ -153,7 +405,7 @@ func (s *SshExecutor) VolumeDestroy(host string, volume string) error {
 	}
 
 	// Execute command
-	_, err = s.RemoteExecutor.RemoteCommandExecute(host, commands, 10)
+	_, err = s.run(host, commands, 10)
 	if err != nil {
 		logger.LogError("Unable to delete volume %v: %v", volume, err)
 	}
@@ -217,13 +469,14 @@ func (s *SshExecutor) checkForSnapshots(host, volume string) error {
 	}
 
 	// Execute command
-	output, err := s.RemoteExecutor.RemoteCommandExecute(host, commands, 10)
+	output, err := s.query(host, commands, 10)
 	if err != nil {
-		return fmt.Errorf("Unable to get snapshot information from volume %v: %v", volume, err)
+		logger.LogError("Unable to get snapshot information from volume %v: %v", volume, err)
+		return err
 	}
 
 	var snapInfo CliOutput
-	err = xml.Unmarshal([]byte(output[0]), &snapInfo)
+	err = xml.Unmarshal([]byte(output[0].Stdout), &snapInfo)
 	if err != nil {
 		return fmt.Errorf("Unable to determine snapshot information from volume %v: %v", volume, err)
 	}
@@ -0,0 +1,382 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package sshexec
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/heketi/tests"
+	"github.com/heketi/utils"
+)
+
+func TestSshExecVolumeHealInfo(t *testing.T) {
+
+	f := NewFakeSsh()
+	defer tests.Patch(&sshNew,
+		func(logger *utils.Logger, user string, config *SshConfig) (Ssher, error) {
+			return f, nil
+		}).Restore()
+
+	config := &SshConfig{
+		PrivateKeyFile: "xkeyfile",
+		User:           "xuser",
+		Port:           "100",
+	}
+
+	s, err := NewSshExecutor(config)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, s != nil)
+
+	// Mock ssh function
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+
+		tests.Assert(t, host == "myhost:100", host)
+		tests.Assert(t, len(commands) == 1)
+		tests.Assert(t,
+			commands[0] == "sudo gluster --mode=script volume heal vol1 info --xml",
+			commands[0])
+
+		return []string{`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cliOutput>
+  <healInfo>
+    <bricks>
+      <brick hostUuid="a">
+        <name>host1:/bricks/b1</name>
+        <file gfid="f1">/dir/file1</file>
+        <file gfid="f2">/dir/file2</file>
+        <status>Connected</status>
+        <numberOfEntries>2</numberOfEntries>
+      </brick>
+      <brick hostUuid="b">
+        <name>host2:/bricks/b2</name>
+        <status>Connected</status>
+        <numberOfEntries>0</numberOfEntries>
+      </brick>
+      <brick hostUuid="c">
+        <name>host3:/bricks/b3</name>
+        <status>Transport endpoint is not connected</status>
+        <numberOfEntries>-</numberOfEntries>
+      </brick>
+    </bricks>
+  </healInfo>
+  <opRet>0</opRet>
+</cliOutput>`}, nil
+	}
+
+	info, err := s.VolumeHealInfo("myhost", "vol1")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(info.Bricks) == 3)
+	tests.Assert(t, info.Bricks[0].Name == "host1:/bricks/b1")
+	tests.Assert(t, info.Bricks[0].Connected)
+	tests.Assert(t, info.Bricks[0].Entries == 2)
+	tests.Assert(t, info.Bricks[1].Entries == 0)
+	tests.Assert(t, !info.Bricks[2].Connected)
+	tests.Assert(t, info.Bricks[2].Entries == -1)
+
+	pending, complete := info.Pending()
+	tests.Assert(t, pending == 2)
+	tests.Assert(t, !complete)
+
+	// Bad output
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+		return []string{"not xml"}, nil
+	}
+
+	_, err = s.VolumeHealInfo("myhost", "vol1")
+	tests.Assert(t, err != nil)
+}
+
+func TestSshExecVolumeHeal(t *testing.T) {
+
+	f := NewFakeSsh()
+	defer tests.Patch(&sshNew,
+		func(logger *utils.Logger, user string, config *SshConfig) (Ssher, error) {
+			return f, nil
+		}).Restore()
+
+	config := &SshConfig{
+		PrivateKeyFile: "xkeyfile",
+		User:           "xuser",
+		Port:           "100",
+	}
+
+	s, err := NewSshExecutor(config)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, s != nil)
+
+	// Mock ssh function
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+
+		tests.Assert(t, host == "myhost:100", host)
+		tests.Assert(t, len(commands) == 1)
+		tests.Assert(t,
+			commands[0] == "sudo gluster --mode=script volume heal vol1 full",
+			commands[0])
+
+		return []string{""}, nil
+	}
+
+	err = s.VolumeHeal("myhost", "vol1")
+	tests.Assert(t, err == nil, err)
+
+	// Command failure
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+		return nil, errors.New("Launching heal operation has been unsuccessful")
+	}
+
+	err = s.VolumeHeal("myhost", "vol1")
+	tests.Assert(t, err != nil)
+}
+
+func TestSshExecVolumeRebalanceStatus(t *testing.T) {
+
+	f := NewFakeSsh()
+	defer tests.Patch(&sshNew,
+		func(logger *utils.Logger, user string, config *SshConfig) (Ssher, error) {
+			return f, nil
+		}).Restore()
+
+	config := &SshConfig{
+		PrivateKeyFile: "xkeyfile",
+		User:           "xuser",
+		Port:           "100",
+	}
+
+	s, err := NewSshExecutor(config)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, s != nil)
+
+	// Mock ssh function
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+
+		tests.Assert(t, host == "myhost:100", host)
+		tests.Assert(t, len(commands) == 1)
+		tests.Assert(t,
+			commands[0] == "sudo gluster --mode=script volume rebalance vol1 status --xml",
+			commands[0])
+
+		return []string{`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cliOutput>
+  <opRet>0</opRet>
+  <opErrno>0</opErrno>
+  <opErrstr/>
+  <volRebalance>
+    <task-id>6a2f2a4e-3e2b-4b0e-9a5c-2a6f7e1b4c11</task-id>
+    <op>3</op>
+    <nodeCount>2</nodeCount>
+    <node>
+      <nodeName>localhost</nodeName>
+      <id>a</id>
+      <files>10</files>
+      <size>1048576</size>
+      <lookups>20</lookups>
+      <failures>0</failures>
+      <skipped>1</skipped>
+      <status>3</status>
+      <statusStr>completed</statusStr>
+      <runtime>12.50</runtime>
+    </node>
+    <node>
+      <nodeName>host2</nodeName>
+      <id>b</id>
+      <files>4</files>
+      <size>4096</size>
+      <lookups>8</lookups>
+      <failures>2</failures>
+      <skipped>0</skipped>
+      <status>1</status>
+      <statusStr>in progress</statusStr>
+      <runtime>30.00</runtime>
+    </node>
+    <aggregate>
+      <files>14</files>
+      <size>1052672</size>
+      <lookups>28</lookups>
+      <failures>2</failures>
+      <skipped>1</skipped>
+      <status>1</status>
+      <statusStr>in progress</statusStr>
+      <runtime>30.00</runtime>
+    </aggregate>
+  </volRebalance>
+</cliOutput>`}, nil
+	}
+
+	info, err := s.VolumeRebalanceStatus("myhost", "vol1")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, info.Status == "in progress")
+	tests.Assert(t, info.InProgress())
+	tests.Assert(t, len(info.Nodes) == 2)
+	tests.Assert(t, info.Nodes[0].Name == "localhost")
+	tests.Assert(t, info.Nodes[0].Status == "completed")
+	tests.Assert(t, info.Nodes[0].Files == 10)
+	tests.Assert(t, info.Nodes[0].Size == 1048576)
+	tests.Assert(t, info.Nodes[0].Lookups == 20)
+	tests.Assert(t, info.Nodes[0].Skipped == 1)
+	tests.Assert(t, info.Nodes[0].RunTime == 12.5)
+	tests.Assert(t, info.Nodes[1].Failures == 2)
+
+	// Rebalance never started
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+		return []string{`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cliOutput>
+  <opRet>-1</opRet>
+  <opErrno>0</opErrno>
+  <opErrstr>Rebalance not started for volume vol1.</opErrstr>
+</cliOutput>`}, nil
+	}
+
+	_, err = s.VolumeRebalanceStatus("myhost", "vol1")
+	tests.Assert(t, err != nil)
+	tests.Assert(t, strings.Contains(err.Error(), "Rebalance not started"), err)
+}
+
+func TestSshExecVolumeStatus(t *testing.T) {
+
+	f := NewFakeSsh()
+	defer tests.Patch(&sshNew,
+		func(logger *utils.Logger, user string, config *SshConfig) (Ssher, error) {
+			return f, nil
+		}).Restore()
+
+	config := &SshConfig{
+		PrivateKeyFile: "xkeyfile",
+		User:           "xuser",
+		Port:           "100",
+	}
+
+	s, err := NewSshExecutor(config)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, s != nil)
+
+	// Mock ssh function
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+
+		tests.Assert(t, host == "myhost:100", host)
+		tests.Assert(t, len(commands) == 1)
+		tests.Assert(t,
+			commands[0] == "sudo gluster --mode=script volume status vol1 detail --xml",
+			commands[0])
+
+		return []string{`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cliOutput>
+  <opRet>0</opRet>
+  <opErrno>0</opErrno>
+  <opErrstr/>
+  <volStatus>
+    <volumes>
+      <volume>
+        <volName>vol1</volName>
+        <nodeCount>3</nodeCount>
+        <node>
+          <hostname>host1</hostname>
+          <path>/bricks/b1</path>
+          <peerid>a</peerid>
+          <status>1</status>
+          <port>49152</port>
+          <ports>
+            <tcp>49152</tcp>
+            <rdma>N/A</rdma>
+          </ports>
+          <pid>1234</pid>
+          <sizeTotal>10724835328</sizeTotal>
+          <sizeFree>10691031040</sizeFree>
+          <device>/dev/mapper/vg-brick1</device>
+          <blockSize>4096</blockSize>
+          <mntOptions>rw,noatime</mntOptions>
+          <fsName>xfs</fsName>
+          <inodeSize>512</inodeSize>
+          <inodesTotal>5242368</inodesTotal>
+          <inodesFree>5242337</inodesFree>
+        </node>
+        <node>
+          <hostname>host2</hostname>
+          <path>/bricks/b2</path>
+          <peerid>b</peerid>
+          <status>0</status>
+          <port>N/A</port>
+          <ports>
+            <tcp>N/A</tcp>
+            <rdma>N/A</rdma>
+          </ports>
+          <pid>-1</pid>
+          <sizeTotal>10724835328</sizeTotal>
+          <sizeFree>10724835328</sizeFree>
+          <inodesTotal>5242368</inodesTotal>
+          <inodesFree>5242368</inodesFree>
+        </node>
+        <node>
+          <hostname>Self-heal Daemon</hostname>
+          <path>localhost</path>
+          <peerid>a</peerid>
+          <status>1</status>
+          <port>N/A</port>
+          <pid>4321</pid>
+        </node>
+      </volume>
+    </volumes>
+  </volStatus>
+</cliOutput>`}, nil
+	}
+
+	info, err := s.VolumeStatus("myhost", "vol1")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(info.Bricks) == 2, info.Bricks)
+	tests.Assert(t, info.Bricks[0].Name == "host1:/bricks/b1")
+	tests.Assert(t, info.Bricks[0].Online)
+	tests.Assert(t, info.Bricks[0].Port == 49152)
+	tests.Assert(t, info.Bricks[0].Pid == 1234)
+	tests.Assert(t, info.Bricks[0].SizeTotal == 10724835328)
+	tests.Assert(t, info.Bricks[0].SizeFree == 10691031040)
+	tests.Assert(t, info.Bricks[0].InodesTotal == 5242368)
+	tests.Assert(t, info.Bricks[0].InodesFree == 5242337)
+	tests.Assert(t, info.Bricks[1].Name == "host2:/bricks/b2")
+	tests.Assert(t, !info.Bricks[1].Online)
+	tests.Assert(t, info.Bricks[1].Port == 0)
+	tests.Assert(t, info.Bricks[1].Pid == -1)
+
+	// Volume does not exist
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int) ([]string, error) {
+		return []string{`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cliOutput>
+  <opRet>-1</opRet>
+  <opErrno>30800</opErrno>
+  <opErrstr>Volume vol1 does not exist</opErrstr>
+</cliOutput>`}, nil
+	}
+
+	_, err = s.VolumeStatus("myhost", "vol1")
+	tests.Assert(t, err != nil)
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/heketi/heketi/apps"
 	"github.com/heketi/heketi/apps/glusterfs"
 	"github.com/heketi/heketi/middleware"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"os/signal"
@@ -41,11 +42,14 @@ var (
 	HEKETI_VERSION = "(dev)"
 	configfile     string
 	showVersion    bool
+	checkConfig    bool
 )
 
 func init() {
 	flag.StringVar(&configfile, "config", "", "Configuration file")
 	flag.BoolVar(&showVersion, "version", false, "Show version")
+	flag.BoolVar(&checkConfig, "validate-config", false,
+		"Validate the configuration file and exit")
 }
 
 func printVersion() {
@@ -74,6 +78,51 @@ func setWithEnvVariables(options *Config) {
 	}
 }
 
+// Reads the configuration file again and applies the settings which
+// can be changed while the server is running
+func reloadConfig(app *glusterfs.App, jwtauth *middleware.JwtAuth, current *Config) {
+	fmt.Printf("Reloading configuration file %v\n", configfile)
+
+	fp, err := os.Open(configfile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: Unable to open config file %v: %v\n",
+			configfile,
+			err.Error())
+		return
+	}
+	defer fp.Close()
+
+	var options Config
+	if err = json.NewDecoder(fp).Decode(&options); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: Unable to parse %v: %v\n",
+			configfile,
+			err.Error())
+		return
+	}
+	setWithEnvVariables(&options)
+
+	// Apply the application settings first, so that nothing is
+	// changed if they are not valid
+	fp.Seek(0, os.SEEK_SET)
+	if err = app.Reload(fp); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: Unable to reload configuration: %v\n", err)
+		return
+	}
+
+	if options.Port != current.Port {
+		fmt.Fprintln(os.Stderr, "ERROR: Unable to change port without a restart")
+	}
+	if options.AuthEnabled != current.AuthEnabled {
+		fmt.Fprintln(os.Stderr, "ERROR: Unable to change use_auth without a restart")
+	} else if jwtauth != nil {
+		if err = jwtauth.SetKeys(&options.JwtConfig); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: Unable to change JWT keys: %v\n", err)
+		} else {
+			current.JwtConfig = options.JwtConfig
+		}
+	}
+}
+
 func main() {
 	flag.Parse()
 	printVersion()
@@ -89,6 +138,27 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Quit here if all we needed to do was validate the configuration
+	if checkConfig {
+		data, err := ioutil.ReadFile(configfile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: Unable to read config file %v: %v\n",
+				configfile,
+				err.Error())
+			os.Exit(1)
+		}
+
+		errs := validateConfig(data)
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		}
+		if len(errs) != 0 {
+			os.Exit(1)
+		}
+		fmt.Printf("Configuration file %v is valid\n", configfile)
+		return
+	}
+
 	// Read configuration
 	fp, err := os.Open(configfile)
 	if err != nil {
@@ -133,6 +203,16 @@ func main() {
 			fmt.Fprint(w, "Hello from Heketi")
 		})
 
+	// Add health routers.  These are not behind authorization
+	// so that they can be used by probes and load balancers.
+	// Checking the nodes with /readyz?executor=true runs commands
+	// on them and is left to the authorized routes.
+	router.Methods("GET").Path("/healthz").Name("Healthz").HandlerFunc(
+		glusterfsApp.Healthz)
+	router.Methods("GET").Path("/readyz").Name("Readyz").
+		MatcherFunc(glusterfs.ReadyzWithoutExecutor).HandlerFunc(
+		glusterfsApp.Readyz)
+
 	// Create a router and do not allow any routes
 	// unless defined.
 	heketiRouter := mux.NewRouter().StrictSlash(true)
@@ -148,8 +228,9 @@ func main() {
 	n := negroni.New(negroni.NewRecovery(), negroni.NewLogger())
 
 	// Load authorization JWT middleware
+	var jwtauth *middleware.JwtAuth
 	if options.AuthEnabled {
-		jwtauth := middleware.NewJwtAuth(&options.JwtConfig)
+		jwtauth = middleware.NewJwtAuth(&options.JwtConfig)
 		if jwtauth == nil {
 			fmt.Fprintln(os.Stderr, "ERROR: Missing JWT information in config file")
 			os.Exit(1)
@@ -175,6 +256,10 @@ func main() {
 	signalch := make(chan os.Signal, 1)
 	signal.Notify(signalch, os.Interrupt, os.Kill, syscall.SIGINT, syscall.SIGTERM)
 
+	// Reload the configuration on SIGHUP
+	reloadch := make(chan os.Signal, 1)
+	signal.Notify(reloadch, syscall.SIGHUP)
+
 	// Create a channel to know if the server was unable to start
 	done := make(chan bool)
 	go func() {
@@ -188,9 +273,15 @@ func main() {
 	}()
 
 	// Block here for signals and errors from the HTTP server
-	select {
-	case <-signalch:
-	case <-done:
+	for running := true; running; {
+		select {
+		case <-reloadch:
+			reloadConfig(glusterfsApp, jwtauth, &options)
+		case <-signalch:
+			running = false
+		case <-done:
+			running = false
+		}
 	}
 	fmt.Printf("Shutting down...\n")
 
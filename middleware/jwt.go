@@ -25,6 +25,7 @@ import (
 	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/gorilla/context"
 	"net/http"
+	"sync"
 )
 
 var (
@@ -34,6 +35,7 @@ var (
 type JwtAuth struct {
 	adminKey []byte
 	userKey  []byte
+	lock     sync.RWMutex
 }
 
 type Issuer struct {
@@ -67,6 +69,22 @@ func NewJwtAuth(config *JwtAuthConfig) *JwtAuth {
 	return j
 }
 
+// Replaces the keys used to validate the tokens
+func (j *JwtAuth) SetKeys(config *JwtAuthConfig) error {
+	if config.Admin.PrivateKey == "" ||
+		config.User.PrivateKey == "" {
+		return errors.New("Missing JWT information")
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	j.adminKey = []byte(config.Admin.PrivateKey)
+	j.userKey = []byte(config.User.PrivateKey)
+
+	return nil
+}
+
 func (j *JwtAuth) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 
 	// Access token from header
@@ -86,6 +104,9 @@ func (j *JwtAuth) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.Ha
 	token, err := jwt.Parse(rawtoken,
 		func(token *jwt.Token) (interface{}, error) {
 			if issuer, ok := token.Claims["iss"]; ok {
+				j.lock.RLock()
+				defer j.lock.RUnlock()
+
 				switch issuer {
 				case "admin":
 					return j.adminKey, nil
@@ -481,3 +481,62 @@ func TestJwtInvalidKeys(t *testing.T) {
 	tests.Assert(t, err == nil)
 	tests.Assert(t, strings.Contains(s, "signature is invalid"))
 }
+
+func TestJwtSetKeys(t *testing.T) {
+	// Setup jwt
+	c := &JwtAuthConfig{}
+	c.Admin.PrivateKey = "Key"
+	c.User.PrivateKey = "UserKey"
+	j := NewJwtAuth(c)
+	tests.Assert(t, j != nil)
+
+	// Missing keys are not accepted
+	err := j.SetKeys(&JwtAuthConfig{})
+	tests.Assert(t, err != nil)
+	tests.Assert(t, string(j.adminKey) == "Key")
+	tests.Assert(t, string(j.userKey) == "UserKey")
+
+	// Setup middleware framework
+	n := negroni.New(j)
+	n.UseHandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	// Create test server
+	ts := httptest.NewServer(n)
+	defer ts.Close()
+
+	// Create token
+	token := jwt.New(jwt.SigningMethodHS256)
+	token.Claims["iss"] = "admin"
+	token.Claims["iat"] = time.Now().Unix()
+	token.Claims["exp"] = time.Now().Add(time.Second * 10).Unix()
+
+	// Generate qsh
+	qshstring := "GET&/"
+	hash := sha256.New()
+	hash.Write([]byte(qshstring))
+	token.Claims["qsh"] = hex.EncodeToString(hash.Sum(nil))
+
+	tokenString, err := token.SignedString([]byte("NewKey"))
+	tests.Assert(t, err == nil)
+
+	req, err := http.NewRequest("GET", ts.URL, nil)
+	tests.Assert(t, err == nil)
+	req.Header.Set("Authorization", "bearer "+tokenString)
+
+	// Token signed with the new key is rejected
+	r, err := http.DefaultClient.Do(req)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusUnauthorized)
+
+	// Change the keys
+	c.Admin.PrivateKey = "NewKey"
+	err = j.SetKeys(c)
+	tests.Assert(t, err == nil)
+
+	// Now it is accepted
+	r, err = http.DefaultClient.Do(req)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusOK)
+}
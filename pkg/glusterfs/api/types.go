@@ -14,10 +14,8 @@
 // limitations under the License.
 //
 
-//
 // Please see https://github.com/heketi/heketi/wiki/API
 // for documentation
-//
 package api
 
 import (
@@ -33,6 +31,9 @@ const (
 	EntryStateOnline  EntryState = "online"
 	EntryStateOffline EntryState = "offline"
 	EntryStateFailed  EntryState = "failed"
+
+	// Like offline, but set while the node is being serviced
+	EntryStateMaintenance EntryState = "maintenance"
 )
 
 type DurabilityType string
@@ -73,7 +74,8 @@ type BrickInfo struct {
 
 // Device
 type Device struct {
-	Name string `json:"name"`
+	Name string            `json:"name"`
+	Tags map[string]string `json:"tags,omitempty"`
 }
 
 type DeviceAddRequest struct {
@@ -87,6 +89,55 @@ type DeviceInfo struct {
 	Id      string      `json:"id"`
 }
 
+type DeviceTagsRequest struct {
+	Tags map[string]string `json:"tags"`
+}
+
+// Sizes known by the server before and after
+// rereading the size of the device
+type DeviceResyncResponse struct {
+	Id       string      `json:"id"`
+	Previous StorageSize `json:"previous"`
+	Current  StorageSize `json:"current"`
+}
+
+// SMART health of a device
+type DeviceHealthResponse struct {
+	Id                 string `json:"id"`
+	Passed             bool   `json:"passed"`
+	ReallocatedSectors uint64 `json:"reallocated_sectors"`
+	PendingSectors     uint64 `json:"pending_sectors"`
+	Temperature        int    `json:"temperature"`
+}
+
+// Actual usage of a brick as read from its node
+type BrickUsageInfo struct {
+	Id string `json:"id"`
+
+	// Sizes in KB of the brick filesystem
+	Size uint64 `json:"size"`
+	Used uint64 `json:"used"`
+
+	// Size in KB of the thin pool and how full it is
+	PoolSize            uint64  `json:"pool_size"`
+	PoolDataPercent     float64 `json:"pool_data_percent"`
+	PoolMetadataPercent float64 `json:"pool_metadata_percent"`
+
+	// Set when the thin pool has passed the fill threshold
+	PoolWarning bool `json:"pool_warning"`
+}
+
+type DeviceUsageResponse struct {
+	Id string `json:"id"`
+
+	// Space allocated by the server
+	Storage StorageSize `json:"storage"`
+
+	// KB actually written to the thin pools of the device
+	Used   uint64           `json:"used"`
+	Bricks []BrickUsageInfo `json:"bricks"`
+}
+
 type DeviceInfoResponse struct {
 	DeviceInfo
 	State  EntryState  `json:"state"`
@@ -98,6 +149,23 @@ type NodeAddRequest struct {
 	Zone      int           `json:"zone"`
 	Hostnames HostAddresses `json:"hostnames"`
 	ClusterId string        `json:"cluster"`
+
+	// User and port to ssh to the node as, when they are not
+	// those of the server configuration
+	SshUser string `json:"ssh_user,omitempty"`
+	SshPort string `json:"ssh_port,omitempty"`
+}
+
+// Value of SshUser or SshPort in a NodeUpdateRequest which makes the
+// node use the user or port of the server configuration again
+const NodeSshDefault = "default"
+
+// Values which are not set are not changed
+type NodeUpdateRequest struct {
+	Zone      int           `json:"zone,omitempty"`
+	Hostnames HostAddresses `json:"hostnames"`
+	SshUser   string        `json:"ssh_user,omitempty"`
+	SshPort   string        `json:"ssh_port,omitempty"`
 }
 
 type NodeInfo struct {
@@ -109,9 +177,52 @@ type NodeInfoResponse struct {
 	NodeInfo
 	State       EntryState           `json:"state"`
 	DevicesInfo []DeviceInfoResponse `json:"devices"`
+
+	// Heartbeat, last seen in RFC3339 format
+	LastSeen          string `json:"last_seen,omitempty"`
+	HeartbeatFailures int    `json:"heartbeat_failures,omitempty"`
+}
+
+// Result of connecting to the node and running a command
+// with sudo on it
+type NodeSshCheckResponse struct {
+	Id        string `json:"id"`
+	Host      string `json:"host"`
+	Reachable bool   `json:"reachable"`
+	Sudo      bool   `json:"sudo"`
+	Error     string `json:"error,omitempty"`
 }
 
 // Cluster
+// Settings which are not set use the server defaults
+type ClusterSettings struct {
+	// Sizes in GB
+	BrickMinSize int `json:"brick_min_size_gb,omitempty"`
+	BrickMaxSize int `json:"brick_max_size_gb,omitempty"`
+	BrickMaxNum  int `json:"max_bricks_per_volume,omitempty"`
+
+	// Default durability of volumes created in the cluster
+	Durability VolumeDurabilityInfo `json:"durability,omitempty"`
+
+	// Formatting and mounting of new bricks: options given to
+	// mkfs.xfs and mount, chunk size of the thin pools, and
+	// directory the bricks are mounted under
+	BrickMkfsOptions  string `json:"brick_mkfs_options,omitempty"`
+	BrickMountOptions string `json:"brick_mount_options,omitempty"`
+	BrickChunkSize    int    `json:"brick_chunk_size_kb,omitempty"`
+	BrickMountRoot    string `json:"brick_mount_root,omitempty"`
+}
+
+type ClusterCreateRequest struct {
+	Settings ClusterSettings   `json:"settings"`
+	Labels   map[string]string `json:"labels,omitempty"`
+}
+
+// Replaces the labels of a cluster
+type ClusterLabelsRequest struct {
+	Labels map[string]string `json:"labels"`
+}
+
 type Cluster struct {
 	Volumes []VolumeInfoResponse `json:"volumes"`
 	Nodes   []NodeInfoResponse   `json:"nodes"`
@@ -123,9 +234,11 @@ type TopologyInfoResponse struct {
 }
 
 type ClusterInfoResponse struct {
-	Id      string           `json:"id"`
-	Nodes   sort.StringSlice `json:"nodes"`
-	Volumes sort.StringSlice `json:"volumes"`
+	Id       string            `json:"id"`
+	Nodes    sort.StringSlice  `json:"nodes"`
+	Volumes  sort.StringSlice  `json:"volumes"`
+	Settings ClusterSettings   `json:"settings"`
+	Labels   map[string]string `json:"labels,omitempty"`
 }
 
 type ClusterListResponse struct {
@@ -159,6 +272,12 @@ type VolumeCreateRequest struct {
 		Enable bool    `json:"enable"`
 		Factor float32 `json:"factor"`
 	} `json:"snapshot"`
+
+	// Only clusters with all of these labels are used
+	ClusterSelector map[string]string `json:"cluster_selector,omitempty"`
+
+	// Only devices with all of these tags are used
+	DeviceSelector map[string]string `json:"device_selector,omitempty"`
 }
 
 type VolumeInfo struct {
@@ -184,6 +303,113 @@ type VolumeListResponse struct {
 
 type VolumeExpandRequest struct {
 	Size int `json:"expand_size"`
+
+	// Start a rebalance once the bricks have been added and only
+	// complete the request after it has finished.  The request fails
+	// if the rebalance is still running after six hours.
+	WaitForRebalance bool `json:"wait_for_rebalance,omitempty"`
+}
+
+type VolumeRebalanceRequest struct {
+	// Either "start" or "stop"
+	Action string `json:"action"`
+}
+
+// Rebalance progress of a volume on one of its nodes
+type NodeRebalanceInfo struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Files    uint64 `json:"files"`
+	Size     uint64 `json:"size"`
+	Lookups  uint64 `json:"lookups"`
+	Failures uint64 `json:"failures"`
+	Skipped  uint64 `json:"skipped"`
+
+	// Run time in seconds
+	RunTime float64 `json:"run_time"`
+}
+
+type VolumeRebalanceResponse struct {
+	Id     string              `json:"id"`
+	Status string              `json:"status"`
+	Nodes  []NodeRebalanceInfo `json:"nodes"`
+}
+
+type VolumeUsageResponse struct {
+	Id string `json:"id"`
+
+	// KB used on all the bricks, including replicas
+	Used   uint64           `json:"used"`
+	Bricks []BrickUsageInfo `json:"bricks"`
+}
+
+// Live status of a brick in a volume
+type BrickStatusInfo struct {
+	// Empty if the brick is not known to heketi
+	Id     string `json:"id"`
+	Name   string `json:"name"`
+	Online bool   `json:"online"`
+
+	// Zero or negative when the brick process is not running
+	Port int `json:"port"`
+	Pid  int `json:"pid"`
+
+	Storage     StorageSize `json:"storage"`
+	InodesTotal uint64      `json:"inodes_total"`
+	InodesFree  uint64      `json:"inodes_free"`
+}
+
+type VolumeStatusResponse struct {
+	Id string `json:"id"`
+
+	// True if any brick of the volume is not online
+	Degraded bool              `json:"degraded"`
+	Bricks   []BrickStatusInfo `json:"bricks"`
+}
+
+// Self-heal status of a brick in a volume
+type BrickHealInfo struct {
+	// Empty if the brick is not known to heketi
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	Connected bool   `json:"connected"`
+
+	// Entries pending heal, or -1 if the brick could not be read
+	Entries int `json:"entries"`
+}
+
+type VolumeHealResponse struct {
+	Id string `json:"id"`
+
+	// Total entries pending heal on the bricks which could be read
+	Pending int `json:"pending"`
+
+	// False if any brick could not be read
+	Complete bool            `json:"complete"`
+	Bricks   []BrickHealInfo `json:"bricks"`
+}
+
+// Dry run
+type HostCommands struct {
+	Host     string   `json:"host"`
+	Commands []string `json:"commands"`
+}
+
+// Commands a request would run, in the order they would run
+type DryRunResponse struct {
+	Commands []HostCommands `json:"commands"`
+}
+
+// Health
+type HealthCheck struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Message string `json:"message,omitempty"`
+}
+
+type HealthResponse struct {
+	Healthy bool          `json:"healthy"`
+	Checks  []HealthCheck `json:"checks"`
 }
 
 // Constructors
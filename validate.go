@@ -0,0 +1,135 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/heketi/heketi/apps/glusterfs"
+)
+
+// Layout of the entire configuration file
+type configFile struct {
+	Config
+	glusterfs.ConfigFile
+}
+
+// Checks the configuration file data and returns every problem found.
+// Keys starting with '_' are treated as comments and ignored.
+func validateConfig(data []byte) []error {
+	errs := make([]error, 0)
+
+	// Check for unknown keys
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return append(errs, fmt.Errorf("Unable to parse configuration: %v", err))
+	}
+	errs = append(errs, unknownConfigKeys("", raw, reflect.TypeOf(configFile{}))...)
+
+	// Check for values of the wrong type
+	var options Config
+	if err := json.Unmarshal(data, &options); err != nil {
+		errs = append(errs, fmt.Errorf("Unable to parse configuration: %v", err))
+	}
+	setWithEnvVariables(&options)
+
+	if options.Port == "" {
+		errs = append(errs, fmt.Errorf("port must be provided"))
+	} else if port, err := strconv.Atoi(options.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("Invalid port %v", options.Port))
+	}
+
+	if options.AuthEnabled {
+		if options.JwtConfig.Admin.PrivateKey == "" {
+			errs = append(errs, fmt.Errorf("jwt: admin key must be provided when use_auth is enabled"))
+		}
+		if options.JwtConfig.User.PrivateKey == "" {
+			errs = append(errs, fmt.Errorf("jwt: user key must be provided when use_auth is enabled"))
+		}
+	}
+
+	// Check the application settings
+	errs = append(errs, glusterfs.ValidateConfiguration(bytes.NewReader(data))...)
+
+	return errs
+}
+
+// Returns the json key names of the fields in the struct type t,
+// including those of embedded structs
+func configFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			for name, ftype := range configFields(field.Type) {
+				fields[name] = ftype
+			}
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		} else if name == "" {
+			name = field.Name
+		}
+
+		// encoding/json matches keys without regard to case
+		fields[strings.ToLower(name)] = field.Type
+	}
+
+	return fields
+}
+
+func unknownConfigKeys(prefix string, value interface{}, t reflect.Type) []error {
+	errs := make([]error, 0)
+
+	values, ok := value.(map[string]interface{})
+	if !ok || t.Kind() != reflect.Struct {
+		return errs
+	}
+
+	// Sort the keys so that the errors are always in the same order
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fields := configFields(t)
+	for _, key := range keys {
+		if strings.HasPrefix(key, "_") {
+			continue
+		}
+
+		ftype, ok := fields[strings.ToLower(key)]
+		if !ok {
+			errs = append(errs, fmt.Errorf("Unknown configuration key %v%v", prefix, key))
+			continue
+		}
+
+		errs = append(errs, unknownConfigKeys(prefix+key+".", values[key], ftype)...)
+	}
+
+	return errs
+}
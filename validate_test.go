@@ -0,0 +1,95 @@
+//
+// Copyright (c) 2016 The heketi Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/heketi/tests"
+)
+
+func TestValidateConfig(t *testing.T) {
+	data := []byte(`{
+		"_port_comment": "Comments are ignored",
+		"port" : "8080",
+		"use_auth" : true,
+		"jwt" : {
+			"admin" : { "key" : "admin secret" },
+			"user" : { "key" : "user secret" }
+		},
+		"glusterfs" : {
+			"_executor_comment": [ "Comments can be of any type" ],
+			"executor" : "mock",
+			"loglevel" : "debug",
+			"brick_max_size_gb" : 1024,
+			"brick_min_size_gb" : 1
+		}
+	}`)
+	errs := validateConfig(data)
+	tests.Assert(t, len(errs) == 0, errs)
+}
+
+func TestValidateConfigBadJson(t *testing.T) {
+	errs := validateConfig([]byte(`{ bad json }`))
+	tests.Assert(t, len(errs) == 1, errs)
+}
+
+func TestValidateConfigUnknownKeys(t *testing.T) {
+	data := []byte(`{
+		"port" : "8080",
+		"prot" : "8080",
+		"jwt" : {
+			"admin" : { "kye" : "admin secret" }
+		},
+		"glusterfs" : {
+			"executor" : "mock",
+			"sshexec" : { "keyfiel" : "/path" }
+		}
+	}`)
+	errs := validateConfig(data)
+	tests.Assert(t, len(errs) == 3, errs)
+	tests.Assert(t, strings.Contains(errs[0].Error(), "glusterfs.sshexec.keyfiel"), errs[0])
+	tests.Assert(t, strings.Contains(errs[1].Error(), "jwt.admin.kye"), errs[1])
+	tests.Assert(t, strings.Contains(errs[2].Error(), "prot"), errs[2])
+}
+
+func TestValidateConfigAllErrors(t *testing.T) {
+	data := []byte(`{
+		"port" : "notaport",
+		"use_auth" : true,
+		"glusterfs" : {
+			"executor" : "unknown",
+			"allocator" : "unknown",
+			"brick_max_size_gb" : 1,
+			"brick_min_size_gb" : 2
+		}
+	}`)
+	errs := validateConfig(data)
+	tests.Assert(t, len(errs) == 6, errs)
+}
+
+func TestValidateConfigWrongType(t *testing.T) {
+	data := []byte(`{
+		"port" : 8080,
+		"glusterfs" : {
+			"executor" : "mock"
+		}
+	}`)
+	errs := validateConfig(data)
+	tests.Assert(t, len(errs) == 2, errs)
+}